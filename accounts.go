@@ -15,6 +15,7 @@ import (
 func handleAccounts(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -27,7 +28,7 @@ func handleAccounts(c *gin.Context) {
 		return
 	}
 
-	us, err := data.GetUsers(Database)
+	us, err := data.GetUsers(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -48,6 +49,7 @@ func handleAccounts(c *gin.Context) {
 func handleEditAccount(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -71,7 +73,7 @@ func handleEditAccount(c *gin.Context) {
 		return
 	}
 
-	us, err := data.GetUser(Database, uint(uid))
+	us, err := data.GetUser(db, uint(uid))
 	if err != nil {
 		if errors.Is(err, data.ErrUserNotFound) {
 			c.String(http.StatusNotFound, "User Not Found")
@@ -82,16 +84,25 @@ func handleEditAccount(c *gin.Context) {
 		return
 	}
 
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
 	dat := struct {
 		DashboardData
 		TargetUser data.User
-	}{ddat, us}
+		Preps      []data.PrepRoom
+		Prep       prepFilter
+	}{ddat, us, preps, prepFilter{us.PrepRoomID}}
 	c.HTML(http.StatusOK, "accounts-edit.gohtml", dat)
 }
 
 func handleNewAccount(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	role := data.UserTeacher
 	if _, t := c.GetQuery("technician"); t {
@@ -112,7 +123,7 @@ func handleNewAccount(c *gin.Context) {
 		return
 	}
 
-	u, err := data.NewUser(Database, data.UserRole(role))
+	u, err := data.NewUser(db, data.UserRole(role))
 	if err != nil {
 		internalError(c, err)
 		return
@@ -128,6 +139,7 @@ func handleNewAccount(c *gin.Context) {
 func handleAccountSwitch(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -148,7 +160,7 @@ func handleAccountSwitch(c *gin.Context) {
 			return
 		}
 
-		u, err := data.GetUser(Database, uint(uid))
+		u, err := data.GetUser(db, uint(uid))
 		if err != nil {
 			c.Redirect(http.StatusFound, "/account/switch?error")
 			return
@@ -165,7 +177,7 @@ func handleAccountSwitch(c *gin.Context) {
 		return
 	}
 
-	users, err := data.GetUsers(Database)
+	users, err := data.GetUsers(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -211,6 +223,7 @@ func handleChangePassword(c *gin.Context) {
 func handleChangePasswordAttempt(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -239,7 +252,7 @@ func handleChangePasswordAttempt(c *gin.Context) {
 		return
 	}
 
-	if Database.Updates(&ddat.User).Error != nil {
+	if db.Updates(&ddat.User).Error != nil {
 		internalError(c, err)
 		return
 	}
@@ -255,6 +268,7 @@ func handleChangePasswordAttempt(c *gin.Context) {
 func handleAccountTimetable(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -279,7 +293,7 @@ func handleAccountTimetable(c *gin.Context) {
 		return
 	}
 
-	usr, err := data.GetUser(Database, uint(uid))
+	usr, err := data.GetUser(db, uint(uid))
 	if err != nil {
 		c.String(http.StatusNotFound, "User not Found")
 		return
@@ -288,17 +302,18 @@ func handleAccountTimetable(c *gin.Context) {
 	var iusr *isams.User
 	var iusrs []isams.User
 	var isched *isams.UserTimetable
-	if Config.HasISAMS() {
+	is := tenantISAMS(c)
+	if is != nil {
 		if usr.IsamsID != nil {
 			// NOTE: deliberately ignoring error here to use nil as a
 			// sentinel. very naughty!
-			iusr, _ = ISAMS.FindUser(*usr.IsamsID)
+			iusr, _ = is.FindUser(*usr.IsamsID)
 			if iusr != nil {
-				isched = iusr.Timetable(ISAMS)
+				isched = iusr.Timetable(is)
 			}
 		}
 
-		iusrs = ISAMS.Users
+		iusrs = is.Users
 	}
 
 	dat := struct {
@@ -308,7 +323,7 @@ func handleAccountTimetable(c *gin.Context) {
 		ISAMSUser     *isams.User
 		ISAMSUsers    []isams.User
 		ISAMSSchedule *isams.UserTimetable
-	}{ddat, usr, Config.HasISAMS(), iusr, iusrs, isched}
+	}{ddat, usr, is != nil, iusr, iusrs, isched}
 
 	c.HTML(http.StatusOK, "link.gohtml", dat)
 }
@@ -320,6 +335,7 @@ func handleAccountTimetable(c *gin.Context) {
 func handleAccountUnlink(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -344,14 +360,14 @@ func handleAccountUnlink(c *gin.Context) {
 		return
 	}
 
-	usr, err := data.GetUser(Database, uint(uid))
+	usr, err := data.GetUser(db, uint(uid))
 	if err != nil {
 		c.String(http.StatusNotFound, "User not Found")
 		return
 	}
 
 	usr.IsamsID = nil
-	if err := Database.Model(&usr).Where(&usr).Update("isams_id", nil).Error; err != nil {
+	if err := db.Model(&usr).Where(&usr).Update("isams_id", nil).Error; err != nil {
 		internalError(c, err)
 		return
 	}
@@ -366,6 +382,7 @@ func handleAccountUnlink(c *gin.Context) {
 func handleAccountLink(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -390,7 +407,7 @@ func handleAccountLink(c *gin.Context) {
 		return
 	}
 
-	usr, err := data.GetUser(Database, uint(uid))
+	usr, err := data.GetUser(db, uint(uid))
 	if err != nil {
 		c.String(http.StatusNotFound, "User not Found")
 		return
@@ -407,14 +424,14 @@ func handleAccountLink(c *gin.Context) {
 		return
 	}
 
-	iusr, err := ISAMS.FindUser(id)
+	iusr, err := tenantISAMS(c).FindUser(id)
 	if err != nil {
 		c.String(http.StatusNotFound, "No such iSAMS user")
 		return
 	}
 
 	usr.IsamsID = &iusr.UserCode
-	if err = Database.Updates(&usr).Error; err != nil {
+	if err = db.Updates(&usr).Error; err != nil {
 		internalError(c, err)
 		return
 	}
@@ -428,6 +445,7 @@ func handleAccountLink(c *gin.Context) {
 func handleAccountSync(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -452,7 +470,7 @@ func handleAccountSync(c *gin.Context) {
 		return
 	}
 
-	usr, err := data.GetUser(Database, uint(uid))
+	usr, err := data.GetUser(db, uint(uid))
 	if err != nil {
 		c.String(http.StatusNotFound, "User not Found")
 		return
@@ -463,7 +481,7 @@ func handleAccountSync(c *gin.Context) {
 		return
 	}
 
-	iusr, err := ISAMS.FindUser(*usr.IsamsID)
+	iusr, err := tenantISAMS(c).FindUser(*usr.IsamsID)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -479,7 +497,7 @@ func handleAccountSync(c *gin.Context) {
 	usr.Telephone = iusr.SchoolMobileNumber
 
 	log.Println(c.RemoteIP(), "syncs", usr.Username, "with iSAMS", iusr.UserCode, "--", usr)
-	if err := Database.Updates(&usr).Error; err != nil {
+	if err := db.Updates(&usr).Error; err != nil {
 		internalError(c, err)
 		return
 	}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -20,6 +21,7 @@ import (
 func handleActivities(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -27,7 +29,7 @@ func handleActivities(c *gin.Context) {
 		return
 	}
 
-	act, err := data.GetPermanentActivities(Database)
+	act, err := data.GetPermanentActivities(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -49,13 +51,26 @@ func handleActivities(c *gin.Context) {
 
 // activityEditor shows the activity editor HTML page.
 func activityEditor(c *gin.Context, activity data.Activity, s session.Session) {
+	db := tenantDB(c)
 	ddat, err := NewDashboardData(s)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	eq, err := data.GetEquipment(Database)
+	eq, err := data.GetPrepRoomEquipment(db, activity.PrepRoomID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	kits, err := data.GetKits(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	preps, err := data.GetPrepRooms(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -65,15 +80,19 @@ func activityEditor(c *gin.Context, activity data.Activity, s session.Session) {
 		DashboardData
 		Activity  data.Activity
 		Equipment []data.EquipmentItem
-	}{ddat, activity, eq})
+		Kits      []data.Kit
+		Preps     []data.PrepRoom
+		Prep      prepFilter
+	}{ddat, activity, eq, kits, preps, prepFilter{activity.PrepRoomID}})
 }
 
 // handleActivityNew is the handler for "/activity/new".
 func handleActivityNew(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
-	newact, err := data.NewActivity(Database, s.UserID)
+	newact, err := data.NewActivity(db, s.UserID)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -86,6 +105,7 @@ func handleActivityNew(c *gin.Context) {
 func handleActivityEdit(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	actsid := c.Param("activity")
 	actid, err := strconv.ParseUint(actsid, 10, 32)
@@ -94,7 +114,7 @@ func handleActivityEdit(c *gin.Context) {
 		return
 	}
 
-	act, err := data.GetActivity(Database, uint(actid))
+	act, err := data.GetActivity(db, uint(actid))
 	if err != nil {
 		c.String(http.StatusBadRequest, "Activity Not Found")
 		return
@@ -107,6 +127,7 @@ func handleActivityEdit(c *gin.Context) {
 //
 // This is the form handler for the edit form.
 func handleActivityDoEdit(c *gin.Context) {
+	db := tenantDB(c)
 	actsid := c.Param("activity")
 	actid, err := strconv.ParseUint(actsid, 10, 32)
 	if err != nil {
@@ -114,14 +135,14 @@ func handleActivityDoEdit(c *gin.Context) {
 		return
 	}
 
-	act, err := data.GetActivity(Database, uint(actid))
+	act, err := data.GetActivity(db, uint(actid))
 	if err != nil {
 		c.String(http.StatusNotFound, "Activity Not Found")
 		return
 	}
 
 	c.MultipartForm()
-	set, err := NewPostItemInformation(c.Request)
+	set, err := NewPostItemInformation(db, c.Request)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Invalid item set")
 		return
@@ -131,12 +152,24 @@ func handleActivityDoEdit(c *gin.Context) {
 		Title       string `form:"title"`
 		Description string `form:"description"`
 		Category    string `form:"category"`
-	}{act.Title, act.Description, act.Category}
+		Department  string `form:"department"`
+		PrepRoom    string `form:"prep_room"`
+		PrepMinutes uint   `form:"prep_minutes"`
+	}{Title: act.Title, Description: act.Description, Category: act.Category, Department: act.Department, PrepMinutes: uint(act.PrepMinutes)}
 	err = c.Bind(&sub)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Recieved bad data")
 	}
 
+	prep, err := data.ParsePrepRoom(sub.PrepRoom)
+	if err == nil {
+		err = data.CheckPrepRoom(db, prep)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Prep Room")
+		return
+	}
+
 	// Process equipment sets
 	// We later use this to determine what to delete
 	for i := range act.Equipment {
@@ -147,13 +180,23 @@ func handleActivityDoEdit(c *gin.Context) {
 	act.Title = sub.Title
 	act.Description = sub.Description
 	act.Category = sub.Category
-	if err := Database.Updates(&act).Error; err != nil {
+	act.Department = strings.TrimSpace(sub.Department)
+	// Department may be cleared to charge the owner's department instead.
+	if err := db.Updates(&act).Update("department", act.Department).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := db.Model(&act).Update("prep_room_id", prep).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := db.Model(&act).Update("prep_minutes", data.Minutes(sub.PrepMinutes)).Error; err != nil {
 		internalError(c, err)
 		return
 	}
 	for _, eq := range act.Equipment {
 		if eq.Quantity == 0 {
-			if err := Database.Model(&eq).Where(&eq).Delete(&eq).Error; err != nil {
+			if err := db.Model(&eq).Where(&eq).Delete(&eq).Error; err != nil {
 				internalError(c, err)
 				return
 			}
@@ -161,7 +204,7 @@ func handleActivityDoEdit(c *gin.Context) {
 			continue
 		}
 
-		if err := Database.Updates(&eq).Error; err != nil {
+		if err := db.Updates(&eq).Error; err != nil {
 			internalError(c, err)
 			return
 		}
@@ -177,6 +220,7 @@ func handleActivityDoEdit(c *gin.Context) {
 func handleActivityDelete(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -191,7 +235,7 @@ func handleActivityDelete(c *gin.Context) {
 		return
 	}
 
-	act, err := data.GetActivity(Database, uint(actid))
+	act, err := data.GetActivity(db, uint(actid))
 	if err != nil {
 		c.String(http.StatusBadRequest, "Activity Not Found")
 		return
@@ -201,7 +245,7 @@ func handleActivityDelete(c *gin.Context) {
 	_, conf := c.GetQuery("confirm")
 	if conf {
 		// If err isn't nil, GORM will rollback this transaction.
-		err := Database.Transaction(func(tx *gorm.DB) error {
+		err := db.Transaction(func(tx *gorm.DB) error {
 			acts := make([]data.Activity, 0, 10)
 			if err := tx.Where(data.Activity{CopiedFrom: act.ID}).Find(&acts).Error; err != nil {
 				internalError(c, err)
@@ -246,7 +290,7 @@ func handleActivityDelete(c *gin.Context) {
 	}
 
 	numbook := int64(0)
-	if err := Database.Model(&data.Activity{}).Where(&data.Activity{CopiedFrom: act.ID}).Count(&numbook).Error; err != nil {
+	if err := db.Model(&data.Activity{}).Where(&data.Activity{CopiedFrom: act.ID}).Count(&numbook).Error; err != nil {
 		internalError(c, err)
 		return
 	}
@@ -5,12 +5,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/ejv2/prepper/data"
 	"github.com/ejv2/prepper/logging"
 	"github.com/gin-gonic/gin"
 )
 
+// auditLimit is the maximum number of audit entries shown at once.
+const auditLimit = 200
+
 // handleAdminRoot is the handler for "/admin/".
 //
 // Displays a simple UI for selecting the admin function desired.
@@ -58,3 +63,75 @@ func handleAdminRunMaint(c *gin.Context) {
 	MSched.Now()
 	c.Redirect(http.StatusFound, "/admin/")
 }
+
+// handleAdminAudit is the handler for "/admin/audit".
+//
+// Shows the inventory audit log, optionally filtered by the "user" and "item"
+// IDs and the "from" and "to" dates (inclusive) given as query parameters.
+func handleAdminAudit(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	frm := struct {
+		User uint   `form:"user"`
+		Item uint   `form:"item"`
+		From string `form:"from"`
+		To   string `form:"to"`
+	}{}
+	if err := c.BindQuery(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Filter")
+		return
+	}
+
+	f := data.AuditFilter{UserID: frm.User, ItemID: frm.Item, Limit: auditLimit}
+	if frm.From != "" {
+		f.From, err = time.ParseInLocation(dateFormat, frm.From, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+			return
+		}
+	}
+	if frm.To != "" {
+		f.To, err = time.ParseInLocation(dateFormat, frm.To, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+			return
+		}
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+
+	audit, err := data.GetItemAudits(db, f)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	usrs, err := data.GetUsers(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Audit   []data.ItemAudit
+		Users   []data.User
+		UserID  uint
+		ItemID  string
+		From    string
+		To      string
+		Limited bool
+	}{ddat, audit, usrs, frm.User, "", frm.From, frm.To, len(audit) == auditLimit}
+	if frm.Item != 0 {
+		dat.ItemID = strconv.FormatUint(uint64(frm.Item), 10)
+	}
+
+	c.HTML(http.StatusOK, "admin-audit.gohtml", dat)
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ejv2/prepper/conf"
 	"github.com/ejv2/prepper/data"
 	"github.com/ejv2/prepper/notifications"
 	"github.com/gin-gonic/gin"
@@ -34,6 +37,7 @@ func handleAPIRoot(c *gin.Context) {
 func handleAPIEditUser(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	if !s.SignedIn {
 		c.JSON(http.StatusForbidden, gin.H{
@@ -72,16 +76,17 @@ func handleAPIEditUser(c *gin.Context) {
 
 	u := struct {
 		data.User
-		PostPassword string `json:"password"`
+		PostPassword string  `json:"password"`
+		PostPrepRoom *string `json:"prep_room"`
 	}{
 		data.User{Model: &gorm.Model{ID: uint(uid)}},
-		"",
+		"", nil,
 	}
 
-	if err = Database.Find(&u.User).First(&u.User).Error; err != nil {
+	if err = db.Find(&u.User).First(&u.User).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database Error",
-			"message": "Internal Database Error" + err.Error(),
+			"error":   "db Error",
+			"message": "Internal db Error" + err.Error(),
 		})
 		return
 	}
@@ -105,10 +110,34 @@ func handleAPIEditUser(c *gin.Context) {
 		}
 	}
 
-	if err = Database.Updates(&u.User).Error; err != nil {
+	// Prep rooms are sent by ID as given in the form, and may be cleared.
+	if u.PostPrepRoom != nil {
+		prep, err := data.ParsePrepRoom(*u.PostPrepRoom)
+		if err == nil {
+			err = data.CheckPrepRoom(db, prep)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Prep Room",
+				"message": err.Error(),
+			})
+			return
+		}
+		u.PrepRoomID = prep
+		if err = db.Model(&u.User).Update("prep_room_id", prep).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "db Server Error",
+				"message": "db SQL Error: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	u.Department = strings.TrimSpace(u.Department)
+	if err = db.Updates(&u.User).Update("department", u.Department).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database Server Error",
-			"message": "Database SQL Error: " + err.Error(),
+			"error":   "db Server Error",
+			"message": "db SQL Error: " + err.Error(),
 		})
 		return
 	}
@@ -122,6 +151,7 @@ func handleAPIEditUser(c *gin.Context) {
 func handleAPICreateItem(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	us, err := data.GetUser(Database, s.UserID)
 	if err != nil {
@@ -158,10 +188,34 @@ func handleAPICreateItem(c *gin.Context) {
 		return
 	}
 
-	if err := Database.Create(&dat).Error; err != nil {
+	if !dat.ValidSignalWord() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Item Specification",
+			"message": "Signal word must be blank, \"Warning\" or \"Danger\"",
+		})
+		return
+	}
+
+	if !dat.Category.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Item Specification",
+			"message": "Unknown item category",
+		})
+		return
+	}
+	dat.Tags = data.NormaliseTags(dat.Tags)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&dat).Error; err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, us.ID, data.AuditCreate, "", data.EquipmentItem{}, dat)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database Server Error",
-			"message": "Database SQL Error: " + err.Error(),
+			"error":   "db Server Error",
+			"message": "db SQL Error: " + err.Error(),
 		})
 		return
 	}
@@ -186,6 +240,7 @@ func handleAPIBadEditItem(c *gin.Context) {
 func handleAPIEditItem(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	us, err := data.GetUser(Database, s.UserID)
 	if err != nil {
@@ -215,7 +270,8 @@ func handleAPIEditItem(c *gin.Context) {
 		return
 	}
 
-	i, err := data.GetEquipmentItem(Database, id)
+	i, err := data.GetEquipmentItem(db, id)
+	old := i
 	oldid := i.ID
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -242,19 +298,59 @@ func handleAPIEditItem(c *gin.Context) {
 		return
 	}
 
+	if !i.ValidSignalWord() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Item Specification",
+			"message": "Signal word must be blank, \"Warning\" or \"Danger\"",
+		})
+		return
+	}
+
+	if !i.Category.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Item Specification",
+			"message": "Unknown item category",
+		})
+		return
+	}
+	i.Tags = data.NormaliseTags(i.Tags)
+
 	log.Printf("user %s (%d) updates item ID %d: new record: %v", us.DisplayName(), us.ID, id, i)
 
-	err = Database.Updates(&i).
-		Update("hazard_voltage", i.HazardVoltage).
-		Update("hazard_lazer", i.HazardLazer).
-		Update("hazard_toxic", i.HazardToxic).
-		Update("hazard_misc", i.HazardMisc).
-		Update("available", i.Available).Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Updates(&i).
+			Update("ghs_explosive", i.GHSExplosive).
+			Update("ghs_flammable", i.GHSFlammable).
+			Update("ghs_oxidising", i.GHSOxidising).
+			Update("ghs_gas", i.GHSGas).
+			Update("ghs_corrosive", i.GHSCorrosive).
+			Update("ghs_toxic", i.GHSToxic).
+			Update("ghs_harmful", i.GHSHarmful).
+			Update("ghs_health", i.GHSHealth).
+			Update("ghs_environment", i.GHSEnvironment).
+			Update("signal_word", i.SignalWord).
+			Update("hazard_statements", i.HazardStatements).
+			Update("precaution_statements", i.PrecautionStatements).
+			Update("service_interval", i.ServiceInterval).
+			Update("category", i.Category).
+			Update("tags", i.Tags).
+			Update("unit_cost", i.UnitCost).
+			Update("available", i.Available).Error
+		if err != nil {
+			return err
+		}
 
+		i, err = data.GetEquipmentItem(tx, id)
+		if err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, us.ID, data.AuditUpdate, "", old, i)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database Server Error",
-			"message": "Database SQL Error: " + err.Error(),
+			"error":   "db Server Error",
+			"message": "db SQL Error: " + err.Error(),
 		})
 		return
 	}
@@ -286,12 +382,18 @@ func handleAPIDashboard(c *gin.Context) {
 		a = append(a, formattedNotification{n, n.Time.Format(time.Kitchen)})
 	}
 
+	_, week, _ := timetableWeek(c, time.Now())
 	c.JSON(http.StatusOK, gin.H{
 		"time":          time.Now().Format(time.Kitchen),
+		"week":          week,
 		"notifications": a,
 	})
 }
 
+// handleAPIPeriod is the handler for "/api/period".
+//
+// Returns the period containing "time" on "date", or today if absent, using
+// the timetable layout for that day.
 func handleAPIPeriod(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
@@ -310,6 +412,7 @@ func handleAPIPeriod(c *gin.Context) {
 			"error":   "Bad Request",
 			"message": "Need a time parameter",
 		})
+		return
 	}
 
 	t, err := time.Parse("15:04", ts)
@@ -318,20 +421,127 @@ func handleAPIPeriod(c *gin.Context) {
 			"error":   "Bad Request",
 			"message": "Bad time format: " + err.Error(),
 		})
+		return
+	}
+
+	date, ok := apiDate(c)
+	if !ok {
+		return
 	}
+	t = date.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
 
-	p := Config.TimetableLayout.FindPeriod(t)
+	p := tenantConfig(c).FindPeriod(t)
 	if p == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "Not Found",
 			"message": "No matching period",
 		})
+		return
 	}
 
 	c.JSON(http.StatusOK, p)
 }
 
+// handleAPIPeriods is the handler for "/api/periods".
+//
+// Returns every period of the timetable layout used on "date", or today if
+// absent.
+func handleAPIPeriods(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	if !s.SignedIn {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Access Denied",
+			"message": "Please authenticate first",
+		})
+		return
+	}
+
+	date, ok := apiDate(c)
+	if !ok {
+		return
+	}
+
+	layout := tenantConfig(c).LayoutFor(date)
+	periods := make([]*conf.Period, 0, len(layout))
+	for _, p := range layout {
+		if p != nil {
+			periods = append(periods, p)
+		}
+	}
+
+	c.JSON(http.StatusOK, periods)
+}
+
+// apiDate parses the "date" query parameter of an API request, returning
+// midnight at the start of today if absent. If the date is invalid, a response
+// is written and false is returned.
+func apiDate(c *gin.Context) (time.Time, bool) {
+	y, m, d := time.Now().Date()
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if ds, ok := c.GetQuery("date"); ok {
+		var err error
+		date, err = time.ParseInLocation(dateFormat, ds, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": "Bad date format: " + err.Error(),
+			})
+			return date, false
+		}
+	}
+
+	return date, true
+}
+
+// handleAPIWeek is the handler for "/api/week".
+//
+// Returns the week of the timetable rotation containing "date", or today if
+// absent.
+func handleAPIWeek(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	if !s.SignedIn {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Access Denied",
+			"message": "Please authenticate first",
+		})
+		return
+	}
+
+	date, ok := apiDate(c)
+	if !ok {
+		return
+	}
+
+	i, name, ok := timetableWeek(c, date)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "No week rotation configured",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"index":           i,
+		"name":            name,
+		"week_commencing": weekCommencing(date).Format(dateFormat),
+	})
+}
+
+// substituteReference is an item which may be used in place of a clashing
+// item, with the quantity free over the requested period.
+type substituteReference struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Available int    `json:"available"`
+}
+
 type clashReference struct {
+	EquipmentID     uint   `json:"equipment_id"`
 	EquipmentName   string `json:"equipment_name"`
 	TotalQuantity   uint   `json:"total_quantity"`
 	NetQuantity     int    `json:"net_quantity"`
@@ -342,15 +552,15 @@ type clashReference struct {
 	BookingActivity string `json:"booking_activity"`
 	BookingStarts   string `json:"booking_starts"`
 	BookingEnds     string `json:"booking_ends"`
-}
 
-// handleAPIClashes is the handler for "/api/clashes".
-//
-// Returns a JSON array of all the clashes which are detected for the two query
-// parameter datetimes.
-func handleAPIClashes(c *gin.Context) {
-	clashes := make([]clashReference, 0, 5)
+	Substitutes []substituteReference `json:"substitutes"`
+}
 
+// clashPeriod parses the period of a prospective booking from the query
+// parameters of a clash check. Dates are given either manually as "date", or
+// as "week_commencing" and "day" from the timetable. If the period cannot be
+// parsed, a response is written and false is returned.
+func clashPeriod(c *gin.Context) (time.Time, time.Time, bool) {
 	var err error
 	var date time.Time
 
@@ -363,7 +573,7 @@ func handleAPIClashes(c *gin.Context) {
 				"error":   "Bad Date Format",
 				"message": err.Error(),
 			})
-			return
+			return date, date, false
 		}
 	} else {
 		wcp := c.Query("week_commencing")
@@ -373,7 +583,7 @@ func handleAPIClashes(c *gin.Context) {
 				"error":   "Bad date format",
 				"message": err.Error(),
 			})
-			return
+			return date, date, false
 		}
 		wday := parseDay(c.Query("day"))
 
@@ -387,7 +597,7 @@ func handleAPIClashes(c *gin.Context) {
 			"error":   "Bad start time format",
 			"message": err.Error(),
 		})
-		return
+		return date, date, false
 	}
 
 	etime, err := time.Parse(timeFormat, setime)
@@ -396,7 +606,7 @@ func handleAPIClashes(c *gin.Context) {
 			"error":   "Bad end time format",
 			"message": err.Error(),
 		})
-		return
+		return date, date, false
 	}
 
 	// Handle zone offsets as HTML does not supply them
@@ -408,7 +618,23 @@ func handleAPIClashes(c *gin.Context) {
 	start := date.Add(time.Hour * time.Duration(stime.Hour())).Add(time.Minute * time.Duration(stime.Minute()))
 	end := date.Add(time.Hour * time.Duration(etime.Hour())).Add(time.Minute * time.Duration(etime.Minute()))
 
-	set, err := NewItemInformation(c.Request)
+	return start, end, true
+}
+
+// handleAPIClashes is the handler for "/api/clashes".
+//
+// Returns a JSON array of all the clashes which are detected for the two query
+// parameter datetimes.
+func handleAPIClashes(c *gin.Context) {
+	db := tenantDB(c)
+	clashes := make([]clashReference, 0, 5)
+
+	start, end, ok := clashPeriod(c)
+	if !ok {
+		return
+	}
+
+	set, err := NewItemInformation(db, c.Request)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Internal Error",
@@ -417,8 +643,10 @@ func handleAPIClashes(c *gin.Context) {
 		return
 	}
 
-	for _, i := range set {
-		i.Item, err = data.GetEquipmentItem(Database, i.Item.ID)
+	// Kits have already been expanded into their components, which may
+	// overlap with other items or kits requested.
+	for _, i := range set.Totals() {
+		i.Item, err = data.GetEquipmentItem(db, i.Item.ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Internal Error",
@@ -451,17 +679,33 @@ func handleAPIClashes(c *gin.Context) {
 				return
 			}
 
+			avail, err := data.AvailableSubstitutes(db, i.Item.ID, start, end)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Internal Error",
+					"message": "SQL Error" + err.Error(),
+				})
+				return
+			}
+
+			subs := make([]substituteReference, len(avail))
+			for j, a := range avail {
+				subs[j] = substituteReference{a.ID, a.Name, a.Available}
+			}
+
 			for _, b := range bks {
 				cl := clashReference{
+					EquipmentID:     i.Item.ID,
 					EquipmentName:   i.Item.Name,
 					TotalQuantity:   i.Item.Quantity,
 					NetQuantity:     qty,
 					YouQuantity:     i.Quantity,
 					BookingID:       b.ID,
 					BookingUser:     b.Owner.Username,
-					BookingActivity: b.Activity.Parent(Database).Title,
+					BookingActivity: b.Activity.Parent(db).Title,
 					BookingStarts:   b.StartTime.Format(time.TimeOnly),
 					BookingEnds:     b.EndTime.Format(time.TimeOnly),
+					Substitutes:     subs,
 				}
 
 				for _, eq := range b.Activity.Equipment {
@@ -477,3 +721,67 @@ func handleAPIClashes(c *gin.Context) {
 
 	c.JSON(http.StatusOK, clashes)
 }
+
+// roomClashReference is another booking in the same room at the same time.
+type roomClashReference struct {
+	Location        string `json:"location"`
+	BookingID       uint   `json:"booking_id"`
+	BookingUser     string `json:"booking_user"`
+	BookingActivity string `json:"booking_activity"`
+	BookingStarts   string `json:"booking_starts"`
+	BookingEnds     string `json:"booking_ends"`
+}
+
+// handleAPIRoomClashes is the handler for "/api/rooms/clashes".
+//
+// Returns a JSON array of the bookings which are already in the room given as
+// "location" over the period given as for "/api/clashes". If the location is
+// not a known room, a bad request is returned.
+func handleAPIRoomClashes(c *gin.Context) {
+	db := tenantDB(c)
+	clashes := make([]roomClashReference, 0, 2)
+
+	start, end, ok := clashPeriod(c)
+	if !ok {
+		return
+	}
+
+	location, err := data.ResolveLocation(db, c.Query("location"))
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownRoom) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Unknown Room",
+				"message": "\"" + location + "\" is not a known room",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Error",
+			"message": "SQL Error" + err.Error(),
+		})
+		return
+	}
+
+	bks, err := data.RoomClashes(db, data.Booking{Location: location, StartTime: start, EndTime: end})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Error",
+			"message": "SQL Error" + err.Error(),
+		})
+		return
+	}
+
+	for _, b := range bks {
+		clashes = append(clashes, roomClashReference{
+			Location:        b.Location,
+			BookingID:       b.ID,
+			BookingUser:     b.Owner.Username,
+			BookingActivity: b.Activity.Parent(db).Title,
+			BookingStarts:   b.StartTime.Format(time.TimeOnly),
+			BookingEnds:     b.EndTime.Format(time.TimeOnly),
+		})
+	}
+
+	c.JSON(http.StatusOK, clashes)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/notifications"
+)
+
+// workloadRow is the bookings assigned to one technician on each day of a
+// workload view.
+type workloadRow struct {
+	Name  string
+	Days  [][]data.Booking
+	Total int
+}
+
+// workload is the bookings of a week laid out by assigned technician and day.
+type workload struct {
+	Start time.Time
+	Days  []time.Time
+	Rows  []workloadRow
+}
+
+// Previous returns the start of the previous week.
+func (w workload) Previous() time.Time {
+	return w.Start.AddDate(0, 0, -7)
+}
+
+// Next returns the start of the next week.
+func (w workload) Next() time.Time {
+	return w.Start.AddDate(0, 0, 7)
+}
+
+// newWorkload lays out the bookings for the week containing date by the
+// technician assigned to each and the day on which it falls. Every technician
+// of the prep room is shown, along with any unassigned bookings.
+func newWorkload(db *gorm.DB, prep *uint, date time.Time) (workload, error) {
+	y, m, d := date.Date()
+	start := weekCommencing(time.Date(y, m, d, 0, 0, 0, 0, time.Local))
+	end := start.AddDate(0, 0, 7)
+	wl := workload{Start: start}
+
+	bks, err := data.GetBookingsRange(db, start.UTC(), end.UTC())
+	if err != nil {
+		return wl, err
+	}
+	bks = slices.DeleteFunc(bks, func(b data.Booking) bool {
+		return !b.Status.Committed() || (prep != nil && b.PrepRoomID != nil && *b.PrepRoomID != *prep)
+	})
+
+	techs, err := data.PrepRoomTechnicians(db, prep)
+	if err != nil {
+		return wl, err
+	}
+
+	wl.Days = weekDays(start, bks)
+
+	idx := make(map[uint]int, len(techs)+1)
+	for _, t := range techs {
+		idx[t.ID] = len(wl.Rows)
+		wl.Rows = append(wl.Rows, workloadRow{Name: t.DisplayName(), Days: make([][]data.Booking, len(wl.Days))})
+	}
+
+	unassigned := workloadRow{Name: "Unassigned", Days: make([][]data.Booking, len(wl.Days))}
+	for _, b := range bks {
+		row := &unassigned
+		if b.AssigneeID != nil {
+			i, ok := idx[*b.AssigneeID]
+			if !ok {
+				// Assigned to somebody outside of this prep room.
+				i = len(wl.Rows)
+				idx[*b.AssigneeID] = i
+				wl.Rows = append(wl.Rows, workloadRow{Name: b.AssigneeName(), Days: make([][]data.Booking, len(wl.Days))})
+			}
+			row = &wl.Rows[i]
+		}
+
+		for i, day := range wl.Days {
+			if sameDay(b.StartTime.In(time.Local), day) {
+				row.Days[i] = append(row.Days[i], b)
+				row.Total++
+			}
+		}
+	}
+	wl.Rows = append(wl.Rows, unassigned)
+
+	return wl, nil
+}
+
+// weekDays returns the days of the week commencing start. Weekends are only of
+// interest if something is booked, so are left out otherwise.
+func weekDays(start time.Time, bks []data.Booking) []time.Time {
+	days := make([]time.Time, 0, 7)
+	for i := 0; i < 7; i++ {
+		day := start.AddDate(0, 0, i)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			booked := false
+			for _, b := range bks {
+				if sameDay(b.StartTime.In(time.Local), day) {
+					booked = true
+				}
+			}
+			if !booked {
+				continue
+			}
+		}
+
+		days = append(days, day)
+	}
+
+	return days
+}
+
+// sameDay returns true if both times fall on the same calendar date.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// autoAssign assigns a new booking to a technician if auto assignment is
+// enabled, letting them know that it is theirs. Failure to assign is not
+// fatal, as the booking may still be claimed by hand.
+func autoAssign(c *gin.Context, bk data.Booking) {
+	if !tenantConfig(c).AutoAssign {
+		return
+	}
+
+	bk, err := data.AutoAssign(tenantDB(c), bk)
+	if err != nil {
+		log.Println("auto assign:", err)
+		return
+	}
+
+	Notifications.PushUser(*bk.AssigneeID, notifications.Notification{
+		Title:  "Booking Assigned",
+		Body:   fmt.Sprint("You have been assigned the booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), "."),
+		Action: "/todo/",
+		Type:   notifications.TypeImportant,
+		Time:   time.Now(),
+	})
+}
+
+// assignFromParam assigns the booking given by the "id" URI parameter to the
+// given technician, or unassigns it if tech is nil. If there was an error, a
+// response is written and false is returned.
+func assignFromParam(c *gin.Context, tech *uint) (data.Booking, bool) {
+	db := tenantDB(c)
+	lid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad ID Format: %s", err)
+		return data.Booking{}, false
+	}
+
+	bk, err := data.AssignBooking(db, uint(lid), tech)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoSuchBooking):
+			c.String(http.StatusNotFound, "Booking Not Found")
+		case errors.Is(err, data.ErrUserNotFound), errors.Is(err, data.ErrNotTechnician):
+			c.String(http.StatusBadRequest, "Bad Technician")
+		default:
+			internalError(c, err)
+		}
+		return bk, false
+	}
+
+	return bk, true
+}
+
+// handleTodoClaim is the handler for "/todo/claim/[ID]".
+//
+// Assigns the booking to the current user.
+func handleTodoClaim(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	if _, ok := assignFromParam(c, &s.UserID); ok {
+		c.Redirect(http.StatusFound, "/todo/")
+	}
+}
+
+// handleTodoAssign is the handler for POST "/todo/assign/[ID]".
+//
+// Assigns the booking to the technician given as "technician" in the form, or
+// unassigns it if blank. The technician is notified unless they assigned it
+// themselves.
+func handleTodoAssign(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	usr, err := data.GetUser(Database, s.UserID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	var tech *uint
+	if st := c.PostForm("technician"); st != "" {
+		id, err := strconv.ParseUint(st, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Technician")
+			return
+		}
+
+		v := uint(id)
+		tech = &v
+	}
+
+	bk, ok := assignFromParam(c, tech)
+	if !ok {
+		return
+	}
+
+	if tech != nil && *tech != s.UserID {
+		Notifications.PushUser(*tech, notifications.Notification{
+			Title:  "Booking Assigned",
+			Body:   fmt.Sprint(usr.DisplayName(), " has assigned you the booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), "."),
+			Action: "/todo/",
+			Type:   notifications.TypeImportant,
+			Time:   time.Now(),
+		})
+	}
+
+	c.Redirect(http.StatusFound, "/todo/")
+}
+
+// handleWorkload is the handler for "/todo/workload".
+//
+// Shows the bookings assigned to each technician on each day of the week
+// containing "date", for the prep room given as "prep".
+func handleWorkload(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	prep, ok := prepRoomFilter(c, ddat.User)
+	if !ok {
+		return
+	}
+
+	date := time.Now()
+	if sd := c.Query("date"); sd != "" {
+		date, err = time.ParseInLocation(dateFormat, sd, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date")
+			return
+		}
+	}
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	wl, err := newWorkload(db, prep.ID, date)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "workload.gohtml", struct {
+		DashboardData
+		Workload workload
+		Prep     prepFilter
+		Preps    []data.PrepRoom
+	}{ddat, wl, prep, preps})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// batchExpiryWarning is how long before its expiry date technicians begin to
+// be warned about a batch.
+const batchExpiryWarning = 30 * 24 * time.Hour
+
+// batchForm is the form submitted when creating or editing a batch.
+type batchForm struct {
+	LotNumber string  `form:"lot_number"`
+	Supplier  string  `form:"supplier"`
+	Received  string  `form:"received"`
+	Expiry    string  `form:"expiry" binding:"required"`
+	Remaining float64 `form:"remaining"`
+	Unit      string  `form:"unit"`
+}
+
+// apply copies the form values into b, returning an error if any dates are
+// malformed.
+func (f batchForm) apply(b *data.ChemicalBatch) error {
+	exp, err := time.Parse(dateFormat, f.Expiry)
+	if err != nil {
+		return fmt.Errorf("bad expiry date: %w", err)
+	}
+
+	rec := time.Now()
+	if f.Received != "" {
+		rec, err = time.Parse(dateFormat, f.Received)
+		if err != nil {
+			return fmt.Errorf("bad received date: %w", err)
+		}
+	}
+
+	b.LotNumber = f.LotNumber
+	b.Supplier = f.Supplier
+	b.Received = rec.UTC()
+	b.Expiry = exp.UTC()
+	b.Remaining = f.Remaining
+	b.Unit = f.Unit
+
+	return nil
+}
+
+// batchFromParam looks up the batch given by the "id" URI parameter, writing
+// an error response and returning false if this is not possible.
+func batchFromParam(c *gin.Context) (data.ChemicalBatch, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Batch ID")
+		return data.ChemicalBatch{}, false
+	}
+
+	b, err := data.GetBatch(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchBatch) {
+			c.String(http.StatusNotFound, "Batch Not Found")
+			return b, false
+		}
+
+		internalError(c, err)
+		return b, false
+	}
+
+	return b, true
+}
+
+// handleBatchNew is the handler for POST "/inventory/item/[ID]/batch".
+//
+// Records a newly received batch against the item.
+func handleBatchNew(c *gin.Context) {
+	db := tenantDB(c)
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := batchForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	b := data.ChemicalBatch{ItemID: item.ID}
+	if err := frm.apply(&b); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs: %s", err)
+		return
+	}
+
+	if err := db.Create(&b).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#batches"))
+}
+
+// handleBatchEdit is the handler for POST "/inventory/batch/[ID]/edit".
+func handleBatchEdit(c *gin.Context) {
+	db := tenantDB(c)
+	b, ok := batchFromParam(c)
+	if !ok {
+		return
+	}
+
+	frm := batchForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	if err := frm.apply(&b); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs: %s", err)
+		return
+	}
+
+	err := db.Model(&b).Select("lot_number", "supplier", "received", "expiry", "remaining", "unit").
+		Updates(&b).Error
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", b.ItemID, "#batches"))
+}
+
+// handleBatchOpen is the handler for "/inventory/batch/[ID]/open".
+//
+// Marks the batch as opened as of now.
+func handleBatchOpen(c *gin.Context) {
+	db := tenantDB(c)
+	b, ok := batchFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := b.Open(db); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", b.ItemID, "#batches"))
+}
+
+// handleBatchDelete is the handler for "/inventory/batch/[ID]/delete".
+func handleBatchDelete(c *gin.Context) {
+	db := tenantDB(c)
+	b, ok := batchFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(&b).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", b.ItemID, "#batches"))
+}
+
+// usableBatches returns a map between item IDs and their unexpired batches for
+// every item used in the given bookings. Items without any usable batches are
+// not present in the map.
+func usableBatches(db *gorm.DB, bks []data.Booking) (map[uint][]data.ChemicalBatch, error) {
+	m := make(map[uint][]data.ChemicalBatch)
+	seen := make(map[uint]bool)
+
+	for _, bk := range bks {
+		for _, eq := range bk.Activity.Equipment {
+			if seen[eq.ItemID] {
+				continue
+			}
+			seen[eq.ItemID] = true
+
+			bt, err := data.GetItemBatches(db, eq.ItemID)
+			if err != nil {
+				return m, err
+			}
+
+			for _, b := range bt {
+				if !b.Expired() {
+					m[eq.ItemID] = append(m[eq.ItemID], b)
+				}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// handleSetBatches records the batches chosen for each equipment set of the
+// booking given as a URI parameter. Batches are passed as query parameters of
+// the form "batch_[SET ID]=[BATCH ID]". Changes are made through db. If there
+// was an error, a response is written and false is returned, else true.
+func handleSetBatches(c *gin.Context, db *gorm.DB) bool {
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad ID Format: %s", err)
+		return false
+	}
+
+	bk, err := data.GetBooking(db, uint(lid))
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+
+	for _, eq := range bk.Activity.Equipment {
+		sbid := c.Query(fmt.Sprint("batch_", eq.ID))
+		if sbid == "" {
+			continue
+		}
+
+		bid, err := strconv.ParseUint(sbid, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Batch ID Format: %s", err)
+			return false
+		}
+
+		b, err := data.GetBatch(db, uint(bid))
+		if err != nil || b.ItemID != eq.ItemID {
+			c.String(http.StatusBadRequest, "Batch %d is not a batch of %s", bid, eq.Item.Name)
+			return false
+		}
+
+		if err := db.Model(&eq).Update("batch_id", b.ID).Error; err != nil {
+			internalError(c, err)
+			return false
+		}
+
+		if err := b.Open(db); err != nil {
+			internalError(c, err)
+			return false
+		}
+	}
+
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,8 @@ import (
 var (
 	matchItems = regexp.MustCompile("^qty_.*")
 	matchExtra = regexp.MustCompile("^eqty_.*")
+	matchKits  = regexp.MustCompile("^kit_.*")
+	matchEKits = regexp.MustCompile("^ekit_.*")
 )
 
 // Date and time formats for parsing HTML datetime submissions.
@@ -36,31 +39,40 @@ const (
 // quaranteed to be filled in.
 type ItemInformation []data.EquipmentSet
 
+// parseQuantityParam parses the ID and quantity from a parameter of the form
+// "[PREFIX]_[ID]=[QUANTITY]".
+func parseQuantityParam(qs url.Values, param string) (id, qty uint, err error) {
+	segs := strings.Split(param, "_")
+	if len(segs) != 2 {
+		return 0, 0, fmt.Errorf("parse ID: invalid syntax")
+	}
+
+	lid, err := strconv.ParseUint(segs[1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse ID: %w", err)
+	}
+
+	lqty, err := strconv.ParseUint(qs.Get(param), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse quantity: %w", err)
+	}
+
+	return uint(lid), uint(lqty), nil
+}
+
 // itemInfoFromValues parses a set of url.Values into a set of item information
-// values.
-func itemInfoFromValues(qs url.Values) (ItemInformation, error) {
+// values. Kits, given as "kit_[ID]" or "ekit_[ID]" for extras, are expanded
+// into their components, which are added to any matching items.
+func itemInfoFromValues(db *gorm.DB, qs url.Values) (ItemInformation, error) {
 	inf := make(ItemInformation, 0, len(qs))
 	for param := range qs {
 		if matchItems.MatchString(param) || matchExtra.MatchString(param) {
-			segs := strings.Split(param, "_")
-			if len(segs) != 2 {
-				return inf, fmt.Errorf("parse item information: parse ID: invalid syntax")
-			}
-
-			sid := segs[1]
-			lid, err := strconv.ParseUint(sid, 10, 32)
-			id := uint(lid)
+			id, qty, err := parseQuantityParam(qs, param)
 			if err != nil {
-				return inf, fmt.Errorf("parse item information: parse ID: %w", err)
+				return inf, fmt.Errorf("parse item information: %w", err)
 			}
 
-			lqty, err := strconv.ParseUint(qs.Get(param), 10, 32)
-			qty := uint(lqty)
-			if err != nil {
-				return inf, fmt.Errorf("parse item information: parse quantity: %w", err)
-			}
-
-			inf = append(inf, data.EquipmentSet{
+			inf.add(data.EquipmentSet{
 				Quantity:  qty,
 				Important: !matchExtra.MatchString(param),
 				ItemID:    id,
@@ -71,20 +83,67 @@ func itemInfoFromValues(qs url.Values) (ItemInformation, error) {
 		}
 	}
 
+	for param := range qs {
+		if matchKits.MatchString(param) || matchEKits.MatchString(param) {
+			id, qty, err := parseQuantityParam(qs, param)
+			if err != nil {
+				return inf, fmt.Errorf("parse item information: kit: %w", err)
+			}
+			if qty == 0 {
+				continue
+			}
+
+			kit, err := data.GetKit(db, id)
+			if err != nil {
+				return inf, fmt.Errorf("parse item information: %w", err)
+			}
+
+			for _, s := range kit.Sets(qty, !matchEKits.MatchString(param)) {
+				s.Item = data.EquipmentItem{Model: &gorm.Model{ID: s.ItemID}}
+				inf.add(s)
+			}
+		}
+	}
+
 	return inf, nil
 }
 
+// add adds an equipment set to the information, adding to the quantity of
+// any existing set for the same item and importance.
+func (i *ItemInformation) add(set data.EquipmentSet) {
+	for j, s := range *i {
+		if s.ItemID == set.ItemID && s.Important == set.Important {
+			(*i)[j].Quantity += set.Quantity
+			return
+		}
+	}
+
+	*i = append(*i, set)
+}
+
+// Totals returns the total quantity of each item requested, regardless of
+// importance.
+func (i ItemInformation) Totals() ItemInformation {
+	tot := make(ItemInformation, 0, len(i))
+	for _, s := range i {
+		s.Important = true
+		tot.add(s)
+	}
+
+	return tot
+}
+
 // NewItemInformation parses a new ItemInformation set from a request's query
-// parameters.
-func NewItemInformation(r *http.Request) (ItemInformation, error) {
-	return itemInfoFromValues(r.URL.Query())
+// parameters, expanding kits from db.
+func NewItemInformation(db *gorm.DB, r *http.Request) (ItemInformation, error) {
+	return itemInfoFromValues(db, r.URL.Query())
 }
 
 // NewPostItemInformation parses a new ItemInformation from a request's post
 // parameters. You must have already  parsed the request body (i.e via
 // c.MultipartForm) before using this function.
-func NewPostItemInformation(r *http.Request) (ItemInformation, error) {
-	return itemInfoFromValues(r.PostForm)
+func NewPostItemInformation(db *gorm.DB, r *http.Request) (ItemInformation, error) {
+	return itemInfoFromValues(db, r.PostForm)
 }
 
 // Copy copies any contained items into the destination activity, overwriting
@@ -183,6 +242,7 @@ func weekCommencing(target time.Time) time.Time {
 func handleBook(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -190,7 +250,7 @@ func handleBook(c *gin.Context) {
 		return
 	}
 
-	act, err := data.GetPermanentActivities(Database)
+	act, err := data.GetPermanentActivities(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -211,6 +271,7 @@ func handleBook(c *gin.Context) {
 func handleBookMy(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -218,7 +279,7 @@ func handleBookMy(c *gin.Context) {
 		return
 	}
 
-	bks, err := data.GetPersonalBookings(Database, s.UserID)
+	bks, err := data.GetPersonalBookings(db, s.UserID)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -226,8 +287,9 @@ func handleBookMy(c *gin.Context) {
 
 	dat := struct {
 		DashboardData
-		Bookings []data.Booking
-	}{ddat, bks}
+		Bookings    []data.Booking
+		AmendWindow time.Duration
+	}{ddat, bks, amendWindow(c, ddat.User)}
 
 	c.HTML(http.StatusOK, "my-bookings.gohtml", dat)
 }
@@ -238,6 +300,7 @@ func handleBookMy(c *gin.Context) {
 func handleBookActivity(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -253,13 +316,24 @@ func handleBookActivity(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	act, err := data.GetActivity(Database, id)
+	act, err := data.GetActivity(db, id)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	items, err := data.GetEquipment(Database)
+	// Only the stock of the prep room preparing the booking may be booked.
+	prep := act.PrepRoomID
+	if prep == nil {
+		prep = ddat.User.PrepRoomID
+	}
+	items, err := data.GetPrepRoomEquipment(db, prep)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	kits, err := data.GetKits(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -269,7 +343,8 @@ func handleBookActivity(c *gin.Context) {
 		DashboardData
 		Activity  data.Activity
 		Equipment []data.EquipmentItem
-	}{ddat, act, items}
+		Kits      []data.Kit
+	}{ddat, act, items, kits}
 	c.HTML(http.StatusOK, "book-activity.gohtml", dat)
 }
 
@@ -281,6 +356,7 @@ func handleBookActivity(c *gin.Context) {
 func handleBookTimings(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -296,13 +372,13 @@ func handleBookTimings(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	act, err := data.GetActivity(Database, id)
+	act, err := data.GetActivity(db, id)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	set, err := NewItemInformation(c.Request)
+	set, err := NewItemInformation(db, c.Request)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Invalid Paramater Format Format: %s", err)
 		return
@@ -316,14 +392,15 @@ func handleBookTimings(c *gin.Context) {
 
 	var tbl *isams.UserTimetable
 	var tbla [][]struct{}
-	if Config.HasISAMS() && ddat.User.IsamsID != nil {
-		iu, err := ISAMS.FindUser(*ddat.User.IsamsID)
+	is := tenantISAMS(c)
+	if is != nil && ddat.User.IsamsID != nil {
+		iu, err := is.FindUser(*ddat.User.IsamsID)
 		if err != nil {
 			internalError(c, err)
 			return
 		}
 
-		tbl = iu.Timetable(ISAMS)
+		tbl = iu.Timetable(is)
 
 		tbla = make([][]struct{}, 0, len(*tbl))
 		for _, t := range *tbl {
@@ -332,6 +409,39 @@ func handleBookTimings(c *gin.Context) {
 	}
 
 	wc := weekCommencing(time.Now())
+	week, weekName, _ := timetableWeek(c, wc)
+	if tbl != nil && week >= len(*tbl) {
+		week = 0
+	}
+
+	ids := make([]uint, len(set))
+	for i, e := range set {
+		ids[i] = e.ItemID
+	}
+
+	// The date is yet to be chosen, so warn of anything falling due before
+	// the last week which may be booked from here.
+	ootBy := wc.AddDate(0, 0, 7*closureWeeks)
+	oot := []data.ServiceStatus{}
+	if len(ids) > 0 {
+		oot, err = data.GetOutOfTest(db, ootBy, ids...)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+	}
+
+	rooms, err := data.GetRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	cal, err := data.GetAcademicCalendar(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
 
 	dat := struct {
 		DashboardData
@@ -342,13 +452,21 @@ func handleBookTimings(c *gin.Context) {
 		Timetable      *isams.UserTimetable
 		TimetableLoop  [][]struct{}
 		WeekCommencing time.Time
-	}{ddat, act, set, string(setjson), Config.HasISAMS(), tbl, tbla, wc}
+		Week           int
+		WeekName       string
+		OutOfTest      []data.ServiceStatus
+		OutOfTestBy    time.Time
+		Rooms          []data.Room
+		Policy         []string
+		Closures       []data.SchoolDate
+	}{ddat, act, set, string(setjson), is != nil, tbl, tbla, wc, week, weekName, oot, ootBy, rooms, tenantConfig(c).BookingPolicy.Describe(act.Category), cal.ClosuresBetween(wc, wc.AddDate(0, 0, 7*closureWeeks))}
 	c.HTML(http.StatusOK, "book-timings.gohtml", dat)
 }
 
 func handleBookSubmission(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	usr, err := data.GetUser(Database, s.UserID)
 	if err != nil {
@@ -364,13 +482,13 @@ func handleBookSubmission(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	act, err := data.GetActivity(Database, id)
+	act, err := data.GetActivity(db, id)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	set, err := NewItemInformation(c.Request)
+	set, err := NewItemInformation(db, c.Request)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Invalid Paramater Format Format: %s", err)
 		return
@@ -419,11 +537,25 @@ func handleBookSubmission(c *gin.Context) {
 	start := date.Add(time.Hour * time.Duration(stime.Hour())).Add(time.Minute * time.Duration(stime.Minute()))
 	end := date.Add(time.Hour * time.Duration(etime.Hour())).Add(time.Minute * time.Duration(etime.Minute()))
 
+	if !checkPolicy(c, usr, act.Category, start) || !checkOpen(c, usr, start) {
+		return
+	}
+
 	location, ok := c.GetQuery("location")
 	if !ok {
 		c.String(http.StatusBadRequest, "Missing Location Parameter")
 		return
 	}
+	location, err = data.ResolveLocation(db, location)
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownRoom) {
+			c.String(http.StatusBadRequest, "Unknown Room: %s", location)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
 	comments, ok := c.GetQuery("comments")
 	if !ok {
 		comments = ""
@@ -432,26 +564,51 @@ func handleBookSubmission(c *gin.Context) {
 	// Copy and clone this activity.
 	// Setting extras to nil, as we already appended them earlier.
 	set.Copy(&act)
-	a, err := act.Clone(Database, s.UserID, nil)
+	act.Equipment = slices.DeleteFunc(act.Equipment, func(e data.EquipmentSet) bool {
+		// Items swapped for substitutes are zeroed rather than removed.
+		return e.Quantity == 0
+	})
+	a, err := act.Clone(db, s.UserID, nil)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	bk, err := data.NewBooking(Database, a, location, start, end, comments)
+	bk, err := data.NewBooking(db, a, location, start, end, comments)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
+	bk, err = data.ChargeBooking(db, bk.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Teachers may choose to wait for equipment to be freed, in which case
+	// the booking only joins the waitlist if there is not enough free now.
+	waitlisted := false
+	if _, ok := c.GetQuery("waitlist"); ok {
+		waitlisted, err = data.WaitlistBooking(db, bk.ID)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+	}
 
 	// Push notification out to technicians
-	urs, err := data.GetRoleUsers(Database, data.UserTechnician)
+	urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
 	if err == nil {
+		body := fmt.Sprintln(usr.DisplayName(), "booked", act.Title, "for", bk.StartTime.Format(time.Kitchen), "-", bk.EndTime.Format(time.Kitchen)+".", "Reload to view.")
+		if waitlisted {
+			body = fmt.Sprintln(usr.DisplayName(), "joined the waitlist for", act.Title, "for", bk.StartTime.Format(time.Kitchen), "-", bk.EndTime.Format(time.Kitchen)+".", "It will be booked once equipment is freed.")
+		}
+
 		// Ignore errors and just push the booking
 		for _, u := range urs {
 			Notifications.PushUser(u.ID, notifications.Notification{
 				Title:  fmt.Sprint("New Booking for ", usr.DisplayName(), " (", usr.Username, ")"),
-				Body:   fmt.Sprintln(usr.DisplayName(), "booked", act.Title, "for", bk.StartTime.Format(time.Kitchen), "-", bk.EndTime.Format(time.Kitchen)+".", "Reload to view."),
+				Body:   body,
 				Type:   notifications.TypeImportant,
 				Action: "/tasks/",
 				Time:   time.Now(),
@@ -459,6 +616,11 @@ func handleBookSubmission(c *gin.Context) {
 		}
 	}
 
+	if !waitlisted {
+		checkBudget(db, bk)
+		autoAssign(c, bk)
+	}
+
 	c.Redirect(http.StatusFound, fmt.Sprint("/book/success/", bk.ID))
 }
 
@@ -466,6 +628,7 @@ func handleBookSubmission(c *gin.Context) {
 func handleBookSuccess(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -481,7 +644,7 @@ func handleBookSuccess(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -508,6 +671,7 @@ func handleBookSuccess(c *gin.Context) {
 func handleBooking(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -523,7 +687,7 @@ func handleBooking(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -534,13 +698,29 @@ func handleBooking(c *gin.Context) {
 		return
 	}
 
+	rets, err := data.GetBookingReturns(db, bk.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	rclash, err := data.RoomClashes(db, bk)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
 	_, noamend := c.GetQuery("noamend")
 	dat := struct {
 		DashboardData
-		Booking  data.Booking
-		Activity data.Activity
-		NoAmend  bool
-	}{ddat, bk, bk.Activity.Parent(Database), noamend}
+		Booking     data.Booking
+		Activity    data.Activity
+		NoAmend     bool
+		Returns     []data.ReturnRecord
+		RoomClashes []data.Booking
+		AmendWindow time.Duration
+		Policy      []string
+	}{ddat, bk, bk.Activity.Parent(db), noamend, rets, rclash, amendWindow(c, ddat.User), tenantConfig(c).BookingPolicy.Describe(bk.Activity.Category)}
 
 	c.HTML(http.StatusOK, "booking.gohtml", dat)
 }
@@ -553,6 +733,7 @@ func handleBooking(c *gin.Context) {
 func handleBookAmend(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -568,7 +749,7 @@ func handleBookAmend(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -579,14 +760,15 @@ func handleBookAmend(c *gin.Context) {
 		return
 	}
 
-	items, err := data.GetEquipment(Database)
+	items, err := data.GetPrepRoomEquipment(db, bk.PrepRoomID)
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
+	window := amendWindow(c, ddat.User)
 	_, postpone := c.GetQuery("postpone")
-	if !bk.MayAmend() && !postpone {
+	if !bk.MayAmend(window) && !postpone {
 		c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID, "?noamend"))
 		return
 	}
@@ -601,7 +783,13 @@ func handleBookAmend(c *gin.Context) {
 			extra = append(extra, e)
 		}
 	}
-	lasttime := bk.StartTime.Add(-time.Hour)
+	lasttime := bk.StartTime.Add(-window)
+
+	rooms, err := data.GetRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
 
 	dat := struct {
 		DashboardData
@@ -611,7 +799,8 @@ func handleBookAmend(c *gin.Context) {
 		Core, Extra []data.EquipmentSet
 		LastTime    time.Time
 		Postpone    bool
-	}{ddat, bk, bk.Activity, items, core, extra, lasttime, postpone}
+		Rooms       []data.Room
+	}{ddat, bk, bk.Activity, items, core, extra, lasttime, postpone, rooms}
 
 	c.HTML(http.StatusOK, "booking-amend.gohtml", dat)
 }
@@ -630,6 +819,7 @@ func handleBookDoAmend(c *gin.Context) {
 func handleBookDoingAmend(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -645,7 +835,7 @@ func handleBookDoingAmend(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -661,8 +851,13 @@ func handleBookDoingAmend(c *gin.Context) {
 		return
 	}
 
+	if !bk.MayAmend(amendWindow(c, ddat.User)) {
+		c.String(http.StatusForbidden, "Amendment Refused: the amendment window for this booking has closed")
+		return
+	}
+
 	c.MultipartForm()
-	set, err := NewPostItemInformation(c.Request)
+	set, err := NewPostItemInformation(db, c.Request)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -686,35 +881,57 @@ func handleBookDoingAmend(c *gin.Context) {
 	stime = stime.Add(time.Duration(off) * -time.Second).In(time.Local).Add(2 * time.Minute)
 	etime = etime.Add(time.Duration(off) * -time.Second).In(time.Local).Add(2 * time.Minute)
 
+	if bookingMoved(bk, sstime) && !checkMove(c, ddat.User, bk, stime) {
+		return
+	}
+	if !sameDay(stime, bk.StartTime) && !checkOpen(c, ddat.User, stime) {
+		return
+	}
+
 	location, ok := c.GetPostForm("location")
 	if !ok {
 		c.String(http.StatusBadRequest, "Missing Location Parameter")
 		return
 	}
+	location, err = data.ResolveLocation(db, location)
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownRoom) {
+			c.String(http.StatusBadRequest, "Unknown Room: %s", location)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
 	comments, ok := c.GetPostForm("comments")
 	if !ok {
 		comments = ""
 	}
 
 	// Update original activity
+	freedStart, freedEnd := joinPeriod(bk.StartTime, bk.EndTime, stime, etime)
 	set.Copy(&bk.Activity)
 	bk.Location = location
 	bk.Comments = comments
 	bk.StartTime = stime
 	bk.EndTime = etime
-	if err := Database.Updates(&bk).Error; err != nil {
+	if err := db.Updates(&bk).Error; err != nil {
 		internalError(c, err)
 		return
 	}
 	for _, eq := range bk.Activity.Equipment {
-		if err := Database.Updates(&eq).Error; err != nil {
+		if err := db.Updates(&eq).Error; err != nil {
 			internalError(c, err)
 			return
 		}
 	}
+	if _, err := data.ChargeBooking(db, bk.ID); err != nil {
+		internalError(c, err)
+		return
+	}
 
 	// Push notification out to technicians
-	urs, err := data.GetRoleUsers(Database, data.UserTechnician)
+	urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
 	if err == nil {
 		// Ignore errors and just push the booking
 		for _, u := range urs {
@@ -728,12 +945,16 @@ func handleBookDoingAmend(c *gin.Context) {
 		}
 	}
 
+	// Amendments may free equipment for, or themselves be on, the waitlist.
+	promoteWaitlist(c, freedStart, freedEnd)
+
 	c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID))
 }
 
 func handleBookPostpone(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -749,7 +970,7 @@ func handleBookPostpone(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -788,27 +1009,44 @@ func handleBookPostpone(c *gin.Context) {
 		c.String(http.StatusForbidden, "Postponement to before current time not allowed")
 		return
 	}
+	if bookingMoved(bk, sstime) && !checkMove(c, ddat.User, bk, stime) {
+		return
+	}
+	if !sameDay(stime, bk.StartTime) && !checkOpen(c, ddat.User, stime) {
+		return
+	}
 
 	location, ok := c.GetPostForm("location")
 	if !ok {
 		c.String(http.StatusBadRequest, "Missing Location Parameter")
 		return
 	}
+	location, err = data.ResolveLocation(db, location)
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownRoom) {
+			c.String(http.StatusBadRequest, "Unknown Room: %s", location)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
 
 	// Update original activity
 	bk.Location = location
+	freedStart, freedEnd := joinPeriod(bk.StartTime, bk.EndTime, stime, etime)
 	bk.StartTime = stime
 	bk.EndTime = etime
-	if bk.Status != data.BookingStatusPending {
+	if bk.Status != data.BookingStatusPending && !bk.Status.Waitlisted() {
 		bk.Status = data.BookingStatusProgress
 	}
-	if err := Database.Updates(&bk).Error; err != nil {
+	if err := db.Updates(&bk).Error; err != nil {
 		internalError(c, err)
 		return
 	}
 
 	// Push notification out to technicians
-	urs, err := data.GetRoleUsers(Database, data.UserTechnician)
+	urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
 	if err == nil {
 		// Ignore errors and just push the booking
 		for _, u := range urs {
@@ -822,6 +1060,8 @@ func handleBookPostpone(c *gin.Context) {
 		}
 	}
 
+	promoteWaitlist(c, freedStart, freedEnd)
+
 	c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID))
 }
 
@@ -832,6 +1072,7 @@ func handleBookPostpone(c *gin.Context) {
 func handleBookCancel(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -847,7 +1088,7 @@ func handleBookCancel(c *gin.Context) {
 	}
 	id := uint(lid)
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoSuchBooking) {
 			c.String(http.StatusNotFound, "Booking Not Found")
@@ -863,14 +1104,25 @@ func handleBookCancel(c *gin.Context) {
 		return
 	}
 
-	res := Database.Delete(&bk)
+	// Returned bookings are kept for the breakage report.
+	returned, err := data.HasReturn(db, bk.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if returned {
+		c.String(http.StatusBadRequest, "Cannot cancel booking: %s", data.ErrReturned)
+		return
+	}
+
+	res := db.Delete(&bk)
 	if err := res.Error; err != nil {
 		internalError(c, err)
 		return
 	}
 
 	// Notify technicians of the cancellation.
-	urs, err := data.GetRoleUsers(Database, data.UserTechnician)
+	urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -885,5 +1137,10 @@ func handleBookCancel(c *gin.Context) {
 		})
 	}
 
+	// Waitlisted bookings held no equipment to free.
+	if !bk.Status.Waitlisted() {
+		promoteWaitlist(c, bk.StartTime, bk.EndTime)
+	}
+
 	c.Redirect(http.StatusFound, "/dashboard/")
 }
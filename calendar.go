@@ -0,0 +1,341 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ejv2/prepper/conf"
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Calendar views and groupings.
+const (
+	calendarWeek = "week"
+	calendarDay  = "day"
+
+	calendarByLocation   = "location"
+	calendarByTeacher    = "teacher"
+	calendarByTechnician = "technician"
+)
+
+// noLocation is the row used for bookings without a location.
+const noLocation = "No Location"
+
+// calendarPeriod is a column of a calendar day. If any bookings fall outside
+// every period of the day, a final column holds them.
+type calendarPeriod struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// calendarEntry is a single booking shown in a calendar cell.
+type calendarEntry struct {
+	ID       uint   `json:"id"`
+	Activity string `json:"activity"`
+	Owner    string `json:"owner"`
+	Assignee string `json:"assignee"`
+	Location string `json:"location"`
+	Status   string `json:"status"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+}
+
+// calendarRow is a row of a calendar day, holding the bookings in each
+// period for one location, teacher or assigned technician.
+type calendarRow struct {
+	Name  string            `json:"name"`
+	Cells [][]calendarEntry `json:"cells"`
+}
+
+// calendarDate is a single day of the calendar, with columns for the periods
+// of that day.
+type calendarDate struct {
+	Date    time.Time        `json:"date"`
+	Periods []calendarPeriod `json:"periods"`
+	Rows    []calendarRow    `json:"rows"`
+}
+
+// calendar is the layout of bookings over a day or week, in rows by location,
+// teacher or technician and columns by timetable period.
+type calendar struct {
+	View  string         `json:"view"`
+	Group string         `json:"group"`
+	Start time.Time      `json:"start"`
+	End   time.Time      `json:"end"`
+	Days  []calendarDate `json:"days"`
+}
+
+// Previous returns the start of the previous day or week.
+func (c calendar) Previous() time.Time {
+	if c.View == calendarWeek {
+		return c.Start.AddDate(0, 0, -7)
+	}
+
+	return c.Start.AddDate(0, 0, -1)
+}
+
+// clockTime returns the time since midnight of the given time.
+func clockTime(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// calendarColumns returns the columns for each configured period, with the
+// index of the period which each booking overlaps. Bookings which overlap no
+// period are placed in the final column.
+func calendarColumns(layout conf.TimetableLayout) ([]calendarPeriod, func(data.Booking) []int) {
+	cols := make([]calendarPeriod, 0, len(layout)+1)
+	for _, p := range layout {
+		if p == nil {
+			cols = append(cols, calendarPeriod{Name: p.String()})
+			continue
+		}
+
+		cols = append(cols, calendarPeriod{
+			Name:  p.Name,
+			Start: time.Time(p.Start).Format(timeFormat),
+			End:   time.Time(p.End).Format(timeFormat),
+		})
+	}
+	cols = append(cols, calendarPeriod{Name: "Other"})
+
+	within := func(b data.Booking) []int {
+		s, e := clockTime(b.StartTime.In(time.Local)), clockTime(b.EndTime.In(time.Local))
+
+		in := make([]int, 0, 1)
+		for i, p := range layout {
+			if p == nil || (s < clockTime(time.Time(p.End)) && e > clockTime(time.Time(p.Start))) {
+				in = append(in, i)
+			}
+		}
+		if len(in) == 0 {
+			in = append(in, len(layout))
+		}
+
+		return in
+	}
+
+	return cols, within
+}
+
+// newCalendar lays out the bookings over the day or week containing date. Rows
+// are grouped by location, including every known room, by teacher, or by
+// assigned technician, including every technician, with columns for each
+// period of the layout returned by layoutFor for each day.
+func newCalendar(db *gorm.DB, layoutFor func(time.Time) conf.TimetableLayout, view, group string, date time.Time) (calendar, error) {
+	y, m, d := date.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	days := 1
+	if view == calendarWeek {
+		start = weekCommencing(start)
+		days = 7
+	}
+	end := start.AddDate(0, 0, days)
+
+	cal := calendar{View: view, Group: group, Start: start, End: end}
+
+	bks, err := data.GetBookingsRange(db, start.UTC(), end.UTC())
+	if err != nil {
+		return cal, err
+	}
+
+	rowOf := func(b data.Booking) string {
+		switch group {
+		case calendarByTeacher:
+			return b.Owner.DisplayName()
+		case calendarByTechnician:
+			return b.AssigneeName()
+		}
+		if b.Location == "" {
+			return noLocation
+		}
+
+		return b.Location
+	}
+
+	names := make([]string, 0, 10)
+	seen := make(map[string]bool)
+	if group == calendarByLocation {
+		rooms, err := data.GetRooms(db)
+		if err != nil {
+			return cal, err
+		}
+
+		for _, r := range rooms {
+			names = append(names, r.Name)
+			seen[r.Name] = true
+		}
+	}
+	if group == calendarByTechnician {
+		techs, err := data.GetRoleUsers(db, data.UserTechnician)
+		if err != nil {
+			return cal, err
+		}
+
+		for _, t := range techs {
+			if n := t.DisplayName(); !seen[n] {
+				names = append(names, n)
+				seen[n] = true
+			}
+		}
+	}
+	for _, b := range bks {
+		if n := rowOf(b); !seen[n] {
+			names = append(names, n)
+			seen[n] = true
+		}
+	}
+	sort.Strings(names)
+
+	sort.SliceStable(bks, func(i, j int) bool {
+		return bks[i].StartTime.Before(bks[j].StartTime)
+	})
+
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		next := day.AddDate(0, 0, 1)
+		cols, within := calendarColumns(layoutFor(day))
+
+		rows := make([]calendarRow, len(names))
+		idx := make(map[string]int, len(names))
+		for j, n := range names {
+			rows[j] = calendarRow{Name: n, Cells: make([][]calendarEntry, len(cols))}
+			idx[n] = j
+		}
+
+		booked := false
+		for _, b := range bks {
+			if !b.Status.Committed() || !b.StartTime.Before(next) || b.EndTime.Before(day) {
+				continue
+			}
+
+			ent := calendarEntry{
+				ID:       b.ID,
+				Activity: b.Activity.Title,
+				Owner:    b.Owner.DisplayName(),
+				Assignee: b.AssigneeName(),
+				Location: b.Location,
+				Status:   b.Status.String(),
+				Start:    b.StartTime.In(time.Local).Format(timeFormat),
+				End:      b.EndTime.In(time.Local).Format(timeFormat),
+			}
+
+			row := &rows[idx[rowOf(b)]]
+			for _, p := range within(b) {
+				row.Cells[p] = append(row.Cells[p], ent)
+			}
+			booked = true
+		}
+
+		// Weekends are only of interest if something is booked.
+		if view == calendarWeek && !booked && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+
+		cal.Days = append(cal.Days, dropOther(calendarDate{Date: day, Periods: cols, Rows: rows}))
+	}
+
+	return cal, nil
+}
+
+// dropOther drops the column of a day for bookings outside every period if
+// it is unused.
+func dropOther(d calendarDate) calendarDate {
+	other := len(d.Periods) - 1
+	for _, r := range d.Rows {
+		if len(r.Cells[other]) > 0 {
+			return d
+		}
+	}
+
+	d.Periods = d.Periods[:other]
+	for i := range d.Rows {
+		d.Rows[i].Cells = d.Rows[i].Cells[:other]
+	}
+
+	return d
+}
+
+// calendarFromQuery builds the calendar requested by the "view", "group" and
+// "date" query parameters, defaulting to this week by location. If the
+// parameters are invalid, false is returned.
+func calendarFromQuery(c *gin.Context) (calendar, bool, error) {
+	view := c.DefaultQuery("view", calendarWeek)
+	group := c.DefaultQuery("group", calendarByLocation)
+	if (view != calendarWeek && view != calendarDay) || (group != calendarByLocation && group != calendarByTeacher && group != calendarByTechnician) {
+		return calendar{}, false, nil
+	}
+
+	date := time.Now()
+	if sd := c.Query("date"); sd != "" {
+		var err error
+		date, err = time.ParseInLocation(dateFormat, sd, time.Local)
+		if err != nil {
+			return calendar{}, false, nil
+		}
+	}
+
+	// Each day is laid out by its own periods, which may differ from day
+	// to day within a week.
+	cal, err := newCalendar(tenantDB(c), tenantConfig(c).LayoutFor, view, group, date)
+	return cal, true, err
+}
+
+// handleCalendar is the handler for "/todo/calendar".
+//
+// Shows bookings for the week or day laid out by period and grouped by
+// location, teacher or technician.
+func handleCalendar(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	cal, ok, err := calendarFromQuery(c)
+	if !ok {
+		c.String(http.StatusBadRequest, "Bad Calendar View, Grouping or Date")
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Calendar calendar
+	}{ddat, cal}
+
+	c.HTML(http.StatusOK, "calendar.gohtml", dat)
+}
+
+// handleAPICalendar is the handler for "/api/calendar".
+//
+// Returns the calendar shown at "/todo/calendar" as JSON, taking the same
+// query parameters.
+func handleAPICalendar(c *gin.Context) {
+	cal, ok, err := calendarFromQuery(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Bad calendar view, grouping or date",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Error",
+			"message": "SQL Error" + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, cal)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ejv2/prepper/conf"
+	"github.com/ejv2/prepper/data"
+)
+
+// capacityPlan is the prep time due on each day of a week against the time
+// technicians have to do it.
+type capacityPlan struct {
+	Start       time.Time
+	Technicians int
+	Day         data.Minutes
+	Days        []data.CapacityDay
+}
+
+// Previous returns the start of the previous week.
+func (p capacityPlan) Previous() time.Time {
+	return p.Start.AddDate(0, 0, -7)
+}
+
+// Next returns the start of the next week.
+func (p capacityPlan) Next() time.Time {
+	return p.Start.AddDate(0, 0, 7)
+}
+
+// Overloaded returns the days on which more prep is due than can be done.
+func (p capacityPlan) Overloaded() []data.CapacityDay {
+	var over []data.CapacityDay
+	for _, d := range p.Days {
+		if d.Overloaded() {
+			over = append(over, d)
+		}
+	}
+
+	return over
+}
+
+// newCapacityPlan plans the prep time due in the week containing date against
+// the time of the technicians of the given prep room.
+func newCapacityPlan(db *gorm.DB, cfg conf.Config, prep *uint, date time.Time) (capacityPlan, error) {
+	y, m, d := date.Date()
+	start := weekCommencing(time.Date(y, m, d, 0, 0, 0, 0, time.Local))
+	plan := capacityPlan{Start: start, Day: data.Minutes(cfg.TechnicianDay() / time.Minute)}
+
+	bks, err := data.GetBookingsRange(db, start.UTC(), start.AddDate(0, 0, 7).UTC())
+	if err != nil {
+		return plan, err
+	}
+	bks = slices.DeleteFunc(bks, func(b data.Booking) bool {
+		return prep != nil && b.PrepRoomID != nil && *b.PrepRoomID != *prep
+	})
+
+	techs, err := data.PrepRoomTechnicians(db, prep)
+	if err != nil {
+		return plan, err
+	}
+
+	plan.Technicians = len(techs)
+	plan.Days = data.PlanCapacity(bks, weekDays(start, bks), len(techs), cfg.TechnicianDay())
+	return plan, nil
+}
+
+// handleCapacity is the handler for "/todo/capacity".
+//
+// Compares the prep time due on each day of the week containing "date"
+// against the working hours of the technicians of the prep room given as
+// "prep", flagging overloaded days and suggesting an order of preparation.
+func handleCapacity(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	prep, ok := prepRoomFilter(c, ddat.User)
+	if !ok {
+		return
+	}
+
+	date := time.Now()
+	if sd := c.Query("date"); sd != "" {
+		date, err = time.ParseInLocation(dateFormat, sd, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date")
+			return
+		}
+	}
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	plan, err := newCapacityPlan(db, tenantConfig(c), prep.ID, date)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "capacity.gohtml", struct {
+		DashboardData
+		Plan  capacityPlan
+		Prep  prepFilter
+		Preps []data.PrepRoom
+	}{ddat, plan, prep, preps})
+}
+
+// handleTodoPrepTime is the handler for POST "/todo/prep/[ID]".
+//
+// Adjusts the estimated prep time of the booking to "minutes". If blank, the
+// estimate of the activity is used instead.
+func handleTodoPrepTime(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	lid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad ID Format: %s", err)
+		return
+	}
+
+	var mins *data.Minutes
+	if sm := strings.TrimSpace(c.PostForm("minutes")); sm != "" {
+		m, err := strconv.ParseUint(sm, 10, 16)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Prep Time")
+			return
+		}
+
+		v := data.Minutes(m)
+		mins = &v
+	}
+
+	if _, err := data.SetBookingPrepTime(db, uint(lid), mins); err != nil {
+		if errors.Is(err, data.ErrNoSuchBooking) {
+			c.String(http.StatusNotFound, "Booking Not Found")
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/todo/")
+}
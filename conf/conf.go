@@ -7,12 +7,17 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/go-playground/validator"
 )
 
 const DefaultHelpText = "For queries or assistance, please do not hesitate to contact your system administrator"
 
+// DefaultTechnicianHours is the length of a technician's working day if not
+// configured.
+const DefaultTechnicianHours = 7.5
+
 // Config represents the config file loaded from somewhere on disk at startup.
 // It is de-serialized from JSON by encoding/json.
 type Config struct {
@@ -30,6 +35,28 @@ type Config struct {
 	ISAMS    *ISAMSConfig `json:"isams"`
 
 	TimetableLayout *TimetableLayout `json:"timetable_layout"`
+	// DayLayouts replace the timetable layout on days of the week, such as
+	// for a shorter Wednesday, keyed by lowercase weekday name.
+	DayLayouts map[string]TimetableLayout `validate:"dive,keys,oneof=monday tuesday wednesday thursday friday saturday sunday,endkeys" json:"day_layouts"`
+	// DateLayouts replace the timetable layout on particular dates.
+	DateLayouts []DateLayout `json:"date_layouts"`
+	// WeekRotation is the rotation of timetable weeks, such as Week A and
+	// Week B. If nil, the week of the timetable is not known.
+	WeekRotation *WeekRotation `json:"week_rotation"`
+
+	// AutoAssign assigns new bookings to the least loaded technician of
+	// their prep room.
+	AutoAssign bool `json:"auto_assign"`
+	// TechnicianHours is the number of hours each technician has to
+	// prepare bookings each day, used to plan capacity.
+	TechnicianHours float64 `validate:"gte=0,lte=24" json:"technician_hours"`
+	// BookingPolicy limits how close to the time bookings may be made
+	// and amended.
+	BookingPolicy BookingPolicy `json:"booking_policy"`
+
+	// Tenants are the schools served by this installation. If empty, a
+	// single school is served using the settings above.
+	Tenants []Tenant `validate:"dive" json:"tenants"`
 }
 
 // NewConfig parses a JSON config file from the file at path.
@@ -54,10 +81,16 @@ func NewConfig(path string) (Config, error) {
 	if c.TimetableLayout == nil {
 		c.TimetableLayout = &TimetableLayout{nil}
 	}
+	if c.TechnicianHours == 0 {
+		c.TechnicianHours = DefaultTechnicianHours
+	}
 
 	if err := c.Struct(c); err != nil {
 		return c, fmt.Errorf("validate config: %w", err)
 	}
+	if err := c.checkTenants(); err != nil {
+		return c, fmt.Errorf("validate config: %w", err)
+	}
 
 	return c, nil
 }
@@ -66,6 +99,12 @@ func (c Config) FullAddr() string {
 	return fmt.Sprintf("%s:%d", c.ListenAddr, c.ListenPort)
 }
 
+// TechnicianDay returns the time each technician has to prepare bookings each
+// day.
+func (c Config) TechnicianDay() time.Duration {
+	return time.Duration(c.TechnicianHours * float64(time.Hour))
+}
+
 // HasISAMS returns true if ISAMS is configured in the config file, enabling
 // ISAMS features.
 func (c Config) HasISAMS() bool {
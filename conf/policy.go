@@ -0,0 +1,156 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultAmendWindow is the number of minutes before a booking starts after
+// which it may no longer be amended, if not configured.
+const DefaultAmendWindow = 60
+
+// AnyCategory is the key of the lead time which applies to activity
+// categories without their own.
+const AnyCategory = "*"
+
+// Booking policy errors.
+var (
+	ErrLeadTime = errors.New("not enough notice given")
+	ErrCutoff   = errors.New("cut-off passed")
+)
+
+// A BookingPolicy is the set of rules governing how close to the time a
+// booking may be made or amended.
+type BookingPolicy struct {
+	// LeadTimes is the minimum notice, in minutes, required for bookings
+	// of each activity category. That of AnyCategory applies to
+	// categories not listed.
+	LeadTimes map[string]uint `json:"lead_times"`
+	// Cutoffs are the times of day by which bookings must be made some
+	// days in advance.
+	Cutoffs []Cutoff `validate:"dive" json:"cutoffs"`
+	// AmendMinutes is the number of minutes before a booking starts after
+	// which it may no longer be amended. If nil, DefaultAmendWindow is
+	// used.
+	AmendMinutes *uint `json:"amend_window"`
+}
+
+// A Cutoff requires bookings to be made by a time of day some days before
+// they take place, such as "tomorrow's bookings by 3pm".
+type Cutoff struct {
+	DaysBefore uint       `json:"days_before"`
+	Time       PeriodTime `json:"time"`
+	// Weekday restricts the cut-off to bookings on one day of the week,
+	// such as to require Monday's bookings by the Friday before. If
+	// empty, it applies to bookings on every day.
+	Weekday string `validate:"omitempty,oneof=monday tuesday wednesday thursday friday saturday sunday" json:"weekday"`
+}
+
+// Applies returns true if the cut-off applies to a booking starting at start.
+func (c Cutoff) Applies(start time.Time) bool {
+	return c.Weekday == "" || strings.EqualFold(c.Weekday, start.Weekday().String())
+}
+
+// Deadline returns the time by which a booking starting at start must be made.
+func (c Cutoff) Deadline(start time.Time) time.Time {
+	t := time.Time(c.Time)
+	y, m, d := start.Date()
+	return time.Date(y, m, d-int(c.DaysBefore), t.Hour(), t.Minute(), t.Second(), 0, start.Location())
+}
+
+func (c Cutoff) String() string {
+	day := "each day's"
+	if c.Weekday != "" {
+		day = strings.ToUpper(c.Weekday[:1]) + c.Weekday[1:] + "'s"
+	}
+
+	tm := time.Time(c.Time).Format("15:04")
+	switch c.DaysBefore {
+	case 0:
+		return fmt.Sprintf("%s bookings must be made by %s on the day", day, tm)
+	case 1:
+		return fmt.Sprintf("%s bookings must be made by %s the day before", day, tm)
+	default:
+		return fmt.Sprintf("%s bookings must be made by %s, %d days before", day, tm, c.DaysBefore)
+	}
+}
+
+// LeadTime returns the minimum notice required for bookings of activities in
+// the given category.
+func (p BookingPolicy) LeadTime(category string) time.Duration {
+	mins, ok := p.LeadTimes[category]
+	if !ok {
+		mins = p.LeadTimes[AnyCategory]
+	}
+
+	return time.Duration(mins) * time.Minute
+}
+
+// AmendWindow returns the time before a booking starts after which it may no
+// longer be amended.
+func (p BookingPolicy) AmendWindow() time.Duration {
+	mins := uint(DefaultAmendWindow)
+	if p.AmendMinutes != nil {
+		mins = *p.AmendMinutes
+	}
+
+	return time.Duration(mins) * time.Minute
+}
+
+// Check returns an error if a booking of an activity in the given category
+// starting at start may not be made at the time now.
+func (p BookingPolicy) Check(category string, start, now time.Time) error {
+	if lead := p.LeadTime(category); lead > 0 && now.Add(lead).After(start) {
+		return fmt.Errorf("%w: bookings of %s activities need %s notice", ErrLeadTime, categoryName(category), formatMinutes(lead))
+	}
+
+	for _, c := range p.Cutoffs {
+		if c.Applies(start) && now.After(c.Deadline(start)) {
+			return fmt.Errorf("%w: %s", ErrCutoff, c)
+		}
+	}
+
+	return nil
+}
+
+// Describe returns the rules which apply to bookings of activities in the
+// given category as human readable sentences.
+func (p BookingPolicy) Describe(category string) []string {
+	var rules []string
+	if lead := p.LeadTime(category); lead > 0 {
+		rules = append(rules, fmt.Sprintf("Bookings of %s activities need %s notice", categoryName(category), formatMinutes(lead)))
+	}
+	for _, c := range p.Cutoffs {
+		s := c.String()
+		rules = append(rules, strings.ToUpper(s[:1])+s[1:])
+	}
+	rules = append(rules, fmt.Sprintf("Bookings may be amended until %s before they start", formatMinutes(p.AmendWindow())))
+
+	return rules
+}
+
+// categoryName returns a readable name for an activity category.
+func categoryName(category string) string {
+	if category == "" || category == AnyCategory {
+		return "all"
+	}
+
+	return category
+}
+
+// formatMinutes formats a whole number of minutes for display.
+func formatMinutes(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%d minutes", m)
+	case m == 0 && h == 1:
+		return "1 hour"
+	case m == 0:
+		return fmt.Sprintf("%d hours", h)
+	default:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+}
@@ -0,0 +1,74 @@
+package conf_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ejv2/prepper/conf"
+)
+
+const PolicyConfigPath = "./testdata/policy.json"
+
+func loadPolicy(t *testing.T) conf.BookingPolicy {
+	cfg, err := conf.NewConfig(PolicyConfigPath)
+	if err != nil {
+		t.Fatalf("invalid policy config: %s", err.Error())
+	}
+
+	return cfg.BookingPolicy
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := loadPolicy(t)
+
+	// Tuesday 20/10/26 and Monday 26/10/26, both at 10:00.
+	tue := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)
+	mon := time.Date(2026, 10, 26, 10, 0, 0, 0, time.UTC)
+
+	testdata := []struct {
+		Category string
+		Start    time.Time
+		Now      time.Time
+		Expect   error
+	}{
+		// Tomorrow's bookings by 15:00.
+		{"physics", tue, time.Date(2026, 10, 19, 14, 59, 0, 0, time.UTC), nil},
+		{"physics", tue, time.Date(2026, 10, 19, 15, 1, 0, 0, time.UTC), conf.ErrCutoff},
+		// Chemistry needs a day's notice, everything else an hour.
+		{"chemistry", tue, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), nil},
+		{"chemistry", tue, time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC), conf.ErrLeadTime},
+		{"physics", tue, time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC), conf.ErrLeadTime},
+		// Monday's bookings by the Friday before.
+		{"physics", mon, time.Date(2026, 10, 23, 14, 0, 0, 0, time.UTC), nil},
+		{"physics", mon, time.Date(2026, 10, 24, 9, 0, 0, 0, time.UTC), conf.ErrCutoff},
+	}
+
+	for _, d := range testdata {
+		err := p.Check(d.Category, d.Start, d.Now)
+		if !errors.Is(err, d.Expect) || (err != nil && d.Expect == nil) {
+			t.Errorf("%s at %s booked at %s: got %v, expect %v", d.Category, d.Start, d.Now, err, d.Expect)
+		}
+	}
+}
+
+func TestAmendWindow(t *testing.T) {
+	if w := loadPolicy(t).AmendWindow(); w != 2*time.Hour {
+		t.Errorf("configured amend window not used (got %s)", w)
+	}
+	if w := (conf.BookingPolicy{}).AmendWindow(); w != conf.DefaultAmendWindow*time.Minute {
+		t.Errorf("default amend window not used (got %s)", w)
+	}
+}
+
+func TestBadCutoffWeekday(t *testing.T) {
+	cfg, err := conf.NewConfig(PolicyConfigPath)
+	if err != nil {
+		t.Fatalf("invalid policy config: %s", err.Error())
+	}
+
+	cfg.BookingPolicy.Cutoffs = []conf.Cutoff{{Weekday: "someday"}}
+	if err := cfg.Struct(cfg); err == nil {
+		t.Error("invalid cut-off weekday accepted")
+	}
+}
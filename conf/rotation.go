@@ -0,0 +1,68 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A WeekRotation is the rotation of timetable weeks, such as Week A and Week
+// B, anchored to a week known to be the first of the rotation.
+type WeekRotation struct {
+	// Anchor is any day in a week which is the first week of the rotation.
+	Anchor CalendarDate `json:"anchor"`
+	// Weeks are the names of each week of the rotation, in order. If
+	// empty, the names of the timetable weeks in iSAMS are used instead.
+	Weeks []string `json:"weeks"`
+}
+
+// Index returns the position of the week containing t in a rotation of n
+// weeks, or zero if n is zero.
+func (r WeekRotation) Index(t time.Time, n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	// Rounded to whole days, as weeks may cross a change in daylight saving.
+	days := int(math.Round(monday(t).Sub(monday(time.Time(r.Anchor))).Hours() / 24))
+	weeks := days / 7
+	return ((weeks % n) + n) % n
+}
+
+// Name returns the configured name of the week containing t, or an empty
+// string if no names are configured.
+func (r WeekRotation) Name(t time.Time) string {
+	if len(r.Weeks) == 0 {
+		return ""
+	}
+
+	return r.Weeks[r.Index(t, len(r.Weeks))]
+}
+
+// monday returns midnight at the start of the Monday of the week containing
+// t, in the location of t.
+func monday(t time.Time) time.Time {
+	y, m, d := t.Date()
+	off := (int(t.Weekday()) + 6) % 7
+	return time.Date(y, m, d-off, 0, 0, 0, 0, t.Location())
+}
+
+// CalendarDate allows the parsing of human-friendly dates, without a time.
+type CalendarDate time.Time
+
+// UnmarshalJSON allows JSON parsing of dates with the desired format.
+func (d *CalendarDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parse date: invalid json: %w", err)
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return fmt.Errorf("parse date: %s: invalid date: %w", s, err)
+	}
+
+	*d = CalendarDate(t)
+	return nil
+}
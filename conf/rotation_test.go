@@ -0,0 +1,52 @@
+package conf_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ejv2/prepper/conf"
+)
+
+const RotationConfigPath = "./testdata/rotation.json"
+
+func TestWeekRotation(t *testing.T) {
+	cfg, err := conf.NewConfig(RotationConfigPath)
+	if err != nil {
+		t.Fatalf("invalid rotation config: %s", err.Error())
+	}
+	if cfg.WeekRotation == nil {
+		t.Fatal("nil week rotation config")
+	}
+	r := *cfg.WeekRotation
+
+	testdata := []struct {
+		Date   time.Time
+		Expect string
+	}{
+		// The anchor is Wednesday 09/09/26, so Week A starts on Monday 07/09/26.
+		{time.Date(2026, 9, 7, 0, 0, 0, 0, time.Local), "Week A"},
+		{time.Date(2026, 9, 13, 23, 0, 0, 0, time.Local), "Week A"},
+		{time.Date(2026, 9, 14, 9, 0, 0, 0, time.Local), "Week B"},
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local), "Week A"},
+		{time.Date(2026, 10, 26, 9, 0, 0, 0, time.Local), "Week B"},
+		// Weeks before the anchor still rotate.
+		{time.Date(2026, 9, 4, 9, 0, 0, 0, time.Local), "Week B"},
+		{time.Date(2026, 8, 24, 9, 0, 0, 0, time.Local), "Week A"},
+	}
+
+	for _, test := range testdata {
+		if got := r.Name(test.Date); got != test.Expect {
+			t.Errorf("%s: wrong week (expect %q, got %q)", test.Date.Format(time.DateOnly), test.Expect, got)
+		}
+	}
+
+	if i := r.Index(time.Date(2026, 9, 21, 9, 0, 0, 0, time.Local), 3); i != 2 {
+		t.Errorf("wrong index in rotation of three weeks (expect 2, got %d)", i)
+	}
+	if i := r.Index(time.Now(), 0); i != 0 {
+		t.Errorf("wrong index in empty rotation (expect 0, got %d)", i)
+	}
+	if n := (conf.WeekRotation{}).Name(time.Now()); n != "" {
+		t.Errorf("unnamed rotation gave name %q", n)
+	}
+}
@@ -0,0 +1,161 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Tenant config errors.
+var (
+	ErrTenantExists  = errors.New("duplicate tenant ID")
+	ErrTenantHost    = errors.New("host served by more than one tenant")
+	ErrTenantDefault = errors.New("more than one default tenant")
+)
+
+// A Tenant is a school served by a shared installation, such as one of the
+// schools of a multi-academy trust. Each tenant sees only its own users,
+// activities, inventory and bookings. Requests are routed to a tenant by
+// hostname, or by a path prefix of the tenant ID.
+//
+// Fields left unset fall back to those of the top-level config.
+type Tenant struct {
+	ID    string   `validate:"required,alphanum" json:"id"`
+	Name  string   `json:"name"`
+	Hosts []string `validate:"dive,hostname" json:"hosts"`
+
+	// Default marks the tenant which takes ownership of data created before
+	// tenants were configured.
+	Default bool `json:"default"`
+
+	HelpText        string                     `json:"help_text"`
+	ISAMS           *ISAMSConfig               `json:"isams"`
+	TimetableLayout *TimetableLayout           `json:"timetable_layout"`
+	DayLayouts      map[string]TimetableLayout `validate:"omitempty,dive,keys,oneof=monday tuesday wednesday thursday friday saturday sunday,endkeys" json:"day_layouts"`
+	DateLayouts     []DateLayout               `json:"date_layouts"`
+	WeekRotation    *WeekRotation              `json:"week_rotation"`
+	AutoAssign      *bool                      `json:"auto_assign"`
+	TechnicianHours *float64                   `validate:"omitempty,gte=0,lte=24" json:"technician_hours"`
+	BookingPolicy   *BookingPolicy             `json:"booking_policy"`
+}
+
+// DisplayName returns the name of the tenant, or its ID if unnamed.
+func (t Tenant) DisplayName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	return t.ID
+}
+
+// HasTenants returns true if tenants are configured, in which case all data is
+// separated by tenant.
+func (c Config) HasTenants() bool {
+	return len(c.Tenants) > 0
+}
+
+// checkTenants ensures that every tenant can be told apart from the others.
+func (c Config) checkTenants() error {
+	ids := make(map[string]bool)
+	hosts := make(map[string]bool)
+	def := false
+
+	for _, t := range c.Tenants {
+		if ids[t.ID] {
+			return fmt.Errorf("tenant %q: %w", t.ID, ErrTenantExists)
+		}
+		ids[t.ID] = true
+
+		for _, h := range t.Hosts {
+			h = strings.ToLower(h)
+			if hosts[h] {
+				return fmt.Errorf("tenant %q: %s: %w", t.ID, h, ErrTenantHost)
+			}
+			hosts[h] = true
+		}
+
+		if t.Default {
+			if def {
+				return fmt.Errorf("tenant %q: %w", t.ID, ErrTenantDefault)
+			}
+			def = true
+		}
+	}
+
+	return nil
+}
+
+// FindTenant returns the tenant with the given ID.
+func (c Config) FindTenant(id string) (Tenant, bool) {
+	for _, t := range c.Tenants {
+		if t.ID == id {
+			return t, true
+		}
+	}
+
+	return Tenant{}, false
+}
+
+// DefaultTenant returns the tenant marked as default, if any.
+func (c Config) DefaultTenant() (Tenant, bool) {
+	for _, t := range c.Tenants {
+		if t.Default {
+			return t, true
+		}
+	}
+
+	return Tenant{}, false
+}
+
+// TenantForHost returns the tenant served on the given host, which may include
+// a port.
+func (c Config) TenantForHost(host string) (Tenant, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, t := range c.Tenants {
+		for _, h := range t.Hosts {
+			if strings.EqualFold(h, host) {
+				return t, true
+			}
+		}
+	}
+
+	return Tenant{}, false
+}
+
+// ForTenant returns the config as seen by the given tenant, with any of its
+// own settings in place of the top-level ones.
+func (c Config) ForTenant(t Tenant) Config {
+	if t.HelpText != "" {
+		c.HelpText = t.HelpText
+	}
+	if t.ISAMS != nil {
+		c.ISAMS = t.ISAMS
+	}
+	if t.TimetableLayout != nil {
+		c.TimetableLayout = t.TimetableLayout
+	}
+	if t.DayLayouts != nil {
+		c.DayLayouts = t.DayLayouts
+	}
+	if t.DateLayouts != nil {
+		c.DateLayouts = t.DateLayouts
+	}
+	if t.WeekRotation != nil {
+		c.WeekRotation = t.WeekRotation
+	}
+	if t.AutoAssign != nil {
+		c.AutoAssign = *t.AutoAssign
+	}
+	if t.TechnicianHours != nil {
+		c.TechnicianHours = *t.TechnicianHours
+	}
+	if t.BookingPolicy != nil {
+		c.BookingPolicy = *t.BookingPolicy
+	}
+
+	return c
+}
@@ -0,0 +1,133 @@
+package conf_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ejv2/prepper/conf"
+)
+
+const (
+	TenantConfigPath    = "./testdata/tenants.json"
+	DuplicateConfigPath = "./testdata/tenants-duplicate.json"
+)
+
+func loadTenants(t *testing.T) conf.Config {
+	cfg, err := conf.NewConfig(TenantConfigPath)
+	if err != nil {
+		t.Fatalf("invalid tenant config: %s", err.Error())
+	}
+
+	return cfg
+}
+
+func TestFindTenant(t *testing.T) {
+	cfg := loadTenants(t)
+	if !cfg.HasTenants() {
+		t.Fatal("tenants not loaded")
+	}
+
+	testdata := []struct {
+		ID     string
+		Expect bool
+		Name   string
+	}{
+		{"north", true, "North Academy"},
+		{"south", true, "south"},
+		{"east", false, ""},
+		{"", false, ""},
+	}
+
+	for _, d := range testdata {
+		tn, ok := cfg.FindTenant(d.ID)
+		if ok != d.Expect {
+			t.Errorf("%q: wrong response (got %v, expect %v)", d.ID, ok, d.Expect)
+			continue
+		}
+		if ok && tn.DisplayName() != d.Name {
+			t.Errorf("%q: wrong name (got %q, expect %q)", d.ID, tn.DisplayName(), d.Name)
+		}
+	}
+
+	def, ok := cfg.DefaultTenant()
+	if !ok || def.ID != "north" {
+		t.Errorf("wrong default tenant (got %q, expect %q)", def.ID, "north")
+	}
+}
+
+func TestTenantForHost(t *testing.T) {
+	cfg := loadTenants(t)
+
+	testdata := []struct {
+		Host   string
+		Expect string
+	}{
+		{"prep.north.example.org", "north"},
+		{"prep.north.example.org:8080", "north"},
+		{"PREP.SOUTH.example.org", "south"},
+		{"south.example.org", "south"},
+		{"example.org", ""},
+		{"localhost:8080", ""},
+	}
+
+	for _, d := range testdata {
+		tn, ok := cfg.TenantForHost(d.Host)
+		if !ok {
+			if d.Expect != "" {
+				t.Errorf("%s: no tenant found (expect %q)", d.Host, d.Expect)
+			}
+			continue
+		}
+
+		if tn.ID != d.Expect {
+			t.Errorf("%s: wrong tenant (got %q, expect %q)", d.Host, tn.ID, d.Expect)
+		}
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	cfg := loadTenants(t)
+
+	north, _ := cfg.FindTenant("north")
+	nc := cfg.ForTenant(north)
+	if nc.HelpText != cfg.HelpText {
+		t.Errorf("north: help text not inherited (got %q)", nc.HelpText)
+	}
+	if nc.HasISAMS() {
+		t.Error("north: unexpected iSAMS config")
+	}
+	if p := (*nc.TimetableLayout)[0]; p.Name != "Period 1" {
+		t.Errorf("north: timetable not inherited (got %q)", p.Name)
+	}
+
+	south, _ := cfg.FindTenant("south")
+	sc := cfg.ForTenant(south)
+	if sc.HelpText != south.HelpText {
+		t.Errorf("south: help text not overridden (got %q)", sc.HelpText)
+	}
+	if !sc.HasISAMS() || sc.ISAMS.Domain != "south.isams.example.org" {
+		t.Error("south: iSAMS config not overridden")
+	}
+	if l := len(*sc.TimetableLayout); l != 2 {
+		t.Errorf("south: timetable not overridden (got %d periods)", l)
+	}
+	if !sc.AutoAssign || nc.AutoAssign {
+		t.Errorf("auto assignment not overridden (north %v, south %v)", nc.AutoAssign, sc.AutoAssign)
+	}
+	if nc.TechnicianDay() != 7*time.Hour+30*time.Minute || sc.TechnicianDay() != 6*time.Hour {
+		t.Errorf("technician hours not overridden (north %v, south %v)", nc.TechnicianDay(), sc.TechnicianDay())
+	}
+
+	// The top-level config must be left alone.
+	if cfg.HasISAMS() || len(*cfg.TimetableLayout) != 1 {
+		t.Error("top-level config modified by tenant")
+	}
+}
+
+func TestDuplicateTenantHost(t *testing.T) {
+	_, err := conf.NewConfig(DuplicateConfigPath)
+	if !errors.Is(err, conf.ErrTenantHost) {
+		t.Errorf("duplicate host accepted (got %v)", err)
+	}
+}
@@ -3,11 +3,13 @@ package conf
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
-// bsearch performs a binary search on the input slice of periods. This is
-// implemented recursively so take care on very large timetables.
+// bsearch performs a binary search on the input slice of periods. Where one
+// period ends as the next starts, the later is returned. This is implemented
+// recursively so take care on very large timetables.
 func bsearch(needle time.Time, stack []*Period) *Period {
 	if len(stack) == 0 {
 		return nil
@@ -18,6 +20,9 @@ func bsearch(needle time.Time, stack []*Period) *Period {
 
 	switch stack[i].Compare(needle) {
 	case 0:
+		if i+1 < len(stack) && stack[i+1] != nil && stack[i+1].Within(needle) {
+			return stack[i+1]
+		}
 		return stack[i]
 	case 1:
 		// Larger; take upper half
@@ -32,16 +37,56 @@ func bsearch(needle time.Time, stack []*Period) *Period {
 	return bsearch(needle, newstack)
 }
 
+// clockTime returns the time of day of t on the date and in the location in
+// which period times are parsed, so that the two may be compared.
+func clockTime(t time.Time) time.Time {
+	return time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
 // A TimetableLayout is a set of rules which define how a given day is broken
 // up into periods. It is configured losely in order to react to future
 // timetable layout changes. Periods must be configured in time order.
 type TimetableLayout []*Period
 
-// Returns the first period which is defined as containing this time, or else
-// nil if none do.
+// Returns the period which is defined as containing this time, or else nil if
+// none do. At the boundary between two periods, the one starting is returned.
 func (t TimetableLayout) FindPeriod(tm time.Time) *Period {
-	tt := time.Date(0, 1, 1, tm.Hour(), tm.Minute(), tm.Second(), 0, tm.Location())
-	return bsearch(tt, t)
+	return bsearch(clockTime(tm), t)
+}
+
+// A DateLayout replaces the timetable layout on a particular date, such as for
+// an exam day or a shortened last day of term.
+type DateLayout struct {
+	Date   CalendarDate    `json:"date"`
+	Layout TimetableLayout `json:"layout"`
+}
+
+// LayoutFor returns the timetable layout used on the day of t. A layout for
+// that date takes precedence over one for that day of the week, which takes
+// precedence over the default layout.
+func (c Config) LayoutFor(t time.Time) TimetableLayout {
+	y, m, d := t.Date()
+	for _, l := range c.DateLayouts {
+		ly, lm, ld := time.Time(l.Date).Date()
+		if y == ly && m == lm && d == ld {
+			return l.Layout
+		}
+	}
+
+	if l, ok := c.DayLayouts[strings.ToLower(t.Weekday().String())]; ok {
+		return l
+	}
+
+	if c.TimetableLayout == nil {
+		return nil
+	}
+	return *c.TimetableLayout
+}
+
+// FindPeriod returns the first period which contains this time in the layout
+// used on its day, or else nil if none do.
+func (c Config) FindPeriod(t time.Time) *Period {
+	return c.LayoutFor(t).FindPeriod(t)
 }
 
 // A Period is a time which spans from start to end in a given day. A nil
@@ -55,16 +100,14 @@ type Period struct {
 
 // Within returns true if the given instant in time lies within the period.
 // Date information is deliberately discarded, only considering the hour,
-// minute and second.
+// minute and second in the location of t.
 func (p *Period) Within(t time.Time) bool {
 	if p == nil {
 		return true
 	}
 
-	tw := time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
-	return (tw.After(time.Time(p.Start)) && tw.Before(time.Time(p.End))) ||
-		(tw == time.Time(p.Start)) ||
-		(tw == time.Time(p.End))
+	tw := clockTime(t)
+	return !tw.Before(time.Time(p.Start)) && !tw.After(time.Time(p.End))
 }
 
 // Compare returns 1 if t lies after this period, -1 if before and zero if
@@ -74,7 +117,7 @@ func (p *Period) Compare(t time.Time) int {
 		return 0
 	}
 
-	if t.Before(time.Time(p.Start)) {
+	if clockTime(t).Before(time.Time(p.Start)) {
 		return -1
 	}
 
@@ -108,3 +151,8 @@ func (p *PeriodTime) UnmarshalJSON(data []byte) error {
 	*p = PeriodTime(d)
 	return nil
 }
+
+// MarshalJSON formats the time in the same format as is parsed.
+func (p PeriodTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(p).Format(time.TimeOnly))
+}
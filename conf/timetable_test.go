@@ -1,6 +1,7 @@
 package conf_test
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -85,3 +86,131 @@ func TestFindPeriod(t *testing.T) {
 		}
 	}
 }
+
+func TestLayoutFor(t *testing.T) {
+	testdata := []struct {
+		Time         time.Time
+		ExpectPeriod string
+	}{
+		// Monday uses the default layout.
+		{time.Date(2026, 10, 19, 9, 20, 0, 0, time.Local), "Period 1"},
+		{time.Date(2026, 10, 19, 14, 30, 0, 0, time.Local), "Period 5"},
+		// Wednesday is shorter.
+		{time.Date(2026, 10, 21, 9, 5, 0, 0, time.Local), "Period 1"},
+		{time.Date(2026, 10, 21, 10, 50, 0, 0, time.Local), "Morning Break"},
+		{time.Date(2026, 10, 21, 14, 30, 0, 0, time.Local), "nil"},
+		// Friday starts with assembly.
+		{time.Date(2026, 10, 23, 9, 15, 0, 0, time.Local), "Assembly"},
+		// Wednesday 16/12/26 is overridden by date.
+		{time.Date(2026, 12, 16, 9, 15, 0, 0, time.Local), "End of Term Service"},
+		{time.Date(2026, 12, 16, 11, 0, 0, 0, time.Local), "nil"},
+	}
+
+	for _, test := range testdata {
+		p := Config.FindPeriod(test.Time)
+		if p == nil {
+			if test.ExpectPeriod != "nil" {
+				t.Errorf("%s: nil response for valid period", test.Time)
+			}
+			continue
+		}
+
+		if p.Name != test.ExpectPeriod {
+			t.Errorf("%s: incorrect period returned (expect %v, got %v)", test.Time, test.ExpectPeriod, p.Name)
+		}
+	}
+
+	if n := len(Config.LayoutFor(time.Date(2026, 10, 21, 0, 0, 0, 0, time.Local))); n != 6 {
+		t.Errorf("wrong Wednesday layout length (expect 6, got %d)", n)
+	}
+}
+
+func TestPeriodTimeJSON(t *testing.T) {
+	p := (*Config.TimetableLayout)[1]
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got conf.Period
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%s: %s", b, err)
+	}
+	if got.Name != p.Name || !time.Time(got.Start).Equal(time.Time(p.Start)) || !time.Time(got.End).Equal(time.Time(p.End)) {
+		t.Errorf("period did not survive round trip (expect %v, got %v)", *p, got)
+	}
+}
+
+func TestPeriodBoundaries(t *testing.T) {
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+
+	for _, zone := range []string{"UTC", "Europe/London", "America/New_York", "Asia/Kolkata"} {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Skipf("%s: %s", zone, err)
+		}
+		time.Local = loc
+
+		// Period 1 [09:10:00 - 10:05:00] on a Monday.
+		at := func(h, m int) time.Time {
+			return time.Date(2026, 10, 19, h, m, 0, 0, time.Local)
+		}
+		p := (*Config.TimetableLayout)[1]
+
+		withins := []struct {
+			Time   time.Time
+			Expect bool
+		}{
+			{at(9, 10), true},
+			{at(10, 5), true},
+			{at(9, 30), true},
+			{at(9, 9), false},
+			{at(10, 6), false},
+		}
+		for _, d := range withins {
+			if w := p.Within(d.Time); w != d.Expect {
+				t.Errorf("%s: wrong response: %v within %s (got %v, expect %v)", zone, d.Time, p.Name, w, d.Expect)
+			}
+		}
+
+		compares := []struct {
+			Time   time.Time
+			Expect int
+		}{
+			{at(9, 9), -1},
+			{at(9, 10), 0},
+			{at(10, 5), 0},
+			{at(10, 6), 1},
+		}
+		for _, d := range compares {
+			if c := p.Compare(d.Time); c != d.Expect {
+				t.Errorf("%s: wrong comparison: %v with %s (got %d, expect %d)", zone, d.Time, p.Name, c, d.Expect)
+			}
+		}
+
+		finds := []struct {
+			Time         time.Time
+			ExpectPeriod string
+		}{
+			// Periods which meet are changed over to the later.
+			{at(8, 35), "Registration"},
+			{at(9, 10), "Period 1"},
+			{at(10, 5), "Period 2"},
+			{at(11, 0), "Morning Break"},
+			{at(16, 0), "Period 6"},
+			{at(8, 34), "nil"},
+			{at(16, 1), "nil"},
+		}
+		for _, d := range finds {
+			got := "nil"
+			if p := Config.FindPeriod(d.Time); p != nil {
+				got = p.Name
+			}
+			if got != d.ExpectPeriod {
+				t.Errorf("%s: %s: incorrect period returned (expect %v, got %v)", zone, d.Time, d.ExpectPeriod, got)
+			}
+		}
+	}
+}
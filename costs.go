@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/notifications"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// costTerms is the number of past terms offered on the cost report.
+const costTerms = 6
+
+// costTerm parses the term given as the "term" query or form parameter, which
+// is the date of any day within it. If absent, the current term is used.
+func costTerm(c *gin.Context, cal data.AcademicCalendar) (data.Term, error) {
+	at := time.Now()
+	if st := c.Request.FormValue("term"); st != "" {
+		var err error
+		at, err = time.ParseInLocation(dateFormat, st, time.Local)
+		if err != nil {
+			return data.Term{}, err
+		}
+	}
+
+	return cal.TermOf(at), nil
+}
+
+// handleCosts is the handler for "/inventory/costs".
+//
+// Shows the cost of equipment booked during a term, with totals by
+// department, teacher and term and each department's budget.
+func handleCosts(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	cal, err := data.GetAcademicCalendar(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	term, err := costTerm(c, cal)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+		return
+	}
+
+	rep, err := data.GetCostReport(db, term)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	terms := cal.Recent(time.Now(), costTerms)
+	totals, err := data.GetTermCosts(db, terms)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	depts, err := data.GetDepartments(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Term        data.Term
+		Terms       []data.Term
+		TermTotals  []data.CostTotal
+		Report      data.CostReport
+		Departments []string
+		Warning     int
+		Error       string
+	}{ddat, term, terms, totals, rep, depts, data.BudgetWarning, c.Query("error")}
+
+	c.HTML(http.StatusOK, "costs.gohtml", dat)
+}
+
+// handleCostsBudget is the handler for POST "/inventory/costs/budget".
+//
+// Sets the budget of "department" for the term containing "term" to "limit"
+// in pounds. A blank or zero limit removes the budget.
+func handleCostsBudget(c *gin.Context) {
+	db := tenantDB(c)
+	cal, err := data.GetAcademicCalendar(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	term, err := costTerm(c, cal)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+		return
+	}
+	back := "/inventory/costs?term=" + url.QueryEscape(term.Start.Format(dateFormat))
+
+	dept := strings.TrimSpace(c.PostForm("department"))
+	if dept == "" {
+		c.Redirect(http.StatusFound, back+"&error="+url.QueryEscape("A department is required"))
+		return
+	}
+
+	limit, err := data.ParsePrice(c.PostForm("limit"))
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"&error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	if err := data.SetBudget(db, dept, term, limit); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, back)
+}
+
+// checkBudget warns technicians if the given booking has taken its
+// department's spending for the term past the warning level or over its
+// budget. Each level is only warned about by the booking which crosses it.
+func checkBudget(db *gorm.DB, bk data.Booking) {
+	bk, err := data.GetBooking(db, bk.ID)
+	if err != nil {
+		log.Println("check budget:", err)
+		return
+	}
+
+	cal, err := data.GetAcademicCalendar(db)
+	if err != nil {
+		log.Println("check budget:", err)
+		return
+	}
+
+	dept := bk.Department()
+	term := cal.TermOf(bk.StartTime.In(time.Local))
+	after, err := data.GetDepartmentSpend(db, dept, term)
+	if err != nil {
+		log.Println("check budget:", err)
+		return
+	}
+	before := after
+	before.Cost -= bk.Cost
+
+	var msg string
+	switch {
+	case after.Over() && !before.Over():
+		msg = "is over"
+	case after.Warning() && !before.Warning():
+		msg = fmt.Sprint("has used ", after.Percent(), "% of")
+	default:
+		return
+	}
+
+	urs, err := data.GetRoleUsers(db, data.UserTechnician)
+	if err != nil {
+		log.Println("check budget:", err)
+		return
+	}
+
+	for _, u := range urs {
+		Notifications.PushUser(u.ID, notifications.Notification{
+			Title:  fmt.Sprint(dept, " Budget Warning"),
+			Body:   fmt.Sprint(dept, " ", msg, " its ", term, " budget, having spent ", after.Cost, " of ", after.Limit, "."),
+			Type:   notifications.TypeImportant,
+			Action: "/inventory/costs?term=" + url.QueryEscape(term.Start.Format(dateFormat)),
+			Time:   time.Now(),
+		})
+	}
+}
@@ -16,6 +16,8 @@ type DashboardData struct {
 	User     data.User
 	Greeting string
 	Time     time.Time
+	// Tenanted is true if this installation serves several schools.
+	Tenanted bool
 }
 
 // NewDashboardData constructs a new DashboardData object for use by the
@@ -40,7 +42,7 @@ func NewDashboardData(s session.Session) (DashboardData, error) {
 		g = "Good afternoon"
 	}
 
-	return DashboardData{u, g, time.Now().Local()}, nil
+	return DashboardData{u, g, time.Now().Local(), Config.HasTenants()}, nil
 }
 
 // handleDashboard is the handler for "/dashboard/"
@@ -49,6 +51,7 @@ func NewDashboardData(s session.Session) (DashboardData, error) {
 func handleDashboard(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -60,38 +63,38 @@ func handleDashboard(c *gin.Context) {
 	var obk []data.Booking
 	var dbk []data.Booking
 	if ddat.User.IsTechnician() {
-		bk, err = data.GetBookings(Database)
+		bk, err = data.GetBookings(db)
 		if err != nil {
 			internalError(c, err)
 			return
 		}
 
-		obk, err = data.GetOngoingBookings(Database)
+		obk, err = data.GetOngoingBookings(db)
 		if err != nil {
 			internalError(c, err)
 			return
 		}
 
-		dbk, err = data.GetBookingsRange(Database, time.Now().Truncate(24*time.Hour), time.Now().Truncate(24*time.Hour).Add(24*time.Hour))
+		dbk, err = data.GetBookingsRange(db, time.Now().Truncate(24*time.Hour), time.Now().Truncate(24*time.Hour).Add(24*time.Hour))
 		if err != nil {
 			internalError(c, err)
 			return
 		}
 	}
 
-	ubk, err := data.GetPersonalBookingsRange(Database, s.UserID,
+	ubk, err := data.GetPersonalBookingsRange(db, s.UserID,
 		time.Now().Truncate(24*time.Hour), time.Now().Truncate(24*time.Hour).Add(24*time.Hour))
 	if err != nil {
 		internalError(c, err)
 		return
 	}
 
-	uuobk, err := data.GetCurrentBooking(Database, s.UserID)
+	uuobk, err := data.GetCurrentBooking(db, s.UserID)
 	var uoba *data.Activity
 	if err != nil {
 		uoba = nil
 	} else {
-		uuoba := uuobk.Activity.Parent(Database)
+		uuoba := uuobk.Activity.Parent(db)
 		uoba = &uuoba
 	}
 
@@ -0,0 +1,335 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of dates in the academic calendar.
+const (
+	// A term, during which the school is open.
+	DateTerm DateKind = iota
+	// A holiday, such as half-term or a bank holiday, on which the school
+	// is closed.
+	DateHoliday
+	// A staff training day, on which there are no lessons.
+	DateInset
+)
+
+// Academic calendar errors.
+var (
+	ErrNoSuchSchoolDate = errors.New("school date not found")
+	ErrSchoolDateName   = errors.New("school date must be named")
+	ErrSchoolDateRange  = errors.New("school date ends before it starts")
+)
+
+// A DateKind is the kind of a span of days in the academic calendar.
+type DateKind uint8
+
+func (k DateKind) String() string {
+	switch k {
+	case DateTerm:
+		return "Term"
+	case DateHoliday:
+		return "Holiday"
+	case DateInset:
+		return "INSET Day"
+	default:
+		return "Unknown"
+	}
+}
+
+// DateKinds are the kinds of school date, in order of selection.
+var DateKinds = []DateKind{DateTerm, DateHoliday, DateInset}
+
+// A SchoolDate is a span of days in the academic calendar: either a term, or
+// a holiday or INSET day on which the school is closed.
+type SchoolDate struct {
+	*gorm.Model
+	Tenancy
+
+	Name string
+	Kind DateKind
+	// First and last days, inclusive, at midnight local time.
+	Start time.Time
+	End   time.Time
+
+	// ID of the term in iSAMS, if imported.
+	IsamsID *uint64 `gorm:"index"`
+}
+
+// Closed returns true if the school is closed for bookings on these dates.
+func (d SchoolDate) Closed() bool {
+	return d.Kind != DateTerm
+}
+
+// Contains returns true if the given time falls on one of these dates.
+func (d SchoolDate) Contains(t time.Time) bool {
+	return !t.Before(d.Start) && t.Before(d.End.AddDate(0, 0, 1))
+}
+
+// Days returns the number of days spanned.
+func (d SchoolDate) Days() int {
+	// Rounded to whole days, as dates may cross a change in daylight saving.
+	return int(math.Round(d.End.Sub(d.Start).Hours()/24)) + 1
+}
+
+// Overlaps returns true if any of these dates fall within [start, end).
+func (d SchoolDate) Overlaps(start, end time.Time) bool {
+	return d.Start.Before(end) && start.Before(d.End.AddDate(0, 0, 1))
+}
+
+// GetSchoolDates returns every date in the academic calendar, in order of
+// start.
+func GetSchoolDates(db *gorm.DB) ([]SchoolDate, error) {
+	var ds []SchoolDate
+	if err := db.Order("start").Order("kind").Find(&ds).Error; err != nil {
+		return nil, fmt.Errorf("get school dates: sql error: %w", err)
+	}
+
+	return ds, nil
+}
+
+// SaveSchoolDate validates and saves a date in the academic calendar. Times
+// are truncated to whole days.
+func SaveSchoolDate(db *gorm.DB, d *SchoolDate) error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return ErrSchoolDateName
+	}
+
+	d.Start, d.End = startOfDay(d.Start), startOfDay(d.End)
+	if d.End.Before(d.Start) {
+		return fmt.Errorf("save school date %s: %w", d.Name, ErrSchoolDateRange)
+	}
+
+	if d.Model == nil {
+		d.Model = &gorm.Model{}
+	}
+	if err := db.Save(d).Error; err != nil {
+		return fmt.Errorf("save school date %s: sql error: %w", d.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteSchoolDate removes a date from the academic calendar.
+func DeleteSchoolDate(db *gorm.DB, id uint) error {
+	res := db.Delete(&SchoolDate{}, id)
+	if err := res.Error; err != nil {
+		return fmt.Errorf("delete school date %d: sql error: %w", id, err)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("delete school date %d: %w", id, ErrNoSuchSchoolDate)
+	}
+
+	return nil
+}
+
+// ImportSchoolDates adds or updates dates imported from iSAMS, matching them
+// by iSAMS ID. Dates without an iSAMS ID are ignored. The number of dates
+// added or changed is returned.
+func ImportSchoolDates(db *gorm.DB, dates []SchoolDate) (int, error) {
+	existing, err := GetSchoolDates(db)
+	if err != nil {
+		return 0, fmt.Errorf("import school dates: %w", err)
+	}
+
+	ids := make(map[uint64]SchoolDate, len(existing))
+	for _, d := range existing {
+		if d.IsamsID != nil {
+			ids[*d.IsamsID] = d
+		}
+	}
+
+	n := 0
+	for _, d := range dates {
+		if d.IsamsID == nil {
+			continue
+		}
+
+		if old, ok := ids[*d.IsamsID]; ok {
+			if old.Name == d.Name && old.Kind == d.Kind && old.Start.Equal(startOfDay(d.Start)) && old.End.Equal(startOfDay(d.End)) {
+				continue
+			}
+			d.Model = old.Model
+		}
+
+		if err := SaveSchoolDate(db, &d); err != nil {
+			return n, fmt.Errorf("import school dates: %w", err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// startOfDay returns midnight at the start of the day of t, in local time.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// An AcademicCalendar is the terms of the school year, along with the days on
+// which the school is closed within them.
+type AcademicCalendar struct {
+	Terms    []SchoolDate
+	Closures []SchoolDate
+}
+
+// GetAcademicCalendar loads the academic calendar.
+func GetAcademicCalendar(db *gorm.DB) (AcademicCalendar, error) {
+	ds, err := GetSchoolDates(db)
+	if err != nil {
+		return AcademicCalendar{}, err
+	}
+
+	return NewAcademicCalendar(ds), nil
+}
+
+// NewAcademicCalendar sorts dates into an academic calendar.
+func NewAcademicCalendar(ds []SchoolDate) AcademicCalendar {
+	var cal AcademicCalendar
+	for _, d := range ds {
+		if d.Closed() {
+			cal.Closures = append(cal.Closures, d)
+		} else {
+			cal.Terms = append(cal.Terms, d)
+		}
+	}
+	sort.SliceStable(cal.Terms, func(i, j int) bool {
+		return cal.Terms[i].Start.Before(cal.Terms[j].Start)
+	})
+
+	return cal
+}
+
+// TermOf returns the term which contains the given time. Each term runs until
+// the start of the next, so holidays count towards the term before them.
+// Outside of the configured terms, terms are assumed to start in January,
+// April and September, as with the package-level TermOf, with the assumed
+// terms either side stretched or shortened to meet the configured ones.
+func (c AcademicCalendar) TermOf(t time.Time) Term {
+	if len(c.Terms) == 0 {
+		return TermOf(t)
+	}
+
+	first := c.Terms[0].Start
+	if t.Before(first) {
+		term := TermOf(t)
+		if term.End.After(first) {
+			// Days before the first term count towards the one before.
+			prev := TermOf(term.Start.Add(-time.Hour))
+			term = Term{Name: prev.Name, Start: prev.Start, End: first}
+		}
+		return term
+	}
+
+	for i := len(c.Terms) - 1; i >= 0; i-- {
+		d := c.Terms[i]
+		if t.Before(d.Start) {
+			continue
+		}
+
+		var end time.Time
+		if i+1 < len(c.Terms) {
+			end = c.Terms[i+1].Start
+		} else {
+			end = d.End.AddDate(0, 0, 1)
+			if next := TermOf(d.End).End; next.After(end) {
+				end = next
+			}
+		}
+		if t.Before(end) {
+			term := Term{Name: d.Name, Start: d.Start, End: end}
+			if d.Model != nil {
+				term.ID = d.ID
+			}
+			return term
+		}
+
+		term := TermOf(t)
+		if term.Start.Before(end) {
+			term.Start = end
+		}
+		return term
+	}
+
+	return TermOf(t)
+}
+
+// Previous returns the term before the given one.
+func (c AcademicCalendar) Previous(t Term) Term {
+	return c.TermOf(t.Start.Add(-time.Hour))
+}
+
+// Closure returns the reason for which the school is closed at the given time.
+// Between configured terms, the school is closed for the holidays.
+func (c AcademicCalendar) Closure(t time.Time) (SchoolDate, bool) {
+	for _, d := range c.Closures {
+		if d.Contains(t) {
+			return d, true
+		}
+	}
+
+	if len(c.Terms) == 0 || t.Before(c.Terms[0].Start) || !t.Before(c.Terms[len(c.Terms)-1].End.AddDate(0, 0, 1)) {
+		return SchoolDate{}, false
+	}
+	for _, d := range c.Terms {
+		if d.Contains(t) {
+			return SchoolDate{}, false
+		}
+	}
+
+	day := startOfDay(t)
+	return SchoolDate{Name: "School Holidays", Kind: DateHoliday, Start: day, End: day}, true
+}
+
+// ClosuresBetween returns the closures overlapping [start, end), including
+// the holidays between terms, in order of start.
+func (c AcademicCalendar) ClosuresBetween(start, end time.Time) []SchoolDate {
+	var ds []SchoolDate
+	for _, d := range c.Closures {
+		if d.Overlaps(start, end) {
+			ds = append(ds, d)
+		}
+	}
+	for i := 1; i < len(c.Terms); i++ {
+		gap := SchoolDate{
+			Name:  "School Holidays",
+			Kind:  DateHoliday,
+			Start: c.Terms[i-1].End.AddDate(0, 0, 1),
+			End:   c.Terms[i].Start.AddDate(0, 0, -1),
+		}
+		if !gap.End.Before(gap.Start) && gap.Overlaps(start, end) {
+			ds = append(ds, gap)
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].Start.Before(ds[j].Start)
+	})
+
+	return ds
+}
+
+// Recent returns n terms, starting with that containing t and working
+// backwards.
+func (c AcademicCalendar) Recent(t time.Time, n int) []Term {
+	terms := make([]Term, n)
+	if n == 0 {
+		return terms
+	}
+
+	terms[0] = c.TermOf(t)
+	for i := 1; i < n; i++ {
+		terms[i] = c.Previous(terms[i-1])
+	}
+
+	return terms
+}
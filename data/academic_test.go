@@ -0,0 +1,126 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// day returns midnight local time on the given date.
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// testCalendar is the academic year used for tests: two terms, with a
+// half-term and an INSET day in the first.
+var testCalendar = NewAcademicCalendar([]SchoolDate{
+	{Model: &gorm.Model{ID: 1}, Name: "Autumn 2026", Kind: DateTerm, Start: day(2026, 9, 3), End: day(2026, 12, 16)},
+	{Model: &gorm.Model{ID: 2}, Name: "Spring 2027", Kind: DateTerm, Start: day(2027, 1, 5), End: day(2027, 3, 26)},
+	{Model: &gorm.Model{ID: 3}, Name: "Half Term", Kind: DateHoliday, Start: day(2026, 10, 26), End: day(2026, 10, 30)},
+	{Model: &gorm.Model{ID: 4}, Name: "Training Day", Kind: DateInset, Start: day(2026, 11, 20), End: day(2026, 11, 20)},
+})
+
+func TestSchoolDateDays(t *testing.T) {
+	testdata := []struct {
+		Name   string
+		Date   SchoolDate
+		Expect int
+	}{
+		{"One day", SchoolDate{Start: day(2026, 11, 20), End: day(2026, 11, 20)}, 1},
+		{"Half-term", SchoolDate{Start: day(2026, 10, 26), End: day(2026, 10, 30)}, 5},
+		// Clocks change on the last Sundays of March and October.
+		{"Spring forward", SchoolDate{Start: day(2027, 3, 27), End: day(2027, 4, 9)}, 14},
+		{"Fall back", SchoolDate{Start: day(2026, 10, 24), End: day(2026, 11, 1)}, 9},
+		{"Whole term", SchoolDate{Start: day(2027, 1, 5), End: day(2027, 3, 26)}, 81},
+	}
+
+	for _, d := range testdata {
+		if got := d.Date.Days(); got != d.Expect {
+			t.Errorf("%s: expected %d days, got %d", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestAcademicTermOf(t *testing.T) {
+	autumn := Term{ID: 1, Name: "Autumn 2026", Start: day(2026, 9, 3), End: day(2027, 1, 5)}
+	spring := Term{ID: 2, Name: "Spring 2027", Start: day(2027, 1, 5), End: day(2027, 4, 1)}
+
+	testdata := []struct {
+		Name   string
+		Time   time.Time
+		Expect Term
+	}{
+		{"First day of term", day(2026, 9, 3), autumn},
+		{"Last day of term", day(2026, 12, 16).Add(15 * time.Hour), autumn},
+		{"Half-term", day(2026, 10, 28).Add(9 * time.Hour), autumn},
+		{"INSET day", day(2026, 11, 20).Add(9 * time.Hour), autumn},
+		{"Between terms", day(2026, 12, 25), autumn},
+		{"Last day of holidays", day(2027, 1, 4).Add(23 * time.Hour), autumn},
+		{"First day of next term", day(2027, 1, 5), spring},
+		// Outside of the configured terms, the assumed terms meet them.
+		{"Before first term", day(2026, 9, 2), Term{Name: "Summer", Start: day(2026, 4, 1), End: day(2026, 9, 3)}},
+		{"After last term", day(2027, 5, 1), Term{Name: "Summer", Start: day(2027, 4, 1), End: day(2027, 9, 1)}},
+	}
+
+	for _, test := range testdata {
+		got := testCalendar.TermOf(test.Time)
+		if got.ID != test.Expect.ID || got.Name != test.Expect.Name || !got.Start.Equal(test.Expect.Start) || !got.End.Equal(test.Expect.End) {
+			t.Errorf("%s: expected %s (%d, %s to %s), got %s (%d, %s to %s)", test.Name,
+				test.Expect, test.Expect.ID, test.Expect.Start.Format(time.DateOnly), test.Expect.End.Format(time.DateOnly),
+				got, got.ID, got.Start.Format(time.DateOnly), got.End.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestAcademicClosure(t *testing.T) {
+	testdata := []struct {
+		Name   string
+		Time   time.Time
+		Expect string
+	}{
+		{"First day of term", day(2026, 9, 3).Add(9 * time.Hour), ""},
+		{"Last day of term", day(2026, 12, 16).Add(15 * time.Hour), ""},
+		{"First day of half-term", day(2026, 10, 26), "Half Term"},
+		{"Last day of half-term", day(2026, 10, 30).Add(23 * time.Hour), "Half Term"},
+		{"After half-term", day(2026, 11, 2), ""},
+		{"INSET day", day(2026, 11, 20).Add(9 * time.Hour), "Training Day"},
+		{"Between terms", day(2026, 12, 25), "School Holidays"},
+		{"First day of next term", day(2027, 1, 5), ""},
+		// Holidays are only known between configured terms.
+		{"Before first term", day(2026, 8, 1), ""},
+		{"After last term", day(2027, 4, 1), ""},
+	}
+
+	for _, test := range testdata {
+		d, closed := testCalendar.Closure(test.Time)
+		if closed != (test.Expect != "") || d.Name != test.Expect {
+			t.Errorf("%s: expected closure %q, got %q (closed %v)", test.Name, test.Expect, d.Name, closed)
+		}
+	}
+}
+
+func TestAcademicClosuresBetween(t *testing.T) {
+	got := testCalendar.ClosuresBetween(day(2026, 10, 1), day(2027, 1, 10))
+	expect := []SchoolDate{
+		{Name: "Half Term", Start: day(2026, 10, 26), End: day(2026, 10, 30)},
+		{Name: "Training Day", Start: day(2026, 11, 20), End: day(2026, 11, 20)},
+		{Name: "School Holidays", Start: day(2026, 12, 17), End: day(2027, 1, 4)},
+	}
+
+	if len(got) != len(expect) {
+		t.Fatalf("expected %d closures, got %d: %v", len(expect), len(got), got)
+	}
+	for i, d := range got {
+		if d.Name != expect[i].Name || !d.Start.Equal(expect[i].Start) || !d.End.Equal(expect[i].End) {
+			t.Errorf("closure %d: expected %s (%s to %s), got %s (%s to %s)", i,
+				expect[i].Name, expect[i].Start.Format(time.DateOnly), expect[i].End.Format(time.DateOnly),
+				d.Name, d.Start.Format(time.DateOnly), d.End.Format(time.DateOnly))
+		}
+	}
+
+	// Closures ending before or starting after the period are left out.
+	if got := testCalendar.ClosuresBetween(day(2026, 11, 2), day(2026, 11, 20)); len(got) != 0 {
+		t.Errorf("expected no closures from 02/11 to 20/11 exclusive, got %v", got)
+	}
+}
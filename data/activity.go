@@ -31,12 +31,23 @@ var (
 // required etc.
 type Activity struct {
 	*gorm.Model
+	Tenancy
 
 	Title       string
 	Description string
 
 	// To get categories, use SELECT DISTINCT.
 	Category string
+	// Department charged for bookings of this activity. If empty, the
+	// owner's department is charged.
+	Department string
+	// Prep room which prepares bookings of this activity. If nil, the
+	// owner's prep room is used.
+	PrepRoomID *uint
+	PrepRoom   *PrepRoom
+	// Estimated time taken to prepare this activity, in minutes. Zero if
+	// not estimated.
+	PrepMinutes Minutes
 
 	// Determines who owns and can edit the activity.
 	OwnerID uint
@@ -98,11 +109,16 @@ func (a Activity) Temp() Activity {
 	act.Model = nil
 	act.OwnerID = 0
 	act.Owner = User{}
+	act.PrepRoom = nil
 
-	// Update equipment set
+	// Update equipment set. Batches and units are issued per booking, so
+	// are not copied.
 	for i := range act.Equipment {
 		act.Equipment[i].Model = nil
 		act.Equipment[i].ActivityID = 0
+		act.Equipment[i].BatchID = nil
+		act.Equipment[i].Batch = nil
+		act.Equipment[i].Units = nil
 	}
 
 	return act
@@ -155,6 +171,17 @@ func (a Activity) TotalQuantity() uint {
 	return tot
 }
 
+// Hazards returns the combined GHS hazards of every item requisitioned for
+// this activity.
+func (a Activity) Hazards() Hazards {
+	h := Hazards{}
+	for _, s := range a.Equipment {
+		h = h.Merge(s.Item.Hazards())
+	}
+
+	return h
+}
+
 // GetActivity retrieves an activity from the database by ID, with all foreign
 // keys joined.
 func GetActivity(db *gorm.DB, id uint) (Activity, error) {
@@ -211,6 +238,16 @@ func GetPermanentActivities(db *gorm.DB) ([]Activity, error) {
 	return acts, nil
 }
 
+// IssuedUnits returns every serialised unit issued for this activity.
+func (a Activity) IssuedUnits() []EquipmentUnit {
+	var u []EquipmentUnit
+	for _, s := range a.Equipment {
+		u = append(u, s.Units...)
+	}
+
+	return u
+}
+
 // ItemQuantity returns the number of the given item requisitioned for this
 // activity, or zero if this item is not in use by this activity.
 func (a Activity) ItemQuantity(i EquipmentItem) uint {
@@ -245,6 +282,7 @@ func (a Activity) Parent(db *gorm.DB) Activity {
 // EquipmentSet is the link table for equipment used in an activity.
 type EquipmentSet struct {
 	*gorm.Model
+	Tenancy
 	ActivityID uint
 
 	// Quantity requisitioned for this activity.
@@ -255,6 +293,32 @@ type EquipmentSet struct {
 
 	ItemID uint
 	Item   EquipmentItem
+
+	// Batch of the item which was issued for this set, if recorded by the
+	// technician when the booking was prepared.
+	BatchID *uint
+	Batch   *ChemicalBatch
+
+	// Serialised units of the item which were issued for this set, if any.
+	Units []EquipmentUnit `gorm:"many2many:set_units"`
+}
+
+// UsesBatch returns true if the batch with the given ID was recorded as issued
+// for this set.
+func (e EquipmentSet) UsesBatch(id uint) bool {
+	return e.BatchID != nil && *e.BatchID == id
+}
+
+// UsesUnit returns true if the unit with the given ID was issued for this
+// set.
+func (e EquipmentSet) UsesUnit(id uint) bool {
+	for _, u := range e.Units {
+		if u.ID == id {
+			return true
+		}
+	}
+
+	return false
 }
 
 // VisualIndex is very useless in Go, but very useful in Go templates where
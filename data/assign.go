@@ -0,0 +1,140 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Assignment errors.
+var (
+	ErrNotTechnician = errors.New("bookings may only be assigned to technicians")
+	ErrNoTechnicians = errors.New("no technicians available")
+)
+
+// AssigneeName returns the name of the technician assigned to the booking, or
+// a placeholder if it is unassigned.
+func (b Booking) AssigneeName() string {
+	if b.Assignee == nil {
+		return "Unassigned"
+	}
+
+	return b.Assignee.DisplayName()
+}
+
+// AssignedTo returns true if the booking is assigned to the given user.
+func (b Booking) AssignedTo(id uint) bool {
+	return b.AssigneeID != nil && *b.AssigneeID == id
+}
+
+// AssignBooking assigns the booking with the given ID to the given
+// technician, or leaves it unassigned if tech is nil. The updated booking is
+// returned.
+func AssignBooking(db *gorm.DB, id uint, tech *uint) (Booking, error) {
+	if tech != nil {
+		u, err := GetUser(db, *tech)
+		if err != nil {
+			return Booking{}, fmt.Errorf("assign booking %d: %w", id, err)
+		}
+		if !u.IsTechnician() {
+			return Booking{}, fmt.Errorf("assign booking %d to %s: %w", id, u.Username, ErrNotTechnician)
+		}
+	}
+
+	bk, err := GetBooking(db, id)
+	if err != nil {
+		return bk, fmt.Errorf("assign booking %d: %w", id, err)
+	}
+
+	if err := db.Model(&Booking{}).Where("id = ?", id).Update("assignee_id", tech).Error; err != nil {
+		return bk, fmt.Errorf("assign booking %d: sql error: %w", id, err)
+	}
+
+	return GetBooking(db, id)
+}
+
+// GetAssigneeLoads returns the number of outstanding bookings assigned to each
+// technician which start within the given range, keyed by technician ID.
+// Outstanding bookings are those which are not yet ready or rejected.
+func GetAssigneeLoads(db *gorm.DB, start, end time.Time) (map[uint]int64, error) {
+	var rows []struct {
+		AssigneeID uint
+		Load       int64
+	}
+
+	res := db.Model(&Booking{}).
+		Select("assignee_id, COUNT(*) AS `load`").
+		Where("assignee_id IS NOT NULL").
+		Where("start_time >= ? AND start_time < ?", start, end).
+		Where("status IN ?", []BookingStatus{BookingStatusPending, BookingStatusProgress}).
+		Group("assignee_id").
+		Scan(&rows)
+	if err := res.Error; err != nil {
+		return nil, fmt.Errorf("get assignee loads: sql error: %w", err)
+	}
+
+	loads := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		loads[r.AssigneeID] = r.Load
+	}
+
+	return loads, nil
+}
+
+// PickAssignee chooses the technician best placed to prepare a booking for
+// the given prep room. Specialists who work in that prep room are preferred
+// over those who work in every prep room; amongst them, the technician with
+// the least load is chosen.
+func PickAssignee(techs []User, loads map[uint]int64, prep *uint) (User, error) {
+	pool := make([]User, 0, len(techs))
+	if prep != nil {
+		for _, t := range techs {
+			if t.PrepRoomID != nil && *t.PrepRoomID == *prep {
+				pool = append(pool, t)
+			}
+		}
+	}
+	if len(pool) == 0 {
+		pool = append(pool, techs...)
+	}
+	if len(pool) == 0 {
+		return User{}, ErrNoTechnicians
+	}
+
+	sort.SliceStable(pool, func(i, j int) bool {
+		li, lj := loads[pool[i].ID], loads[pool[j].ID]
+		if li != lj {
+			return li < lj
+		}
+
+		return pool[i].ID < pool[j].ID
+	})
+
+	return pool[0], nil
+}
+
+// AutoAssign assigns the booking to the technician of its prep room with the
+// least outstanding work on the day of the booking, as chosen by
+// PickAssignee. The updated booking is returned.
+func AutoAssign(db *gorm.DB, bk Booking) (Booking, error) {
+	techs, err := PrepRoomTechnicians(db, bk.PrepRoomID)
+	if err != nil {
+		return bk, fmt.Errorf("auto assign booking %d: %w", bk.ID, err)
+	}
+
+	day := startOfDay(bk.StartTime)
+	loads, err := GetAssigneeLoads(db, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return bk, fmt.Errorf("auto assign booking %d: %w", bk.ID, err)
+	}
+
+	u, err := PickAssignee(techs, loads, bk.PrepRoomID)
+	if err != nil {
+		return bk, fmt.Errorf("auto assign booking %d: %w", bk.ID, err)
+	}
+
+	return AssignBooking(db, bk.ID, &u.ID)
+}
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestPickAssignee(t *testing.T) {
+	chem, phys := uint(1), uint(2)
+	tech := func(id uint, prep *uint) User {
+		return User{Model: &gorm.Model{ID: id}, Role: UserTechnician, PrepRoomID: prep}
+	}
+	// Technicians 1 and 2 work in chemistry, 3 in physics and 4 and 5 in
+	// every prep room.
+	techs := []User{tech(5, nil), tech(2, &chem), tech(4, nil), tech(1, &chem), tech(3, &phys)}
+
+	testdata := []struct {
+		Name   string
+		Techs  []User
+		Loads  map[uint]int64
+		Prep   *uint
+		Expect uint
+		Err    error
+	}{
+		{"Specialists preferred", techs, nil, &phys, 3, nil},
+		{"Busy specialists preferred", techs, map[uint]int64{3: 6}, &phys, 3, nil},
+		{"Least loaded specialist", techs, map[uint]int64{1: 3, 2: 1}, &chem, 2, nil},
+		{"Specialists tied by ID", techs, map[uint]int64{1: 2, 2: 2}, &chem, 1, nil},
+		{"Shared booking by load", techs, map[uint]int64{1: 1, 2: 1, 3: 1, 4: 1}, nil, 5, nil},
+		{"Shared booking tied by ID", techs, nil, nil, 1, nil},
+		{"No specialists", techs[:3], map[uint]int64{5: 2}, &phys, 2, nil},
+		{"No technicians", nil, nil, &chem, 0, ErrNoTechnicians},
+	}
+
+	for _, d := range testdata {
+		u, err := PickAssignee(d.Techs, d.Loads, d.Prep)
+		if !errors.Is(err, d.Err) {
+			t.Errorf("%s: expected error %v, got %v", d.Name, d.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if u.ID != d.Expect {
+			t.Errorf("%s: expected technician %d, got %d", d.Name, d.Expect, u.ID)
+		}
+	}
+}
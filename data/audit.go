@@ -0,0 +1,184 @@
+package data
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions.
+const (
+	AuditCreate = iota
+	AuditUpdate
+	AuditDelete
+)
+
+// AuditAction is the enumerator type for the kind of change recorded by an
+// audit entry.
+type AuditAction uint8
+
+func (a AuditAction) String() string {
+	switch a {
+	case AuditCreate:
+		return "Created"
+	case AuditUpdate:
+		return "Updated"
+	case AuditDelete:
+		return "Deleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// An ItemAudit records a single change made to an inventory item, along with
+// who made it and why. The name of the item is copied so that entries for
+// deleted items remain readable.
+type ItemAudit struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID   uint
+	ItemName string
+
+	Action AuditAction
+	Reason string
+
+	UserID uint
+	User   User
+
+	Changes []ItemChange `gorm:"foreignKey:AuditID"`
+}
+
+// An ItemChange is the change of a single field of an item within an audit
+// entry. Values are stored as they are displayed.
+type ItemChange struct {
+	*gorm.Model
+	Tenancy
+	AuditID uint
+
+	Field string
+	Old   string
+	New   string
+}
+
+// An AuditFilter restricts the audit entries returned by GetItemAudits. Zero
+// fields are not filtered on.
+type AuditFilter struct {
+	ItemID uint
+	UserID uint
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// auditValue returns a field value as displayed in an audit entry. Pointers
+// are followed, with nil shown as empty. False is returned for fields which
+// are not recorded, such as relations to other models.
+func auditValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.Type().Elem().Kind() == reflect.Struct {
+			return "", false
+		}
+		if v.IsNil() {
+			return "", true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		return "", false
+	default:
+		return fmt.Sprint(v.Interface()), true
+	}
+}
+
+// DiffItems returns the fields which differ between two versions of an item.
+// Database bookkeeping fields and relations are ignored.
+func DiffItems(before, after EquipmentItem) []ItemChange {
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	t := bv.Type()
+
+	ch := make([]ItemChange, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		o, ok := auditValue(bv.Field(i))
+		if !ok {
+			continue
+		}
+		n, _ := auditValue(av.Field(i))
+		if o != n {
+			ch = append(ch, ItemChange{Field: f.Name, Old: o, New: n})
+		}
+	}
+
+	return ch
+}
+
+// AuditItem records a change to an item made by the given user. For created
+// items, before should be the zero item; for deleted items, after should be.
+// Updates which change nothing are not recorded.
+func AuditItem(db *gorm.DB, user uint, action AuditAction, reason string, before, after EquipmentItem) error {
+	a := ItemAudit{
+		Model:   &gorm.Model{},
+		Action:  action,
+		Reason:  reason,
+		UserID:  user,
+		Changes: DiffItems(before, after),
+	}
+
+	switch action {
+	case AuditDelete:
+		a.ItemID, a.ItemName = before.ID, before.Name
+		// The whole item is gone, so listing every field says nothing.
+		a.Changes = nil
+	default:
+		a.ItemID, a.ItemName = after.ID, after.Name
+		if action == AuditUpdate && len(a.Changes) == 0 {
+			return nil
+		}
+	}
+
+	for i := range a.Changes {
+		a.Changes[i].Model = &gorm.Model{}
+	}
+
+	if err := db.Omit("User").Create(&a).Error; err != nil {
+		return fmt.Errorf("audit item %d: sql error: %w", a.ItemID, err)
+	}
+
+	return nil
+}
+
+// GetItemAudits returns audit entries matching the filter, most recent first.
+func GetItemAudits(db *gorm.DB, f AuditFilter) ([]ItemAudit, error) {
+	q := db.Model(&ItemAudit{}).Joins("User").Preload("Changes")
+	if f.ItemID != 0 {
+		q = q.Where("item_audits.item_id = ?", f.ItemID)
+	}
+	if f.UserID != 0 {
+		q = q.Where("item_audits.user_id = ?", f.UserID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("item_audits.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("item_audits.created_at < ?", f.To)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	a := make([]ItemAudit, 0, f.Limit)
+	if err := q.Order("item_audits.created_at DESC").Find(&a).Error; err != nil {
+		return a, fmt.Errorf("get item audits: sql error: %w", err)
+	}
+
+	return a, nil
+}
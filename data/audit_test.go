@@ -0,0 +1,52 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestDiffItems(t *testing.T) {
+	one, two := uint(1), uint(2)
+	base := EquipmentItem{Model: &gorm.Model{ID: 4}, Name: "Beaker", Quantity: 10, Available: true, PrepRoomID: &one, PrepRoom: &PrepRoom{Name: "Chemistry"}}
+
+	tests := []struct {
+		Name   string
+		Change func(e *EquipmentItem)
+		Expect []ItemChange
+	}{
+		{"Unchanged", func(e *EquipmentItem) {}, []ItemChange{}},
+		{"Copied pointers", func(e *EquipmentItem) {
+			id := *e.PrepRoomID
+			e.PrepRoomID = &id
+			e.PrepRoom = &PrepRoom{Name: "Chemistry"}
+		}, []ItemChange{}},
+		{"Bookkeeping", func(e *EquipmentItem) {
+			e.Model = &gorm.Model{ID: 5}
+			e.db = &gorm.DB{}
+		}, []ItemChange{}},
+		{"Prep room", func(e *EquipmentItem) {
+			e.PrepRoomID = &two
+			e.PrepRoom = &PrepRoom{Name: "Physics"}
+		}, []ItemChange{{Field: "PrepRoomID", Old: "1", New: "2"}}},
+		{"Prep room removed", func(e *EquipmentItem) {
+			e.PrepRoomID = nil
+			e.PrepRoom = nil
+		}, []ItemChange{{Field: "PrepRoomID", Old: "1", New: ""}}},
+		{"Fields", func(e *EquipmentItem) {
+			e.Quantity = 8
+			e.UnitCost = 250
+		}, []ItemChange{{Field: "Quantity", Old: "10", New: "8"}, {Field: "UnitCost", Old: "£0.00", New: "£2.50"}}},
+	}
+
+	for _, tt := range tests {
+		after := base
+		tt.Change(&after)
+
+		got := DiffItems(base, after)
+		if !reflect.DeepEqual(got, tt.Expect) {
+			t.Errorf("%s: expected %v, got %v", tt.Name, tt.Expect, got)
+		}
+	}
+}
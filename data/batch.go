@@ -0,0 +1,119 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Batch retrieval errors.
+var (
+	ErrInvalidBatchID = errors.New("invalid batch ID")
+	ErrNoSuchBatch    = errors.New("batch does not exist")
+)
+
+// A ChemicalBatch is a single received lot of an EquipmentItem, such as one
+// bottle of a reagent. Batches are optional and are mostly useful for
+// consumables which expire and must be traced (such as for COSHH records).
+type ChemicalBatch struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint          `json:"item_id"`
+	Item   EquipmentItem `json:"-"`
+
+	LotNumber string `json:"lot_number"`
+	Supplier  string `json:"supplier"`
+
+	Received time.Time  `json:"received"`
+	Expiry   time.Time  `json:"expiry"`
+	Opened   *time.Time `json:"opened"`
+
+	// Remaining quantity of the batch in whatever units are printed on the
+	// container.
+	Remaining float64 `json:"remaining"`
+	Unit      string  `json:"unit"`
+}
+
+// Expired returns true if the expiry date of this batch has passed.
+func (b ChemicalBatch) Expired() bool {
+	return b.Expiry.Before(time.Now())
+}
+
+// ExpiresWithin returns true if this batch will have expired by the time d has
+// elapsed. Expired batches also expire within any d.
+func (b ChemicalBatch) ExpiresWithin(d time.Duration) bool {
+	return b.Expiry.Before(time.Now().Add(d))
+}
+
+// IsOpened returns true if this batch has been marked as opened.
+func (b ChemicalBatch) IsOpened() bool {
+	return b.Opened != nil
+}
+
+// Open marks this batch as opened as of now if it has not been already.
+func (b *ChemicalBatch) Open(db *gorm.DB) error {
+	if b.Opened != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if err := db.Model(b).Update("opened", now).Error; err != nil {
+		return fmt.Errorf("open batch %d: sql error: %w", b.ID, err)
+	}
+	b.Opened = &now
+
+	return nil
+}
+
+// GetBatch looks up a batch by ID, joining its item.
+func GetBatch(db *gorm.DB, id uint) (ChemicalBatch, error) {
+	if id == 0 {
+		return ChemicalBatch{}, fmt.Errorf("get batch %d: %w", id, ErrInvalidBatchID)
+	}
+
+	b := ChemicalBatch{Model: &gorm.Model{ID: id}}
+	err := db.Where(&b).Joins("Item").First(&b).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ChemicalBatch{}, fmt.Errorf("get batch %d: %w", id, ErrNoSuchBatch)
+		}
+
+		return ChemicalBatch{}, fmt.Errorf("get batch %d: sql error: %w", id, err)
+	}
+
+	return b, nil
+}
+
+// GetItemBatches returns all batches recorded against the given item, soonest
+// expiry first.
+func GetItemBatches(db *gorm.DB, item uint) ([]ChemicalBatch, error) {
+	b := make([]ChemicalBatch, 0, 5)
+	res := db.Where(&ChemicalBatch{ItemID: item}).
+		Order("expiry ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return b, fmt.Errorf("get batches for item %d: sql error: %w", item, err)
+	}
+
+	return b, nil
+}
+
+// GetExpiringBatches returns all batches which expire before the given time,
+// including those which have already expired, with their items joined.
+func GetExpiringBatches(db *gorm.DB, before time.Time) ([]ChemicalBatch, error) {
+	b := make([]ChemicalBatch, 0, 5)
+	res := db.Model(&ChemicalBatch{}).Joins("Item").
+		Where("expiry < ?", before).
+		Order("expiry ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return b, fmt.Errorf("get batches expiring before %v: sql error: %w", before, err)
+	}
+
+	return b, nil
+}
@@ -24,6 +24,9 @@ const (
 	// Booking rejected. The technician is unable to fulfil this request
 	// and has rejected it. May be accompanied by a rejection message.
 	BookingStatusRejected
+	// Waitlisted. Not enough equipment was free when booked, so this
+	// booking holds no stock until promoted to pending once enough is.
+	BookingStatusWaitlisted
 )
 
 // Booking creation/retrieval errors.
@@ -44,6 +47,10 @@ var (
 
 type BookingStatus uint
 
+// uncommittedStatuses are those of bookings which hold no equipment and need
+// no preparation.
+var uncommittedStatuses = []BookingStatus{BookingStatusRejected, BookingStatusWaitlisted}
+
 func (b BookingStatus) String() string {
 	switch b {
 	case BookingStatusPending:
@@ -54,6 +61,8 @@ func (b BookingStatus) String() string {
 		return "Ready"
 	case BookingStatusRejected:
 		return "Rejected"
+	case BookingStatusWaitlisted:
+		return "Waitlisted"
 	default:
 		return "Unknown"
 	}
@@ -75,6 +84,16 @@ func (b BookingStatus) Rejected() bool {
 	return b == BookingStatusRejected
 }
 
+func (b BookingStatus) Waitlisted() bool {
+	return b == BookingStatusWaitlisted
+}
+
+// Committed returns true if the booking is going ahead, either being or
+// having been prepared.
+func (b BookingStatus) Committed() bool {
+	return !b.Rejected() && !b.Waitlisted()
+}
+
 // A Booking is an entry in the schedule which has an associated activity
 // (temporary or persistent), teacher account and location specification. The
 // location specification stores a location as given by the timetable or other
@@ -82,6 +101,7 @@ func (b BookingStatus) Rejected() bool {
 // status which is documented above.
 type Booking struct {
 	*gorm.Model
+	Tenancy
 
 	StartTime time.Time
 	EndTime   time.Time
@@ -95,6 +115,29 @@ type Booking struct {
 	OwnerID uint
 	Owner   User
 
+	// Prep room which prepares this booking. If nil, every prep room's
+	// technicians see it.
+	PrepRoomID *uint
+	PrepRoom   *PrepRoom
+
+	// Technician responsible for preparing this booking, if any.
+	AssigneeID *uint
+	Assignee   *User
+	// Estimated time taken to prepare this booking, in minutes, if it
+	// differs from that of the activity.
+	PrepMinutes *Minutes
+	// Set by a technician to exempt this booking from the booking policy,
+	// allowing late amendments.
+	PolicyOverride bool
+	// Cost of the booking and the department charged for it, recorded when
+	// the booking is committed so that later changes to prices do not
+	// rewrite past spending.
+	Cost      Price
+	ChargedTo string
+	// Set when the booking is cleaned up once over, rather than cancelled,
+	// so that its cost is still reported.
+	Archived bool
+
 	Comments string
 }
 
@@ -119,10 +162,11 @@ func (b Booking) Ongoing() bool {
 // submitted are met.
 //
 // Those requirements are:
-//   - The current time is more than 1 hour (60 minutes) before the scheduled start time.
+//   - The current time is at least window before the scheduled start time,
+//     unless a technician has exempted the booking from the booking policy.
 //   - The booking has not been marked as completed by the technician.
-func (b Booking) MayAmend() bool {
-	return (b.Status.Pending() || b.Status.Progress()) && time.Until(b.StartTime).Minutes() >= 60
+func (b Booking) MayAmend(window time.Duration) bool {
+	return (b.Status.Pending() || b.Status.Progress() || b.Status.Waitlisted()) && (b.PolicyOverride || time.Until(b.StartTime) >= window)
 }
 
 // Delete removes this booking from the database, along with its temporary
@@ -152,10 +196,24 @@ func (b Booking) Delete(db *gorm.DB) error {
 	})
 }
 
+// Archive removes this booking once it is over, as with Delete, but keeps
+// the booking itself (though not its equipment) so that its cost is still
+// reported.
+func (b Booking) Archive(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&b).Update("archived", true).Error; err != nil {
+			return fmt.Errorf("archive booking #%d: sql error: %w", b.ID, err)
+		}
+
+		return b.Delete(tx)
+	})
+}
+
 // NewBooking inserts a new booking from the specified activity into the
 // database. The owner is taken to be the owner of act. If act is not yet a
 // temporary activity, an error is returned. Else, all errors returned will be
-// SQL-related.
+// SQL-related. The booking is prepared by the prep room of the activity, or
+// else that of the owner.
 func NewBooking(db *gorm.DB, act Activity, location string, start, end time.Time, comments string) (Booking, error) {
 	if !act.Temporary {
 		return Booking{}, fmt.Errorf("book activity %s: %w", act.Title, ErrNotTemporary)
@@ -168,6 +226,15 @@ func NewBooking(db *gorm.DB, act Activity, location string, start, end time.Time
 		Comments:   comments,
 		ActivityID: act.ID,
 		OwnerID:    act.OwnerID,
+		PrepRoomID: act.PrepRoomID,
+	}
+
+	if bk.PrepRoomID == nil {
+		owner, err := GetUser(db, act.OwnerID)
+		if err != nil {
+			return bk, fmt.Errorf("book activity %s: %w", act.Title, err)
+		}
+		bk.PrepRoomID = owner.PrepRoomID
 	}
 
 	err := db.Create(&bk).Error
@@ -188,8 +255,11 @@ func GetBooking(db *gorm.DB, id uint) (Booking, error) {
 
 	u := Booking{Model: &gorm.Model{ID: id}}
 	err := db.Where(&u).Joins("Activity").Joins("Owner").
+		Preload("Assignee").
 		Preload("Activity.Equipment").
 		Preload("Activity.Equipment.Item").
+		Preload("Activity.Equipment.Batch").
+		Preload("Activity.Equipment.Units").
 		First(&u).Error
 
 	if err != nil {
@@ -246,6 +316,7 @@ func GetPersonalBookings(db *gorm.DB, id uint) ([]Booking, error) {
 func GetBookingsRange(db *gorm.DB, start, end time.Time) ([]Booking, error) {
 	b := make([]Booking, 0, 5)
 	res := db.Model(&Booking{}).Joins("Activity").Joins("Owner").
+		Preload("Assignee").
 		Where(`
 			(start_time <= ? AND end_time >= ?) OR
 			(start_time >= ? AND start_time <= ?)
@@ -280,14 +351,22 @@ func GetPersonalBookingsRange(db *gorm.DB, uid uint, start, end time.Time) ([]Bo
 
 // GetBookingsStatus returns all bookings of the given status. Bookings with
 // start time lower than the current time which have been marked as rejected or
-// completed are not returned.
-func GetBookingsStatus(db *gorm.DB, status BookingStatus) ([]Booking, error) {
+// completed are not returned. If prep is not nil, only bookings for that prep
+// room or for none are returned.
+func GetBookingsStatus(db *gorm.DB, status BookingStatus, prep *uint) ([]Booking, error) {
 	b := make([]Booking, 0, 5)
-	res := db.Model(&Booking{}).Joins("Activity").Joins("Owner").
-		Where("Status", status).
+	q := db.Model(&Booking{}).Joins("Activity").Joins("Owner")
+	if prep != nil {
+		q = q.Where("bookings.prep_room_id = ? OR bookings.prep_room_id IS NULL", *prep)
+	}
+
+	res := q.Where("Status", status).
 		Where("start_time > ? OR NOT (status = ? OR status = ?)", time.Now(), BookingStatusReady, BookingStatusRejected).
+		Preload("Assignee").
 		Preload("Activity.Equipment").
 		Preload("Activity.Equipment.Item").
+		Preload("Activity.Equipment.Batch").
+		Preload("Activity.Equipment.Units").
 		Find(&b)
 
 	if err := res.Error; err != nil {
@@ -324,8 +403,8 @@ func GetCurrentBooking(db *gorm.DB, uid uint) (Booking, error) {
 	return o[0], nil
 }
 
-// CleanBookings cleans out old bookings by marking them as deleted if they are
-// passed their expiry date. CleanBookings processes any bookings in batches of
+// CleanBookings cleans out old bookings by archiving them if they are passed
+// their expiry date. CleanBookings processes any bookings in batches of
 // 10 at a time to avoid very large responses from the server.
 func CleanBookings(db *gorm.DB) (int64, error) {
 	t := time.Now()
@@ -345,7 +424,7 @@ func CleanBookings(db *gorm.DB) (int64, error) {
 		}
 
 		for _, b := range bk {
-			err := b.Delete(db)
+			err := b.Archive(db)
 			if err != nil {
 				cerr.Push(fmt.Errorf("clean bookings: %w", err))
 			}
@@ -21,6 +21,9 @@ const (
 	CapOwnBooking = UserTeacher
 	// Only technicians may modify others' bookings.
 	CapAllBooking = UserTechnician
+	// Technicians are not bound by the booking policy, and may exempt
+	// others' bookings from it.
+	CapOverridePolicy = UserTechnician
 
 	// Technicians may manage the inventory database.
 	CapManageInventory      = UserTechnician
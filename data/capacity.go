@@ -0,0 +1,182 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// A Minutes is an amount of prep time in whole minutes.
+type Minutes uint
+
+func (m Minutes) String() string {
+	switch {
+	case m < 60:
+		return fmt.Sprintf("%dm", m)
+	case m%60 == 0:
+		return fmt.Sprintf("%dh", m/60)
+	default:
+		return fmt.Sprintf("%dh %dm", m/60, m%60)
+	}
+}
+
+// Count returns the number of minutes without units, as used in forms.
+func (m Minutes) Count() uint {
+	return uint(m)
+}
+
+// Duration returns the amount of time as a time.Duration.
+func (m Minutes) Duration() time.Duration {
+	return time.Duration(m) * time.Minute
+}
+
+// PrepTime returns the estimated time taken to prepare the booking, which is
+// that of its activity unless adjusted for this booking.
+func (b Booking) PrepTime() Minutes {
+	if b.PrepMinutes != nil {
+		return *b.PrepMinutes
+	}
+
+	return b.Activity.PrepMinutes
+}
+
+// PrepEstimated returns true if the booking has a prep time estimate.
+func (b Booking) PrepEstimated() bool {
+	return b.PrepTime() > 0
+}
+
+// PrepAdjusted returns true if the prep time estimate of the booking differs
+// from that of its activity.
+func (b Booking) PrepAdjusted() bool {
+	return b.PrepMinutes != nil
+}
+
+// PrepBy returns the latest time at which preparation of the booking may
+// start for it to be ready in time.
+func (b Booking) PrepBy() time.Time {
+	return b.StartTime.Add(-b.PrepTime().Duration())
+}
+
+// Outstanding returns true if the booking still needs preparing.
+func (b Booking) Outstanding() bool {
+	return b.Status.Pending() || b.Status.Progress()
+}
+
+// SetBookingPrepTime adjusts the estimated prep time of the booking with the
+// given ID, in minutes. If mins is nil, the estimate of the activity is used.
+// The updated booking is returned.
+func SetBookingPrepTime(db *gorm.DB, id uint, mins *Minutes) (Booking, error) {
+	bk, err := GetBooking(db, id)
+	if err != nil {
+		return bk, fmt.Errorf("set prep time %d: %w", id, err)
+	}
+
+	if err := db.Model(&Booking{}).Where("id = ?", id).Update("prep_minutes", mins).Error; err != nil {
+		return bk, fmt.Errorf("set prep time %d: sql error: %w", id, err)
+	}
+
+	return GetBooking(db, id)
+}
+
+// SortPrepOrder sorts bookings into the order in which they should be
+// prepared: those which must be started soonest come first.
+func SortPrepOrder(bks []Booking) {
+	sort.SliceStable(bks, func(i, j int) bool {
+		pi, pj := bks[i].PrepBy(), bks[j].PrepBy()
+		if !pi.Equal(pj) {
+			return pi.Before(pj)
+		}
+
+		return bks[i].ID < bks[j].ID
+	})
+}
+
+// A CapacityDay compares the prep time due on a day against the time which
+// technicians have available to do it.
+type CapacityDay struct {
+	Date time.Time
+	// Bookings which still need preparing on this day.
+	Bookings []Booking
+	// Summed prep time of the bookings.
+	Demand Minutes
+	// Time available to technicians.
+	Available Minutes
+	// Number of bookings without a prep time estimate.
+	Unestimated int
+}
+
+// Overloaded returns true if more prep is due than can be done.
+func (d CapacityDay) Overloaded() bool {
+	return d.Demand > d.Available
+}
+
+// Load returns the demand as a percentage of the time available.
+func (d CapacityDay) Load() int {
+	if d.Available == 0 {
+		if d.Demand == 0 {
+			return 0
+		}
+		return 100
+	}
+
+	return int(d.Demand * 100 / d.Available)
+}
+
+// Spare returns the time available which is not yet needed, or zero if
+// overloaded.
+func (d CapacityDay) Spare() Minutes {
+	if d.Overloaded() {
+		return 0
+	}
+
+	return d.Available - d.Demand
+}
+
+// Over returns the time by which demand exceeds what is available, or zero if
+// not overloaded.
+func (d CapacityDay) Over() Minutes {
+	if !d.Overloaded() {
+		return 0
+	}
+
+	return d.Demand - d.Available
+}
+
+// PlanCapacity sums the prep time of the outstanding bookings due on each of
+// the given days, comparing it against techs technicians each working for the
+// duration of day. Bookings falling on none of the days are ignored.
+func PlanCapacity(bks []Booking, days []time.Time, techs int, day time.Duration) []CapacityDay {
+	plan := make([]CapacityDay, len(days))
+	for i, d := range days {
+		plan[i] = CapacityDay{Date: d, Available: Minutes(techs) * Minutes(day/time.Minute)}
+	}
+
+	for _, b := range bks {
+		if !b.Outstanding() {
+			continue
+		}
+
+		st := b.StartTime.In(time.Local)
+		for i := range plan {
+			y, m, d := plan[i].Date.Date()
+			by, bm, bd := st.Date()
+			if y != by || m != bm || d != bd {
+				continue
+			}
+
+			plan[i].Bookings = append(plan[i].Bookings, b)
+			plan[i].Demand += b.PrepTime()
+			if !b.PrepEstimated() {
+				plan[i].Unestimated++
+			}
+		}
+	}
+
+	for i := range plan {
+		SortPrepOrder(plan[i].Bookings)
+	}
+
+	return plan
+}
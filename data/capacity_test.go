@@ -0,0 +1,166 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// prepBooking returns a booking with the given ID and status starting at the
+// given time, taking mins minutes to prepare, or the activity estimate of 30
+// minutes if nil.
+func prepBooking(id uint, start time.Time, status BookingStatus, mins *Minutes) Booking {
+	return Booking{
+		Model:       &gorm.Model{ID: id},
+		StartTime:   start,
+		EndTime:     start.Add(time.Hour),
+		Status:      status,
+		PrepMinutes: mins,
+		Activity:    Activity{PrepMinutes: 30},
+	}
+}
+
+func TestMinutesString(t *testing.T) {
+	testdata := []struct {
+		Minutes Minutes
+		Expect  string
+	}{
+		{0, "0m"},
+		{45, "45m"},
+		{60, "1h"},
+		{90, "1h 30m"},
+		{480, "8h"},
+	}
+
+	for _, d := range testdata {
+		if got := d.Minutes.String(); got != d.Expect {
+			t.Errorf("%d minutes: expected %q, got %q", uint(d.Minutes), d.Expect, got)
+		}
+	}
+}
+
+func TestSortPrepOrder(t *testing.T) {
+	mon := day(2026, 10, 19)
+	hour, long := Minutes(60), Minutes(180)
+
+	testdata := []struct {
+		Name     string
+		Bookings []Booking
+		Expect   []uint
+	}{
+		{"By start", []Booking{
+			prepBooking(1, mon.Add(14*time.Hour), BookingStatusPending, nil),
+			prepBooking(2, mon.Add(9*time.Hour), BookingStatusPending, nil),
+		}, []uint{2, 1}},
+		{"Longer prep first", []Booking{
+			// Must be started at 08:00 and 07:00 respectively.
+			prepBooking(1, mon.Add(9*time.Hour), BookingStatusPending, &hour),
+			prepBooking(2, mon.Add(10*time.Hour), BookingStatusPending, &long),
+		}, []uint{2, 1}},
+		{"Ties by ID", []Booking{
+			prepBooking(3, mon.Add(10*time.Hour), BookingStatusPending, nil),
+			prepBooking(1, mon.Add(10*time.Hour), BookingStatusProgress, nil),
+			prepBooking(2, mon.Add(9*time.Hour).Add(30*time.Minute), BookingStatusPending, &hour),
+		}, []uint{2, 1, 3}},
+	}
+
+	for _, d := range testdata {
+		SortPrepOrder(d.Bookings)
+
+		got := make([]uint, len(d.Bookings))
+		for i, b := range d.Bookings {
+			got[i] = b.ID
+		}
+		if !reflect.DeepEqual(got, d.Expect) {
+			t.Errorf("%s: expected order %v, got %v", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestCapacityDay(t *testing.T) {
+	testdata := []struct {
+		Name       string
+		Day        CapacityDay
+		Overloaded bool
+		Load       int
+		Spare      Minutes
+		Over       Minutes
+	}{
+		{"Idle", CapacityDay{Available: 480}, false, 0, 480, 0},
+		{"Part full", CapacityDay{Demand: 120, Available: 480}, false, 25, 360, 0},
+		{"Full", CapacityDay{Demand: 480, Available: 480}, false, 100, 0, 0},
+		{"Overloaded", CapacityDay{Demand: 600, Available: 480}, true, 125, 0, 120},
+		{"No technicians", CapacityDay{Demand: 30}, true, 100, 0, 30},
+		{"Nothing to do", CapacityDay{}, false, 0, 0, 0},
+	}
+
+	for _, d := range testdata {
+		if got := d.Day.Overloaded(); got != d.Overloaded {
+			t.Errorf("%s: expected overloaded %v, got %v", d.Name, d.Overloaded, got)
+		}
+		if got := d.Day.Load(); got != d.Load {
+			t.Errorf("%s: expected load %d%%, got %d%%", d.Name, d.Load, got)
+		}
+		if got := d.Day.Spare(); got != d.Spare {
+			t.Errorf("%s: expected %s spare, got %s", d.Name, d.Spare, got)
+		}
+		if got := d.Day.Over(); got != d.Over {
+			t.Errorf("%s: expected %s over, got %s", d.Name, d.Over, got)
+		}
+	}
+}
+
+func TestPlanCapacity(t *testing.T) {
+	mon, tue, wed := day(2026, 10, 19), day(2026, 10, 20), day(2026, 10, 21)
+	none, long := Minutes(0), Minutes(300)
+
+	bks := []Booking{
+		prepBooking(1, mon.Add(14*time.Hour), BookingStatusPending, nil),
+		prepBooking(2, mon.Add(9*time.Hour), BookingStatusProgress, &long),
+		// Only outstanding bookings need preparing.
+		prepBooking(3, mon.Add(10*time.Hour), BookingStatusReady, nil),
+		prepBooking(4, mon.Add(11*time.Hour), BookingStatusRejected, nil),
+		prepBooking(5, mon.Add(11*time.Hour), BookingStatusWaitlisted, nil),
+		prepBooking(6, tue.Add(9*time.Hour), BookingStatusPending, &none),
+		prepBooking(7, tue.Add(23*time.Hour+30*time.Minute), BookingStatusPending, nil),
+		// Outside of the days planned.
+		prepBooking(8, day(2026, 10, 22).Add(9*time.Hour), BookingStatusPending, nil),
+	}
+
+	testdata := []struct {
+		Date        time.Time
+		Bookings    []uint
+		Demand      Minutes
+		Available   Minutes
+		Unestimated int
+		Overloaded  bool
+	}{
+		{mon, []uint{2, 1}, 330, 240, 0, true},
+		{tue, []uint{6, 7}, 30, 240, 1, false},
+		{wed, nil, 0, 240, 0, false},
+	}
+
+	plan := PlanCapacity(bks, []time.Time{mon, tue, wed}, 2, 2*time.Hour)
+	if len(plan) != len(testdata) {
+		t.Fatalf("expected %d days planned, got %d", len(testdata), len(plan))
+	}
+
+	for i, d := range testdata {
+		p := plan[i]
+
+		var got []uint
+		for _, b := range p.Bookings {
+			got = append(got, b.ID)
+		}
+		if !p.Date.Equal(d.Date) || !reflect.DeepEqual(got, d.Bookings) {
+			t.Errorf("%s: expected bookings %v on %s, got %v", p.Date.Format(time.DateOnly), d.Bookings, d.Date.Format(time.DateOnly), got)
+		}
+		if p.Demand != d.Demand || p.Available != d.Available || p.Unestimated != d.Unestimated || p.Overloaded() != d.Overloaded {
+			t.Errorf("%s: expected %s of %s (%d unestimated, overloaded %v), got %s of %s (%d unestimated, overloaded %v)",
+				d.Date.Format(time.DateOnly), d.Demand, d.Available, d.Unestimated, d.Overloaded,
+				p.Demand, p.Available, p.Unestimated, p.Overloaded())
+		}
+	}
+}
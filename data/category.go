@@ -0,0 +1,86 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+// Item categories. Items with no category are uncategorised.
+const (
+	CategoryNone       ItemCategory = ""
+	CategoryGlassware  ItemCategory = "glassware"
+	CategoryChemicals  ItemCategory = "chemicals"
+	CategoryElectrical ItemCategory = "electrical"
+	CategoryBiology    ItemCategory = "biology"
+	CategoryPhysics    ItemCategory = "physics"
+	CategoryApparatus  ItemCategory = "apparatus"
+	CategorySafety     ItemCategory = "safety"
+)
+
+// ItemCategories are the categories which may be given to an item, in the
+// order in which they are offered.
+var ItemCategories = []ItemCategory{
+	CategoryGlassware, CategoryChemicals, CategoryElectrical, CategoryBiology,
+	CategoryPhysics, CategoryApparatus, CategorySafety,
+}
+
+// An ItemCategory is the broad kind of an inventory item, such as glassware.
+type ItemCategory string
+
+func (c ItemCategory) String() string {
+	if c == CategoryNone {
+		return "Uncategorised"
+	}
+
+	return strings.ToUpper(string(c[:1])) + string(c[1:])
+}
+
+// Key returns the value of c as stored and used in forms.
+func (c ItemCategory) Key() string {
+	return string(c)
+}
+
+// Valid returns true if c is one of ItemCategories or no category.
+func (c ItemCategory) Valid() bool {
+	if c == CategoryNone {
+		return true
+	}
+
+	for _, v := range ItemCategories {
+		if c == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormaliseTags cleans up a comma separated list of free-form tags, so that
+// tags may be searched for reliably. Tags are lower cased, trimmed, sorted and
+// de-duplicated.
+func NormaliseTags(s string) string {
+	return strings.Join(SplitTags(s), ", ")
+}
+
+// SplitTags returns the normalised tags in the comma separated list s.
+func SplitTags(s string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+		if t == "" || seen[t] {
+			continue
+		}
+
+		seen[t] = true
+		tags = append(tags, t)
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// TagList returns the tags of this item.
+func (e EquipmentItem) TagList() []string {
+	return SplitTags(e.Tags)
+}
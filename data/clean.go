@@ -7,9 +7,15 @@ import (
 	"gorm.io/gorm"
 )
 
-// cleanTable cleans the table for a specific model.
-func cleanTable(db *gorm.DB, model any) (int64, error) {
-	res := db.Unscoped().Model(model).Where("deleted_at < ?", time.Now().Add(-3*7*24*time.Hour)).Delete(&model)
+// cleanTable cleans the table for a specific model. If conditions are given,
+// only rows also matching them are removed.
+func cleanTable(db *gorm.DB, model any, conds ...any) (int64, error) {
+	q := db.Unscoped().Model(model).Where("deleted_at < ?", time.Now().Add(-3*7*24*time.Hour))
+	if len(conds) > 0 {
+		q = q.Where(conds[0], conds[1:]...)
+	}
+
+	res := q.Delete(&model)
 	if err := res.Error; err != nil {
 		return res.RowsAffected, fmt.Errorf("cleaning %T: sql error: %w", model, err)
 	}
@@ -34,9 +40,28 @@ func CleanDeleted(db *gorm.DB) error {
 		// Note: Order here is important to avoid foreign key violations!
 		cleanTable(tx, User{})
 		cleanTable(tx, EquipmentSet{})
-		cleanTable(tx, Activity{})
-		cleanTable(tx, Booking{})
+		cleanTable(tx, ReturnRecord{})
+		// Return records refer to their bookings, which refer to their
+		// activities, so neither may go while still referred to.
+		// Archived bookings are kept for the cost report.
+		cleanTable(tx, Activity{}, "id NOT IN (SELECT activity_id FROM bookings)")
+		cleanTable(tx, Booking{}, "(NOT archived OR status IN ?) AND id NOT IN (SELECT booking_id FROM return_records)", uncommittedStatuses)
+		cleanTable(tx, ChemicalBatch{})
+		cleanTable(tx, ServiceRecord{})
+		cleanTable(tx, EquipmentUnit{})
+		cleanTable(tx, ItemStock{})
+		cleanTable(tx, KitItem{})
+		cleanTable(tx, Kit{})
+		cleanTable(tx, ItemSubstitute{})
+		cleanTable(tx, OrderLine{})
+		cleanTable(tx, PurchaseOrder{})
+		cleanTable(tx, CatalogueEntry{})
+		cleanTable(tx, Supplier{})
+		cleanTable(tx, Budget{})
+		cleanTable(tx, Room{})
+		cleanTable(tx, StorageLocation{})
 		cleanTable(tx, EquipmentItem{})
+		cleanTable(tx, PrepRoom{})
 
 		return tx.Error
 	})
@@ -0,0 +1,397 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BudgetWarning is the percentage of a department's budget which, once spent,
+// causes warnings to be raised.
+const BudgetWarning = 80
+
+// NoDepartment is the name used for bookings with no department.
+const NoDepartment = "Unassigned"
+
+// A Budget is the limit on spending by a department over a term. Budgets
+// for terms in the academic calendar are kept by term ID, so that they follow
+// the term if its dates are changed. Those for assumed terms are kept by the
+// start of the term.
+type Budget struct {
+	*gorm.Model
+	Tenancy
+
+	Department string
+	TermID     *uint `gorm:"index"`
+	TermStart  time.Time
+	Limit      Price
+}
+
+// budgetTerm restricts q to the budgets for the given term. Budgets set by
+// start before the term was added to the academic calendar are included.
+func budgetTerm(q *gorm.DB, term Term) *gorm.DB {
+	if term.ID == 0 {
+		return q.Where("term_id IS NULL AND term_start = ?", term.Start.UTC())
+	}
+
+	return q.Where("term_id = ? OR (term_id IS NULL AND term_start = ?)", term.ID, term.Start.UTC())
+}
+
+// Cost returns the cost of the equipment requisitioned for this activity, at
+// current unit costs. Items must be joined.
+func (a Activity) Cost() Price {
+	c := Price(0)
+	for _, s := range a.Equipment {
+		c += s.Item.UnitCost * Price(s.Quantity)
+	}
+
+	return c
+}
+
+// Department returns the department which pays for the booking. This is the
+// department charged when the booking was committed if it has been, otherwise
+// the department of the activity if set, otherwise that of the owner.
+func (b Booking) Department() string {
+	if b.ChargedTo != "" {
+		return b.ChargedTo
+	}
+
+	return b.department()
+}
+
+// department returns the department which would currently be charged for the
+// booking. Activity and owner must be joined.
+func (b Booking) department() string {
+	if d := strings.TrimSpace(b.Activity.Department); d != "" {
+		return d
+	}
+	if d := strings.TrimSpace(b.Owner.Department); d != "" {
+		return d
+	}
+
+	return NoDepartment
+}
+
+// charge returns the booking charged at current unit costs. Equipment,
+// activity and owner must be joined.
+func (b Booking) charge() Booking {
+	b.Cost, b.ChargedTo = b.Activity.Cost(), b.department()
+	return b
+}
+
+// ChargeBooking records the cost of the booking with the given ID and the
+// department charged for it, at current unit costs. Bookings are charged as
+// they are committed, or whenever their equipment changes, so that reports are
+// unaffected by later changes to prices.
+func ChargeBooking(db *gorm.DB, id uint) (Booking, error) {
+	bk, err := GetBooking(db, id)
+	if err != nil {
+		return bk, fmt.Errorf("charge booking: %w", err)
+	}
+
+	bk = bk.charge()
+	res := db.Model(&bk).Updates(map[string]any{"cost": bk.Cost, "charged_to": bk.ChargedTo})
+	if err := res.Error; err != nil {
+		return bk, fmt.Errorf("charge booking #%d: sql error: %w", id, err)
+	}
+
+	return bk, nil
+}
+
+// MigrateCharges charges every booking made before bookings were charged as
+// they were committed. Bookings already cleaned up cannot be charged, so are
+// left out of reports as before. This is a no-op once every booking has been
+// charged.
+func MigrateCharges(db *gorm.DB) error {
+	var ids []uint
+	if err := db.Model(&Booking{}).Where("charged_to IS NULL OR charged_to = ''").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("migrate charges: sql error: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := ChargeBooking(db, id); err != nil {
+			return fmt.Errorf("migrate charges: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// A CostTotal is the total cost of bookings for one group in a cost report.
+// Limit is the budget of the group, if it is a department with a budget.
+type CostTotal struct {
+	Name     string
+	Bookings int
+	Cost     Price
+	Limit    Price
+}
+
+// Percent returns the percentage of the budget which has been spent, or zero
+// if there is no budget.
+func (c CostTotal) Percent() uint {
+	if c.Limit == 0 {
+		return 0
+	}
+
+	return uint(c.Cost * 100 / c.Limit)
+}
+
+// Warning returns true if spending has reached the warning level of the
+// budget.
+func (c CostTotal) Warning() bool {
+	return c.Limit > 0 && c.Percent() >= BudgetWarning
+}
+
+// Over returns true if spending has exceeded the budget.
+func (c CostTotal) Over() bool {
+	return c.Limit > 0 && c.Cost > c.Limit
+}
+
+// A CostReport summarises the cost of bookings over a term.
+type CostReport struct {
+	Term        Term
+	Bookings    []Booking
+	Total       Price
+	Departments []CostTotal
+	Teachers    []CostTotal
+}
+
+// costTotals sums bookings into groups by the key returned from by, most
+// expensive first.
+func costTotals(bks []Booking, by func(Booking) string) []CostTotal {
+	idx := make(map[string]int)
+	t := make([]CostTotal, 0)
+	for _, b := range bks {
+		k := by(b)
+		i, ok := idx[k]
+		if !ok {
+			i = len(t)
+			idx[k] = i
+			t = append(t, CostTotal{Name: k})
+		}
+
+		t[i].Bookings++
+		t[i].Cost += b.Cost
+	}
+
+	sort.SliceStable(t, func(i, j int) bool {
+		return t[i].Cost > t[j].Cost
+	})
+
+	return t
+}
+
+// termBookings returns every committed booking starting within the given
+// term, with activities and owners joined. Bookings archived once over are
+// included, but not those which were cancelled. Costs are as charged, as the
+// equipment of archived bookings may since have been removed.
+func termBookings(db *gorm.DB, term Term) ([]Booking, error) {
+	b := make([]Booking, 0, 20)
+	res := db.Unscoped().Model(&Booking{}).Joins("Activity").Joins("Owner").
+		Where("bookings.deleted_at IS NULL OR bookings.archived").
+		Where("start_time >= ? AND start_time < ?", term.Start.UTC(), term.End.UTC()).
+		Where("bookings.status NOT IN ?", uncommittedStatuses).
+		Order("start_time ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return b, fmt.Errorf("get bookings for %s: sql error: %w", term, err)
+	}
+
+	return b, nil
+}
+
+// newCostReport sums the cost of the bookings of a term, with totals by
+// department and teacher. Departments are compared against their budgets,
+// keyed by department.
+func newCostReport(term Term, bks []Booking, budgets map[string]Budget) CostReport {
+	rep := CostReport{Term: term, Bookings: bks}
+	for _, b := range rep.Bookings {
+		rep.Total += b.Cost
+	}
+
+	rep.Departments = costTotals(rep.Bookings, Booking.Department)
+	rep.Teachers = costTotals(rep.Bookings, func(b Booking) string {
+		return b.Owner.DisplayName()
+	})
+
+	seen := make(map[string]bool)
+	for i, d := range rep.Departments {
+		rep.Departments[i].Limit = budgets[d.Name].Limit
+		seen[d.Name] = true
+	}
+	// Departments with a budget but no spending are still of interest.
+	idle := make([]string, 0, len(budgets))
+	for name := range budgets {
+		if !seen[name] {
+			idle = append(idle, name)
+		}
+	}
+	sort.Strings(idle)
+	for _, name := range idle {
+		rep.Departments = append(rep.Departments, CostTotal{Name: name, Limit: budgets[name].Limit})
+	}
+
+	return rep
+}
+
+// GetCostReport returns the cost of every booking in the given term, with
+// totals by department and teacher. Departments are compared against their
+// budgets for the term.
+func GetCostReport(db *gorm.DB, term Term) (CostReport, error) {
+	bks, err := termBookings(db, term)
+	if err != nil {
+		return CostReport{Term: term}, fmt.Errorf("get cost report: %w", err)
+	}
+
+	budgets, err := GetBudgets(db, term)
+	if err != nil {
+		return CostReport{Term: term}, fmt.Errorf("get cost report: %w", err)
+	}
+
+	return newCostReport(term, bks, budgets), nil
+}
+
+// departmentSpend sums the cost of the bookings charged to a department,
+// against its budget, if any.
+func departmentSpend(dept string, bks []Booking, budgets map[string]Budget) CostTotal {
+	t := CostTotal{Name: dept, Limit: budgets[dept].Limit}
+	for _, b := range bks {
+		if b.Department() == dept {
+			t.Bookings++
+			t.Cost += b.Cost
+		}
+	}
+
+	return t
+}
+
+// GetDepartmentSpend returns the spending of a department over a term and
+// its budget, if any.
+func GetDepartmentSpend(db *gorm.DB, dept string, term Term) (CostTotal, error) {
+	bks, err := termBookings(db, term)
+	if err != nil {
+		return CostTotal{}, fmt.Errorf("get department spend: %w", err)
+	}
+
+	budgets, err := GetBudgets(db, term)
+	if err != nil {
+		return CostTotal{Name: dept}, fmt.Errorf("get department spend: %w", err)
+	}
+
+	return departmentSpend(dept, bks, budgets), nil
+}
+
+// termBudgets returns the budget of each department from those of a term,
+// keyed by department. Budgets kept by term ID take precedence over those
+// kept by start.
+func termBudgets(b []Budget) map[string]Budget {
+	m := make(map[string]Budget, len(b))
+	for _, v := range b {
+		if old, ok := m[v.Department]; !ok || (old.TermID == nil && v.TermID != nil) {
+			m[v.Department] = v
+		}
+	}
+
+	return m
+}
+
+// GetBudgets returns the budget of each department for the given term, keyed
+// by department.
+func GetBudgets(db *gorm.DB, term Term) (map[string]Budget, error) {
+	b := make([]Budget, 0, 10)
+	if err := budgetTerm(db, term).Find(&b).Error; err != nil {
+		return nil, fmt.Errorf("get budgets for %s: sql error: %w", term, err)
+	}
+
+	return termBudgets(b), nil
+}
+
+// SetBudget sets the budget of a department for a term. A zero limit removes
+// the budget.
+func SetBudget(db *gorm.DB, dept string, term Term, limit Price) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		q := budgetTerm(tx.Where("department = ?", dept), term)
+		if err := q.Delete(&Budget{}).Error; err != nil {
+			return fmt.Errorf("set budget for %s: sql error: %w", dept, err)
+		}
+		if limit == 0 {
+			return nil
+		}
+
+		b := Budget{Model: &gorm.Model{}, Department: dept, TermStart: term.Start.UTC(), Limit: limit}
+		if term.ID != 0 {
+			b.TermID = &term.ID
+		}
+		if err := tx.Create(&b).Error; err != nil {
+			return fmt.Errorf("set budget for %s: sql error: %w", dept, err)
+		}
+
+		return nil
+	})
+}
+
+// MigrateBudgets keeps the budgets set by term start before budgets were kept
+// by term ID against the terms of the academic calendar starting then. This is
+// a no-op once every such budget has been migrated.
+func MigrateBudgets(db *gorm.DB) error {
+	res := db.Exec(`UPDATE budgets
+		JOIN school_dates ON school_dates.start = budgets.term_start
+			AND school_dates.tenant = budgets.tenant
+			AND school_dates.kind = ?
+			AND school_dates.deleted_at IS NULL
+		SET budgets.term_id = school_dates.id
+		WHERE budgets.term_id IS NULL`, DateTerm)
+	if err := res.Error; err != nil {
+		return fmt.Errorf("migrate budgets: sql error: %w", err)
+	}
+
+	return nil
+}
+
+// GetDepartments returns every department named on a user or activity, in
+// alphabetical order.
+func GetDepartments(db *gorm.DB) ([]string, error) {
+	var u, a []string
+	if err := db.Model(&User{}).Distinct("department").Where("department <> ''").Pluck("department", &u).Error; err != nil {
+		return nil, fmt.Errorf("get departments: sql error: %w", err)
+	}
+	if err := db.Model(&Activity{}).Distinct("department").Where("department <> ''").Pluck("department", &a).Error; err != nil {
+		return nil, fmt.Errorf("get departments: sql error: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	d := make([]string, 0, len(u)+len(a))
+	for _, v := range append(u, a...) {
+		if !seen[v] {
+			seen[v] = true
+			d = append(d, v)
+		}
+	}
+	sort.Strings(d)
+
+	return d, nil
+}
+
+// GetTermCosts returns the total cost of bookings in each of the given terms,
+// named after the term.
+func GetTermCosts(db *gorm.DB, terms []Term) ([]CostTotal, error) {
+	t := make([]CostTotal, len(terms))
+	for i, term := range terms {
+		bks, err := termBookings(db, term)
+		if err != nil {
+			return t, fmt.Errorf("get term costs: %w", err)
+		}
+
+		t[i].Name = term.String()
+		t[i].Bookings = len(bks)
+		for _, b := range bks {
+			t[i].Cost += b.Cost
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,311 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCharge(t *testing.T) {
+	packs := EquipmentItem{Name: "Power Pack", UnitCost: 150}
+	leads := EquipmentItem{Name: "Lead", UnitCost: 20}
+	bk := Booking{
+		Owner: User{Department: "Science"},
+		Activity: Activity{Equipment: []EquipmentSet{
+			{Item: packs, Quantity: 4},
+			{Item: leads, Quantity: 8},
+		}},
+	}
+
+	testdata := []struct {
+		Name       string
+		Booking    func(bk *Booking)
+		Cost       Price
+		Department string
+	}{
+		{"Owner's department", nil, 760, "Science"},
+		{"Activity's department", func(bk *Booking) {
+			bk.Activity.Department = " Physics "
+		}, 760, "Physics"},
+		{"No department", func(bk *Booking) {
+			bk.Owner.Department = ""
+		}, 760, NoDepartment},
+		{"Charged again", func(bk *Booking) {
+			bk.Cost, bk.ChargedTo = 100, "Chemistry"
+		}, 760, "Science"},
+		{"No equipment", func(bk *Booking) {
+			bk.Activity.Equipment = nil
+		}, 0, "Science"},
+	}
+
+	for _, d := range testdata {
+		b := bk
+		if d.Booking != nil {
+			d.Booking(&b)
+		}
+
+		got := b.charge()
+		if got.Cost != d.Cost || got.ChargedTo != d.Department || got.Department() != d.Department {
+			t.Errorf("%s: expected %s charged to %q, got %s charged to %q", d.Name, d.Cost, d.Department, got.Cost, got.ChargedTo)
+		}
+	}
+
+	// Charges stand once made, whatever later happens to prices and
+	// departments.
+	got := bk.charge()
+	got.Activity.Equipment[0].Item.UnitCost = 500
+	got.Activity.Department = "Physics"
+	if got.Cost != 760 || got.Department() != "Science" {
+		t.Errorf("charge changed: got %s charged to %q", got.Cost, got.Department())
+	}
+}
+
+func TestNewCostReport(t *testing.T) {
+	jane := User{FirstName: "Jane", Department: "Science"}
+	john := User{FirstName: "John", Department: "Science"}
+	term := Term{Name: "Autumn 2026", Start: day(2026, 9, 3), End: day(2027, 1, 5)}
+
+	bks := []Booking{
+		{Owner: jane, Cost: 600, ChargedTo: "Physics"},
+		{Owner: john, Cost: 300, ChargedTo: "Chemistry"},
+		{Owner: jane, Cost: 500, ChargedTo: "Chemistry"},
+		// Uncharged bookings fall back on the owner's department.
+		{Owner: john, Cost: 0},
+	}
+	budgets := map[string]Budget{
+		"Physics":   {Department: "Physics", Limit: 500},
+		"Chemistry": {Department: "Chemistry", Limit: 1000},
+		"Biology":   {Department: "Biology", Limit: 2000},
+		"Art":       {Department: "Art", Limit: 100},
+	}
+
+	rep := newCostReport(term, bks, budgets)
+	if rep.Term != term || rep.Total != 1400 || len(rep.Bookings) != 4 {
+		t.Errorf("expected 4 bookings costing £14.00 in %s, got %d costing %s in %s", term, len(rep.Bookings), rep.Total, rep.Term)
+	}
+
+	testdata := []struct {
+		Name   string
+		Got    []CostTotal
+		Expect []CostTotal
+	}{
+		{"Departments", rep.Departments, []CostTotal{
+			{Name: "Chemistry", Bookings: 2, Cost: 800, Limit: 1000},
+			{Name: "Physics", Bookings: 1, Cost: 600, Limit: 500},
+			{Name: "Science", Bookings: 1},
+			// Idle budgets follow, in alphabetical order.
+			{Name: "Art", Limit: 100},
+			{Name: "Biology", Limit: 2000},
+		}},
+		{"Teachers", rep.Teachers, []CostTotal{
+			{Name: "Jane", Bookings: 2, Cost: 1100},
+			{Name: "John", Bookings: 2, Cost: 300},
+		}},
+	}
+
+	for _, d := range testdata {
+		if !reflect.DeepEqual(d.Got, d.Expect) {
+			t.Errorf("%s: expected %v, got %v", d.Name, d.Expect, d.Got)
+		}
+	}
+
+	spend := []struct {
+		Department string
+		Expect     CostTotal
+	}{
+		{"Chemistry", CostTotal{Name: "Chemistry", Bookings: 2, Cost: 800, Limit: 1000}},
+		{"Science", CostTotal{Name: "Science", Bookings: 1}},
+		{"Art", CostTotal{Name: "Art", Limit: 100}},
+		{"Music", CostTotal{Name: "Music"}},
+	}
+	for _, d := range spend {
+		if got := departmentSpend(d.Department, bks, budgets); got != d.Expect {
+			t.Errorf("%s spend: expected %v, got %v", d.Department, d.Expect, got)
+		}
+	}
+}
+
+func TestCostTotalBudget(t *testing.T) {
+	testdata := []struct {
+		Name    string
+		Total   CostTotal
+		Percent uint
+		Warning bool
+		Over    bool
+	}{
+		{"No budget", CostTotal{Cost: 500}, 0, false, false},
+		{"Under", CostTotal{Cost: 500, Limit: 1000}, 50, false, false},
+		{"Warning", CostTotal{Cost: 800, Limit: 1000}, 80, true, false},
+		{"Spent", CostTotal{Cost: 1000, Limit: 1000}, 100, true, false},
+		{"Over", CostTotal{Cost: 1200, Limit: 1000}, 120, true, true},
+	}
+
+	for _, d := range testdata {
+		if p, w, o := d.Total.Percent(), d.Total.Warning(), d.Total.Over(); p != d.Percent || w != d.Warning || o != d.Over {
+			t.Errorf("%s: expected %d%% (warning %v, over %v), got %d%% (warning %v, over %v)", d.Name, d.Percent, d.Warning, d.Over, p, w, o)
+		}
+	}
+}
+
+func TestTermBudgets(t *testing.T) {
+	term := uint(1)
+
+	testdata := []struct {
+		Name    string
+		Budgets []Budget
+		Expect  map[string]Price
+	}{
+		{"None", nil, map[string]Price{}},
+		{"By term", []Budget{
+			{Department: "Science", TermID: &term, Limit: 500},
+			{Department: "Art", TermID: &term, Limit: 100},
+		}, map[string]Price{"Science": 500, "Art": 100}},
+		{"By start", []Budget{
+			{Department: "Science", Limit: 200},
+		}, map[string]Price{"Science": 200}},
+		{"Term before start", []Budget{
+			{Department: "Science", TermID: &term, Limit: 500},
+			{Department: "Science", Limit: 200},
+		}, map[string]Price{"Science": 500}},
+		{"Start before term", []Budget{
+			{Department: "Science", Limit: 200},
+			{Department: "Science", TermID: &term, Limit: 500},
+			{Department: "Art", Limit: 100},
+		}, map[string]Price{"Science": 500, "Art": 100}},
+	}
+
+	for _, d := range testdata {
+		got := make(map[string]Price)
+		for dept, b := range termBudgets(d.Budgets) {
+			if b.Department != dept {
+				t.Errorf("%s: budget of %q keyed by %q", d.Name, b.Department, dept)
+			}
+			got[dept] = b.Limit
+		}
+
+		if !reflect.DeepEqual(got, d.Expect) {
+			t.Errorf("%s: expected %v, got %v", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestCostReportAfterClean(t *testing.T) {
+	db := testDB(t)
+
+	owner := testOwner(t, db, "Science")
+	item := testItem(t, db, "Power Pack", 20, 150)
+
+	start := time.Now().Add(-2 * time.Hour)
+	term := Term{Name: "Test", Start: start.Add(-24 * time.Hour), End: start.Add(24 * time.Hour)}
+	check := func(when string) {
+		t.Helper()
+
+		rep, err := GetCostReport(db, term)
+		if err != nil {
+			t.Fatal(when, err)
+		}
+		if rep.Total != 600 || len(rep.Bookings) != 1 {
+			t.Errorf("%s: expected 1 booking costing £6.00, got %d costing %s", when, len(rep.Bookings), rep.Total)
+		}
+		want := CostTotal{Name: "Science", Bookings: 1, Cost: 600}
+		if len(rep.Departments) != 1 || rep.Departments[0] != want {
+			t.Errorf("%s: departments: expected [%v], got %v", when, want, rep.Departments)
+		}
+
+		spend, err := GetDepartmentSpend(db, "Science", term)
+		if err != nil {
+			t.Fatal(when, err)
+		}
+		if spend != want {
+			t.Errorf("%s: department spend: expected %v, got %v", when, want, spend)
+		}
+	}
+
+	bk := testBooking(t, db, owner, item, 4, "Ohm's Law", "Physics", start, start.Add(time.Hour))
+	if _, err := ChargeBooking(db, bk.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Cancelled bookings are never charged for.
+	cancelled := testBooking(t, db, owner, item, 2, "Cancelled", "Physics", start, start.Add(time.Hour))
+	if _, err := ChargeBooking(db, cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&cancelled).Error; err != nil {
+		t.Fatal(err)
+	}
+	check("charged")
+
+	if err := db.Model(&item).Update("unit_cost", 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	check("after price change")
+
+	if n, err := CleanBookings(db); err != nil || n != 1 {
+		t.Fatalf("clean bookings: cleaned %d, error %v", n, err)
+	}
+	check("after clean")
+
+	// Even once old enough to be removed for good.
+	old := time.Now().Add(-4 * 7 * 24 * time.Hour)
+	for _, m := range []any{&Booking{}, &Activity{}, &EquipmentSet{}} {
+		if err := db.Unscoped().Model(m).Where("deleted_at IS NOT NULL").Update("deleted_at", old).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := CleanDeleted(db); err != nil {
+		t.Fatal(err)
+	}
+	check("after clean deleted")
+
+	var n int64
+	db.Unscoped().Model(&Booking{}).Where("id = ?", cancelled.ID).Count(&n)
+	if n != 0 {
+		t.Error("cancelled booking not removed by clean deleted")
+	}
+}
+
+func TestBudgetFollowsTerm(t *testing.T) {
+	db := testDB(t)
+
+	autumn := SchoolDate{Name: "Autumn", Kind: DateTerm, Start: day(2026, 9, 3), End: day(2026, 12, 16)}
+	if err := SaveSchoolDate(db, &autumn); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := GetAcademicCalendar(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetBudget(db, "Science", cal.TermOf(day(2026, 10, 1)), 50000); err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving the start of the term keeps its budget.
+	autumn.Start = day(2026, 9, 1)
+	if err := SaveSchoolDate(db, &autumn); err != nil {
+		t.Fatal(err)
+	}
+	cal, err = GetAcademicCalendar(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := GetBudgets(db, cal.TermOf(day(2026, 10, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b["Science"].Limit != 50000 {
+		t.Errorf("budget lost when term moved: got %v", b)
+	}
+
+	// Budgets of assumed terms are kept by start.
+	spring := cal.TermOf(day(2027, 2, 1))
+	if spring.ID != 0 {
+		t.Fatalf("expected assumed spring term, got term %d", spring.ID)
+	}
+	if err := SetBudget(db, "Science", spring, 20000); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := GetBudgets(db, spring); err != nil || b["Science"].Limit != 20000 {
+		t.Errorf("assumed term budget: expected £200.00, got %v (error %v)", b, err)
+	}
+}
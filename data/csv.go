@@ -0,0 +1,367 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Inventory CSV errors.
+var (
+	ErrCSVHeader  = errors.New("bad CSV header")
+	ErrCSVInvalid = errors.New("import contains invalid rows")
+)
+
+// ItemCSVColumns are the columns of an inventory CSV file, in the order in
+// which they are exported. Columns are named after the JSON fields of an
+// EquipmentItem, apart from "code", which holds the label code of an item, and
+// "bookable", which holds whether the item may be booked at all. The stock
+// actually free depends on bookings, so is not exported.
+var ItemCSVColumns = []string{
+	"code", "name", "description", "category", "tags", "quantity", "unit_cost",
+	"bookable",
+	"ghs_explosive", "ghs_flammable", "ghs_oxidising", "ghs_gas", "ghs_corrosive",
+	"ghs_toxic", "ghs_harmful", "ghs_health", "ghs_environment",
+	"signal_word", "hazard_statements", "precaution_statements",
+	"service_interval",
+}
+
+// itemCSVAliases maps the former names of inventory CSV columns to their
+// current names, so that older exports may still be imported.
+var itemCSVAliases = map[string]string{
+	"available": "bookable",
+}
+
+// csvFlags returns pointers to each boolean field of e, keyed by CSV column.
+func csvFlags(e *EquipmentItem) map[string]*bool {
+	return map[string]*bool{
+		"bookable":        &e.Available,
+		"ghs_explosive":   &e.GHSExplosive,
+		"ghs_flammable":   &e.GHSFlammable,
+		"ghs_oxidising":   &e.GHSOxidising,
+		"ghs_gas":         &e.GHSGas,
+		"ghs_corrosive":   &e.GHSCorrosive,
+		"ghs_toxic":       &e.GHSToxic,
+		"ghs_harmful":     &e.GHSHarmful,
+		"ghs_health":      &e.GHSHealth,
+		"ghs_environment": &e.GHSEnvironment,
+	}
+}
+
+// csvBool parses the many ways in which a spreadsheet may express a boolean.
+// Blank cells are false.
+func csvBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "0", "n", "no", "false", "f":
+		return false, nil
+	case "1", "y", "yes", "true", "t", "x":
+		return true, nil
+	default:
+		return false, fmt.Errorf("%q is not yes or no", s)
+	}
+}
+
+// record returns the CSV record for e.
+func (e EquipmentItem) record() []string {
+	flags := csvFlags(&e)
+	rec := make([]string, len(ItemCSVColumns))
+	for i, col := range ItemCSVColumns {
+		switch col {
+		case "code":
+			rec[i] = e.LabelCode()
+		case "name":
+			rec[i] = e.Name
+		case "description":
+			rec[i] = e.Description
+		case "category":
+			rec[i] = string(e.Category)
+		case "tags":
+			rec[i] = e.Tags
+		case "quantity":
+			rec[i] = strconv.FormatUint(uint64(e.Quantity), 10)
+		case "unit_cost":
+			rec[i] = e.UnitCost.Decimal()
+		case "service_interval":
+			rec[i] = strconv.FormatUint(uint64(e.ServiceInterval), 10)
+		case "signal_word":
+			rec[i] = e.SignalWord
+		case "hazard_statements":
+			rec[i] = e.HazardStatements
+		case "precaution_statements":
+			rec[i] = e.PrecautionStatements
+		default:
+			rec[i] = strconv.FormatBool(*flags[col])
+		}
+	}
+
+	return rec
+}
+
+// set sets the field of e for the given CSV column.
+func (e *EquipmentItem) set(col, val string) error {
+	val = strings.TrimSpace(val)
+
+	switch col {
+	case "code":
+		// Only used for matching.
+	case "name":
+		if val == "" {
+			return errors.New("name may not be blank")
+		}
+		e.Name = val
+	case "description":
+		e.Description = val
+	case "category":
+		e.Category = ItemCategory(strings.ToLower(val))
+		if !e.Category.Valid() {
+			return fmt.Errorf("unknown category %q", val)
+		}
+	case "tags":
+		e.Tags = NormaliseTags(val)
+	case "quantity":
+		q, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("quantity %q is not a whole number", val)
+		}
+		e.Quantity = uint(q)
+	case "unit_cost":
+		p, err := ParsePrice(val)
+		if err != nil {
+			return fmt.Errorf("unit cost %q: %w", val, err)
+		}
+		e.UnitCost = p
+	case "service_interval":
+		if val == "" {
+			e.ServiceInterval = 0
+			break
+		}
+		d, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("service interval %q is not a whole number of days", val)
+		}
+		e.ServiceInterval = uint(d)
+	case "signal_word":
+		e.SignalWord = val
+		if len(val) > 0 {
+			e.SignalWord = strings.ToUpper(val[:1]) + strings.ToLower(val[1:])
+		}
+		if !e.ValidSignalWord() {
+			return fmt.Errorf("signal word %q must be blank, Warning or Danger", val)
+		}
+	case "hazard_statements":
+		e.HazardStatements = strings.Join(ParseStatements(val), ", ")
+	case "precaution_statements":
+		e.PrecautionStatements = strings.Join(ParseStatements(val), ", ")
+	default:
+		b, err := csvBool(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", col, err)
+		}
+		*csvFlags(e)[col] = b
+	}
+
+	return nil
+}
+
+// WriteItemCSV writes the given items to w as CSV, with a header row.
+func WriteItemCSV(w io.Writer, items []EquipmentItem) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ItemCSVColumns); err != nil {
+		return fmt.Errorf("write item csv: %w", err)
+	}
+
+	for _, e := range items {
+		if err := cw.Write(e.record()); err != nil {
+			return fmt.Errorf("write item csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("write item csv: %w", err)
+	}
+
+	return nil
+}
+
+// An ImportRow is a single row of an inventory CSV import, along with the
+// item it would create or update and any problems found with it.
+type ImportRow struct {
+	Line   int
+	Item   EquipmentItem
+	Update bool
+	Errors []string
+}
+
+// Valid returns true if the row may be imported.
+func (r ImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// ParseItemCSV reads an inventory CSV file from r, matching each row against
+// the existing inventory. Rows match an existing item by their label code if
+// one is given, else by name (ignoring case). Matched items have only the
+// columns present in the file updated; unmatched rows create new items, which
+// are available unless stated otherwise.
+//
+// Problems with individual rows are recorded against each row. An error is
+// only returned if the file as a whole cannot be read.
+func ParseItemCSV(db *gorm.DB, r io.Reader) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parse item csv: %w: empty file", ErrCSVHeader)
+		}
+		return nil, fmt.Errorf("parse item csv: %w", err)
+	}
+
+	known := make(map[string]bool, len(ItemCSVColumns))
+	for _, col := range ItemCSVColumns {
+		known[col] = true
+	}
+
+	cols := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, h := range header {
+		col := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if alias, ok := itemCSVAliases[col]; ok {
+			col = alias
+		}
+		if !known[col] {
+			return nil, fmt.Errorf("parse item csv: %w: unknown column %q", ErrCSVHeader, h)
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("parse item csv: %w: duplicate column %q", ErrCSVHeader, h)
+		}
+
+		cols[i] = col
+		seen[col] = true
+	}
+	if !seen["name"] && !seen["code"] {
+		return nil, fmt.Errorf("parse item csv: %w: a name or code column is required", ErrCSVHeader)
+	}
+
+	existing, err := GetEquipment(db)
+	if err != nil {
+		return nil, fmt.Errorf("parse item csv: %w", err)
+	}
+
+	byID := make(map[uint]EquipmentItem, len(existing))
+	byName := make(map[string]EquipmentItem, len(existing))
+	for _, e := range existing {
+		byID[e.ID] = e
+		byName[strings.ToLower(e.Name)] = e
+	}
+
+	rows := make([]ImportRow, 0, len(existing))
+	claimed := make(map[string]int)
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		row := ImportRow{}
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, fmt.Errorf("parse item csv: %w", err)
+			}
+
+			row.Line = perr.StartLine
+			row.Errors = append(row.Errors, perr.Err.Error())
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Line, _ = cr.FieldPos(0)
+
+		vals := make(map[string]string, len(cols))
+		for i, col := range cols {
+			vals[col] = rec[i]
+		}
+
+		// Match by code first, falling back to name.
+		row.Item = EquipmentItem{Model: &gorm.Model{}, Available: true}
+		if code := strings.TrimSpace(vals["code"]); code != "" {
+			id, err := ParseLabelCode(code)
+			e, ok := byID[id]
+			if err != nil || !ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("no item has the code %q", code))
+			} else {
+				row.Item, row.Update = e, true
+			}
+		} else if e, ok := byName[strings.ToLower(strings.TrimSpace(vals["name"]))]; ok {
+			row.Item, row.Update = e, true
+		} else if !seen["name"] {
+			row.Errors = append(row.Errors, "new items must have a name")
+		}
+
+		for _, col := range cols {
+			// Items matched by code may leave their name blank.
+			if col == "name" && vals["code"] != "" && strings.TrimSpace(vals[col]) == "" {
+				continue
+			}
+
+			if err := row.Item.set(col, vals[col]); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+
+		// Two rows may not refer to the same item.
+		key := strings.ToLower(row.Item.Name)
+		if row.Update {
+			key = row.Item.LabelCode()
+		}
+		if prev, ok := claimed[key]; ok && key != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("same item as line %d", prev))
+		} else {
+			claimed[key] = row.Line
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// ImportItems creates or updates the items of each row. Either every row is
+// imported, or none are. Rows must have been parsed by ParseItemCSV and
+// contain no errors.
+func ImportItems(db *gorm.DB, rows []ImportRow, user uint) error {
+	for _, r := range rows {
+		if !r.Valid() {
+			return fmt.Errorf("import items: line %d: %w", r.Line, ErrCSVInvalid)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, r := range rows {
+			item := r.Item
+			before, action := EquipmentItem{}, AuditAction(AuditCreate)
+			if r.Update {
+				if err := tx.Where("id = ?", item.ID).First(&before).Error; err != nil {
+					return fmt.Errorf("import items: line %d: sql error: %w", r.Line, err)
+				}
+				action = AuditUpdate
+			}
+
+			if err := tx.Save(&item).Error; err != nil {
+				return fmt.Errorf("import items: line %d: sql error: %w", r.Line, err)
+			}
+			if err := AuditItem(tx, user, action, "CSV import", before, item); err != nil {
+				return fmt.Errorf("import items: line %d: %w", r.Line, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package data
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// testModels are the models migrated for tests, in the same order as those
+// of the server.
+var testModels = []any{
+	&PrepRoom{},
+	&User{},
+	&Booking{}, &Activity{},
+	&EquipmentSet{}, &EquipmentItem{},
+	&ChemicalBatch{},
+	&StorageLocation{}, &ItemStock{}, &StockMove{},
+	&ServiceRecord{}, &EquipmentUnit{},
+	&ReturnRecord{},
+	&ItemAudit{}, &ItemChange{},
+	&Kit{}, &KitItem{},
+	&ItemSubstitute{},
+	&Supplier{}, &CatalogueEntry{},
+	&PurchaseOrder{}, &OrderLine{},
+	&Budget{},
+	&Room{},
+	&SchoolDate{},
+}
+
+// testDB returns a connection to the MySQL database given by the
+// PREPPER_TEST_DSN environment variable (such as
+// "user:pass@tcp(localhost:3306)/prepper_test?parseTime=true&loc=UTC"), with
+// the schema migrated and every table emptied. Tests needing a database are
+// skipped if it is not set.
+//
+// Every table of the database is emptied, so never point this at a database
+// in use.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("PREPPER_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PREPPER_TEST_DSN not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal("open test database:", err)
+	}
+	sdb, err := db.DB()
+	if err != nil {
+		t.Fatal("open test database:", err)
+	}
+	// Foreign key checks are disabled per connection.
+	sdb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sdb.Close() })
+
+	if err := db.AutoMigrate(testModels...); err != nil {
+		t.Fatal("migrate test database:", err)
+	}
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatal("empty test database:", err)
+	}
+	db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+	for _, tb := range tables {
+		if err := db.Exec("TRUNCATE TABLE " + tb).Error; err != nil {
+			t.Fatal("empty test database:", err)
+		}
+	}
+	db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+
+	return db
+}
+
+// testCreate inserts v into db without its associations, failing the test
+// on error.
+func testCreate(t *testing.T, db *gorm.DB, v any) {
+	t.Helper()
+
+	if err := db.Omit(clause.Associations).Create(v).Error; err != nil {
+		t.Fatalf("create %T: %v", v, err)
+	}
+}
+
+// testOwner creates a teacher in the given department.
+func testOwner(t *testing.T, db *gorm.DB, department string) User {
+	t.Helper()
+
+	u := User{Model: &gorm.Model{}, Username: "jsmith", FirstName: "Jane", Department: department}
+	testCreate(t, db, &u)
+
+	return u
+}
+
+// testItem creates a bookable item with the given stock and unit cost.
+func testItem(t *testing.T, db *gorm.DB, name string, quantity uint, cost Price) EquipmentItem {
+	t.Helper()
+
+	it := EquipmentItem{Model: &gorm.Model{}, Name: name, Quantity: quantity, Available: true, UnitCost: cost}
+	testCreate(t, db, &it)
+
+	return it
+}
+
+// testBooking books quantity of item for owner for the given period, with an
+// activity of the given title and category.
+func testBooking(t *testing.T, db *gorm.DB, owner User, item EquipmentItem, quantity uint, title, category string, start, end time.Time) Booking {
+	t.Helper()
+
+	act := Activity{Model: &gorm.Model{}, Title: title, Category: category, OwnerID: owner.ID, Temporary: true}
+	testCreate(t, db, &act)
+	set := EquipmentSet{Model: &gorm.Model{}, ActivityID: act.ID, ItemID: item.ID, Quantity: quantity}
+	testCreate(t, db, &set)
+
+	bk, err := NewBooking(db, act, "Lab 1", start, end, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bk, err = GetBooking(db, bk.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bk
+}
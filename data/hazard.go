@@ -0,0 +1,170 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GHS signal words, in increasing order of severity. An item with no signal
+// word carries no classified hazard.
+const (
+	SignalNone    = ""
+	SignalWarning = "Warning"
+	SignalDanger  = "Danger"
+)
+
+// A Pictogram is one of the nine GHS hazard pictograms.
+type Pictogram struct {
+	Code string
+	Name string
+}
+
+// The nine GHS pictograms, in order of their codes.
+var (
+	PictogramExplosive   = Pictogram{"GHS01", "Explosive"}
+	PictogramFlammable   = Pictogram{"GHS02", "Flammable"}
+	PictogramOxidising   = Pictogram{"GHS03", "Oxidising"}
+	PictogramGas         = Pictogram{"GHS04", "Gas Under Pressure"}
+	PictogramCorrosive   = Pictogram{"GHS05", "Corrosive"}
+	PictogramToxic       = Pictogram{"GHS06", "Acute Toxicity"}
+	PictogramHarmful     = Pictogram{"GHS07", "Harmful"}
+	PictogramHealth      = Pictogram{"GHS08", "Health Hazard"}
+	PictogramEnvironment = Pictogram{"GHS09", "Environmental Hazard"}
+)
+
+// signalRank orders signal words by severity.
+func signalRank(s string) int {
+	switch s {
+	case SignalWarning:
+		return 1
+	case SignalDanger:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Hazards is a GHS classification summary. It may describe a single item or
+// the combined hazards of many.
+type Hazards struct {
+	Pictograms []Pictogram
+	SignalWord string
+
+	HazardStatements     []string
+	PrecautionStatements []string
+}
+
+// Any returns true if any hazard is recorded in this summary.
+func (h Hazards) Any() bool {
+	return len(h.Pictograms) != 0 || h.SignalWord != SignalNone ||
+		len(h.HazardStatements) != 0 || len(h.PrecautionStatements) != 0
+}
+
+// Danger returns true if the signal word for this summary is "Danger".
+func (h Hazards) Danger() bool {
+	return h.SignalWord == SignalDanger
+}
+
+// Merge returns the union of the hazards in h and o. The more severe of the
+// two signal words is kept and statements are de-duplicated and sorted.
+func (h Hazards) Merge(o Hazards) Hazards {
+	r := Hazards{SignalWord: h.SignalWord}
+	if signalRank(o.SignalWord) > signalRank(r.SignalWord) {
+		r.SignalWord = o.SignalWord
+	}
+
+	r.Pictograms = append(append(r.Pictograms, h.Pictograms...), o.Pictograms...)
+	sort.Slice(r.Pictograms, func(i, j int) bool { return r.Pictograms[i].Code < r.Pictograms[j].Code })
+	r.Pictograms = uniquePictograms(r.Pictograms)
+
+	r.HazardStatements = mergeStatements(h.HazardStatements, o.HazardStatements)
+	r.PrecautionStatements = mergeStatements(h.PrecautionStatements, o.PrecautionStatements)
+
+	return r
+}
+
+// uniquePictograms removes adjacent duplicates from a sorted slice.
+func uniquePictograms(p []Pictogram) []Pictogram {
+	out := p[:0]
+	for i, pg := range p {
+		if i == 0 || p[i-1] != pg {
+			out = append(out, pg)
+		}
+	}
+
+	return out
+}
+
+// mergeStatements returns the sorted union of two sets of statements.
+func mergeStatements(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+
+	sort.Strings(out)
+	return out
+}
+
+// ParseStatements splits a free-text list of H or P statement codes (such as
+// "H225, H319") into individual upper-case codes.
+func ParseStatements(s string) []string {
+	f := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	})
+
+	out := make([]string, 0, len(f))
+	for _, st := range f {
+		out = append(out, strings.ToUpper(st))
+	}
+
+	return out
+}
+
+// legacyHazards are the steps converting each legacy hazard flag to GHS, keyed
+// by the old column.
+var legacyHazards = []struct {
+	Column string
+	Stmt   string
+}{
+	{"hazard_toxic", "UPDATE equipment_items SET ghs_toxic = TRUE, signal_word = 'Danger' WHERE hazard_toxic"},
+	{"hazard_misc", "UPDATE equipment_items SET ghs_harmful = TRUE, signal_word = IF(signal_word = '', 'Warning', signal_word) WHERE hazard_misc"},
+	{"hazard_voltage", "UPDATE equipment_items SET description = CONCAT(description, ' (Voltage hazard)') WHERE hazard_voltage AND description NOT LIKE '%(Voltage hazard)'"},
+	{"hazard_lazer", "UPDATE equipment_items SET description = CONCAT(description, ' (Laser hazard)') WHERE hazard_lazer AND description NOT LIKE '%(Laser hazard)'"},
+}
+
+// MigrateHazards converts the legacy hazard flags (voltage, toxic, laser and
+// miscellaneous) to their closest GHS equivalents and drops the old columns.
+// Voltage and laser hazards have no GHS pictogram, so are noted in the item
+// description instead.
+//
+// MySQL commits implicitly when a column is dropped, so the migration cannot
+// be made atomic. Instead, each flag is converted then dropped in turn and
+// every step may safely be repeated, so a migration which failed part way
+// through is finished by running it again. This is a no-op once the old
+// columns are gone.
+func MigrateHazards(db *gorm.DB) error {
+	m := db.Migrator()
+	for _, h := range legacyHazards {
+		if !m.HasColumn(&EquipmentItem{}, h.Column) {
+			continue
+		}
+
+		if err := db.Exec(h.Stmt).Error; err != nil {
+			return fmt.Errorf("migrate hazards: %s: sql error: %w", h.Column, err)
+		}
+		if err := m.DropColumn(&EquipmentItem{}, h.Column); err != nil {
+			return fmt.Errorf("migrate hazards: drop %s: %w", h.Column, err)
+		}
+	}
+
+	return nil
+}
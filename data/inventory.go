@@ -1,29 +1,70 @@
 package data
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// LabelPrefix is prepended to item IDs on barcode labels, so that scanned item
+// labels may be told apart from other barcodes, such as manufacturer codes.
+const LabelPrefix = "PREP"
+
+// ErrBadLabelCode is returned when a scanned code is not an item label.
+var ErrBadLabelCode = errors.New("not an item label")
+
 // An EquipmentItem is an entry in the inventory. It has an associated stock
 // level and some flags to show the status of the equipment (such as any
 // warnings for hazards etc).
 type EquipmentItem struct {
 	*gorm.Model
+	Tenancy
 
 	Name        string `json:"name"`
 	Description string `json:"description"`
 
+	Category ItemCategory `json:"category"`
+	// Comma separated free-form tags, as normalised by NormaliseTags.
+	Tags string `json:"tags"`
+
 	Quantity uint `json:"quantity"`
 	// Availability override. If false, quantity is treated as though zero.
 	Available bool `json:"available"`
+	// Cost of a single item, used to cost bookings.
+	UnitCost Price `json:"unit_cost"`
+
+	// Prep room holding this stock. If nil, the item is shared by every
+	// prep room.
+	PrepRoomID *uint     `json:"prep_room_id"`
+	PrepRoom   *PrepRoom `json:"-"`
+
+	// Days between required services or tests, such as yearly PAT tests.
+	// Zero if the item need not be serviced.
+	ServiceInterval uint `json:"service_interval"`
+
+	// GHS hazard pictograms.
+	GHSExplosive   bool `json:"ghs_explosive"`
+	GHSFlammable   bool `json:"ghs_flammable"`
+	GHSOxidising   bool `json:"ghs_oxidising"`
+	GHSGas         bool `json:"ghs_gas"`
+	GHSCorrosive   bool `json:"ghs_corrosive"`
+	GHSToxic       bool `json:"ghs_toxic"`
+	GHSHarmful     bool `json:"ghs_harmful"`
+	GHSHealth      bool `json:"ghs_health"`
+	GHSEnvironment bool `json:"ghs_environment"`
+
+	// GHS signal word and H/P statement codes, as printed on the label.
+	SignalWord           string `json:"signal_word"`
+	HazardStatements     string `json:"hazard_statements"`
+	PrecautionStatements string `json:"precaution_statements"`
 
-	HazardVoltage bool `json:"hazard_voltage"`
-	HazardToxic   bool `json:"hazard_toxic"`
-	HazardLazer   bool `json:"hazard_lazer"`
-	HazardMisc    bool `json:"hazard_misc"`
+	// File name of the uploaded safety data sheet within the uploads
+	// directory. Empty if none has been uploaded.
+	SafetyDataSheet string `json:"-"`
 
 	// For convenience.
 	db *gorm.DB
@@ -53,6 +94,8 @@ func (e *EquipmentItem) Bookings(start, end time.Time) ([]Booking, error) {
 			start, start, // Top two cases
 			start, end, // Bottom two cases
 		).
+		// Rejected and waitlisted bookings hold no stock.
+		Where("bookings.status NOT IN ?", uncommittedStatuses).
 		Joins("Owner").
 		Preload("Activity.Equipment").
 		Preload("Activity.Equipment.Item").
@@ -120,12 +163,81 @@ func (e *EquipmentItem) NetQuantity(start, end time.Time) (int, error) {
 	return int(e.Quantity) - u, err
 }
 
+// Hazards returns the GHS classification of this item.
+func (e EquipmentItem) Hazards() Hazards {
+	flags := []struct {
+		set bool
+		p   Pictogram
+	}{
+		{e.GHSExplosive, PictogramExplosive},
+		{e.GHSFlammable, PictogramFlammable},
+		{e.GHSOxidising, PictogramOxidising},
+		{e.GHSGas, PictogramGas},
+		{e.GHSCorrosive, PictogramCorrosive},
+		{e.GHSToxic, PictogramToxic},
+		{e.GHSHarmful, PictogramHarmful},
+		{e.GHSHealth, PictogramHealth},
+		{e.GHSEnvironment, PictogramEnvironment},
+	}
+
+	h := Hazards{SignalWord: e.SignalWord}
+	for _, f := range flags {
+		if f.set {
+			h.Pictograms = append(h.Pictograms, f.p)
+		}
+	}
+
+	h.HazardStatements = mergeStatements(ParseStatements(e.HazardStatements), nil)
+	h.PrecautionStatements = mergeStatements(ParseStatements(e.PrecautionStatements), nil)
+
+	return h
+}
+
+// NeedsService returns true if the item must be regularly serviced.
+func (e EquipmentItem) NeedsService() bool {
+	return e.ServiceInterval > 0
+}
+
+// ValidSignalWord returns true if the signal word of this item is either
+// empty or one of the two GHS signal words.
+func (e EquipmentItem) ValidSignalWord() bool {
+	switch e.SignalWord {
+	case SignalNone, SignalWarning, SignalDanger:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasSafetyDataSheet returns true if a safety data sheet has been uploaded for
+// this item.
+func (e EquipmentItem) HasSafetyDataSheet() bool {
+	return e.SafetyDataSheet != ""
+}
+
 // VisualID is very useless in Go but very useful in Go templates where math is
 // strictly disallowed!
 func (e *EquipmentItem) VisualID() uint {
 	return e.ID + 1
 }
 
+// LabelCode returns the code printed on barcode labels for this item.
+func (e EquipmentItem) LabelCode() string {
+	return fmt.Sprint(LabelPrefix, e.ID)
+}
+
+// ParseLabelCode returns the item ID encoded in a scanned label code. Bare item
+// IDs are also accepted, for labels which have been typed in by hand.
+func ParseLabelCode(code string) (uint, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	id, err := strconv.ParseUint(strings.TrimPrefix(code, LabelPrefix), 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("parse label %q: %w", code, ErrBadLabelCode)
+	}
+
+	return uint(id), nil
+}
+
 // UseDB updates the internal database to a new instance. This shouldn't really
 // be used unless really needed.
 func (e *EquipmentItem) UseDB(db *gorm.DB) {
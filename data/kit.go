@@ -0,0 +1,167 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Kit errors.
+var (
+	ErrInvalidKitID = errors.New("invalid kit ID")
+	ErrNoSuchKit    = errors.New("kit does not exist")
+	ErrKitName      = errors.New("kit must have a name")
+)
+
+// A Kit is a named bundle of equipment which is commonly used together, such
+// as a titration kit. Kits may be added to activities and bookings as a unit,
+// at which point they are expanded into their component items.
+type Kit struct {
+	*gorm.Model
+	Tenancy
+
+	Name        string
+	Description string
+
+	Items []KitItem
+}
+
+// A KitItem is a single component of a kit, with the quantity of the item
+// included in one kit.
+type KitItem struct {
+	*gorm.Model
+	Tenancy
+	KitID uint
+
+	ItemID   uint
+	Item     EquipmentItem
+	Quantity uint
+}
+
+// Contents returns a short, human readable summary of the kit's components.
+func (k Kit) Contents() string {
+	parts := make([]string, 0, len(k.Items))
+	for _, i := range k.Items {
+		parts = append(parts, fmt.Sprint(i.Quantity, "x ", i.Item.Name))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Sets returns the equipment sets needed for n of this kit. Sets are marked
+// as important as given.
+func (k Kit) Sets(n uint, important bool) []EquipmentSet {
+	sets := make([]EquipmentSet, 0, len(k.Items))
+	for _, i := range k.Items {
+		sets = append(sets, EquipmentSet{
+			Quantity:  i.Quantity * n,
+			Important: important,
+			ItemID:    i.ItemID,
+			Item:      i.Item,
+		})
+	}
+
+	return sets
+}
+
+// GetKit returns the kit with the given ID, with its components and their
+// items joined.
+func GetKit(db *gorm.DB, id uint) (Kit, error) {
+	if id == 0 {
+		return Kit{}, fmt.Errorf("get kit %d: %w", id, ErrInvalidKitID)
+	}
+
+	k := Kit{}
+	res := db.Preload("Items").Preload("Items.Item").Where("id = ?", id).First(&k)
+	if err := res.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return k, fmt.Errorf("get kit %d: %w", id, ErrNoSuchKit)
+		}
+
+		return k, fmt.Errorf("get kit %d: sql error: %w", id, err)
+	}
+
+	return k, nil
+}
+
+// GetKits returns every kit, sorted by name, with components joined.
+func GetKits(db *gorm.DB) ([]Kit, error) {
+	k := make([]Kit, 0, 10)
+	res := db.Preload("Items").Preload("Items.Item").Order("name ASC").Find(&k)
+	if err := res.Error; err != nil {
+		return k, fmt.Errorf("get kits: sql error: %w", err)
+	}
+
+	return k, nil
+}
+
+// NewKit creates a new, empty kit.
+func NewKit(db *gorm.DB, name, desc string) (Kit, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Kit{}, fmt.Errorf("new kit: %w", ErrKitName)
+	}
+
+	k := Kit{Model: &gorm.Model{}, Name: name, Description: desc}
+	if err := db.Create(&k).Error; err != nil {
+		return k, fmt.Errorf("new kit: sql error: %w", err)
+	}
+
+	return k, nil
+}
+
+// SaveKit saves the name, description and components of a kit, replacing all
+// of its existing components. Components with a zero quantity are dropped.
+func SaveKit(db *gorm.DB, k Kit) error {
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		return fmt.Errorf("save kit %d: %w", k.ID, ErrKitName)
+	}
+
+	items := make([]KitItem, 0, len(k.Items))
+	for _, i := range k.Items {
+		if i.Quantity == 0 {
+			continue
+		}
+
+		items = append(items, KitItem{Model: &gorm.Model{}, KitID: k.ID, ItemID: i.ItemID, Quantity: i.Quantity})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Kit{}).Where("id = ?", k.ID).Updates(map[string]any{
+			"name":        k.Name,
+			"description": k.Description,
+		}).Error; err != nil {
+			return fmt.Errorf("save kit %d: sql error: %w", k.ID, err)
+		}
+
+		if err := tx.Where("kit_id = ?", k.ID).Delete(&KitItem{}).Error; err != nil {
+			return fmt.Errorf("save kit %d: sql error: %w", k.ID, err)
+		}
+
+		if len(items) > 0 {
+			if err := tx.Omit("Item").Create(&items).Error; err != nil {
+				return fmt.Errorf("save kit %d: sql error: %w", k.ID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteKit deletes a kit and its components. Activities and bookings to
+// which the kit was added are unaffected, as kits are expanded when added.
+func DeleteKit(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kit_id = ?", id).Delete(&KitItem{}).Error; err != nil {
+			return fmt.Errorf("delete kit %d: sql error: %w", id, err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&Kit{}).Error; err != nil {
+			return fmt.Errorf("delete kit %d: sql error: %w", id, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,333 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Storage location kinds. Locations nest in this order, such that a shelf is
+// inside a cupboard, which is inside a room, which is inside a building.
+const (
+	LocationBuilding = iota
+	LocationRoom
+	LocationCupboard
+	LocationShelf
+)
+
+// Storage location and stock errors.
+var (
+	ErrInvalidLocationID = errors.New("invalid location ID")
+	ErrNoSuchLocation    = errors.New("location does not exist")
+	ErrBadNesting        = errors.New("location may not be nested here")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// LocationKind is the enumerator type for each level of the storage
+// hierarchy.
+type LocationKind uint8
+
+func (l LocationKind) String() string {
+	switch l {
+	case LocationBuilding:
+		return "Building"
+	case LocationRoom:
+		return "Room"
+	case LocationCupboard:
+		return "Cupboard"
+	case LocationShelf:
+		return "Shelf"
+	default:
+		return "Unknown"
+	}
+}
+
+// A StorageLocation is a physical place in which inventory is stored. Every
+// location other than a building has a parent of a shallower kind.
+type StorageLocation struct {
+	*gorm.Model
+	Tenancy
+
+	Name string
+	Kind LocationKind
+
+	ParentID *uint
+	Parent   *StorageLocation
+}
+
+// Locations is a set of storage locations, used to look up parents when
+// formatting full location paths.
+type Locations []StorageLocation
+
+// Find returns the location with the given ID, or nil if none exists.
+func (l Locations) Find(id uint) *StorageLocation {
+	for i := range l {
+		if l[i].ID == id {
+			return &l[i]
+		}
+	}
+
+	return nil
+}
+
+// Path returns the full, human readable path of the location with the given
+// ID, such as "Science Block > Lab 3 > Cupboard B > Top Shelf".
+func (l Locations) Path(id uint) string {
+	parts := make([]string, 0, 4)
+
+	// Bounded by the depth of the hierarchy to avoid looping forever on
+	// corrupt data.
+	cur := l.Find(id)
+	for i := 0; cur != nil && i <= LocationShelf; i++ {
+		parts = append(parts, cur.Name)
+		if cur.ParentID == nil {
+			break
+		}
+		cur = l.Find(*cur.ParentID)
+	}
+
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+
+	return strings.Join(parts, " > ")
+}
+
+// Sorted returns the locations sorted by their full path, such that children
+// appear directly beneath their parents.
+func (l Locations) Sorted() Locations {
+	s := make(Locations, len(l))
+	copy(s, l)
+
+	sort.SliceStable(s, func(i, j int) bool {
+		return l.Path(s[i].ID) < l.Path(s[j].ID)
+	})
+
+	return s
+}
+
+// NewStorageLocation creates a new location of the given kind. If the kind is
+// not a building, parent must refer to a location of a shallower kind.
+func NewStorageLocation(db *gorm.DB, name string, kind LocationKind, parent *uint) (StorageLocation, error) {
+	loc := StorageLocation{Name: name, Kind: kind, ParentID: parent}
+
+	if kind > LocationShelf {
+		return loc, fmt.Errorf("new location %s: %w", name, ErrBadNesting)
+	}
+
+	if kind == LocationBuilding {
+		loc.ParentID = nil
+	} else {
+		if parent == nil {
+			return loc, fmt.Errorf("new location %s: %w", name, ErrBadNesting)
+		}
+
+		p, err := GetStorageLocation(db, *parent)
+		if err != nil {
+			return loc, fmt.Errorf("new location %s: %w", name, err)
+		}
+
+		if p.Kind >= kind {
+			return loc, fmt.Errorf("new location %s: %s in %s: %w", name, kind, p.Kind, ErrBadNesting)
+		}
+	}
+
+	if err := db.Create(&loc).Error; err != nil {
+		return loc, fmt.Errorf("new location %s: sql error: %w", name, err)
+	}
+
+	return loc, nil
+}
+
+// GetStorageLocation looks up a single storage location by ID.
+func GetStorageLocation(db *gorm.DB, id uint) (StorageLocation, error) {
+	if id == 0 {
+		return StorageLocation{}, fmt.Errorf("get location %d: %w", id, ErrInvalidLocationID)
+	}
+
+	l := StorageLocation{Model: &gorm.Model{ID: id}}
+	if err := db.Where(&l).First(&l).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return l, fmt.Errorf("get location %d: %w", id, ErrNoSuchLocation)
+		}
+
+		return l, fmt.Errorf("get location %d: sql error: %w", id, err)
+	}
+
+	return l, nil
+}
+
+// GetStorageLocations returns every storage location.
+func GetStorageLocations(db *gorm.DB) (Locations, error) {
+	var l Locations
+	if err := db.Find(&l).Error; err != nil {
+		return l, fmt.Errorf("get locations: sql error: %w", err)
+	}
+
+	return l, nil
+}
+
+// ItemStock is the quantity of an item kept at a single storage location.
+type ItemStock struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint
+	Item   EquipmentItem
+
+	LocationID uint
+	Location   StorageLocation
+
+	Quantity uint
+}
+
+// A StockMove is a record of stock being moved between locations. A nil
+// source or destination means that the stock came from or went to no
+// particular location (for example, newly received stock).
+type StockMove struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint
+	Item   EquipmentItem
+
+	FromID *uint
+	From   *StorageLocation
+	ToID   *uint
+	To     *StorageLocation
+
+	Quantity uint
+
+	UserID uint
+	User   User
+}
+
+// GetItemStock returns the stock held at each location for the given item,
+// largest first. Locations holding none of the item are omitted.
+func GetItemStock(db *gorm.DB, item uint) ([]ItemStock, error) {
+	s := make([]ItemStock, 0, 5)
+	res := db.Model(&ItemStock{}).Joins("Location").
+		Where(&ItemStock{ItemID: item}).
+		Where("quantity > 0").
+		Order("quantity DESC").
+		Find(&s)
+
+	if err := res.Error; err != nil {
+		return s, fmt.Errorf("get stock for item %d: sql error: %w", item, err)
+	}
+
+	return s, nil
+}
+
+// GetStockMoves returns the most recent stock moves for the given item.
+func GetStockMoves(db *gorm.DB, item uint, limit int) ([]StockMove, error) {
+	m := make([]StockMove, 0, limit)
+	res := db.Model(&StockMove{}).Joins("User").
+		Preload("From").Preload("To").
+		Where(&StockMove{ItemID: item}).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&m)
+
+	if err := res.Error; err != nil {
+		return m, fmt.Errorf("get stock moves for item %d: sql error: %w", item, err)
+	}
+
+	return m, nil
+}
+
+// LocatedQuantity returns the total quantity of an item assigned to any
+// location.
+func LocatedQuantity(stock []ItemStock) uint {
+	tot := uint(0)
+	for _, s := range stock {
+		tot += s.Quantity
+	}
+
+	return tot
+}
+
+// MoveStock moves qty of the given item from one location to another, on
+// behalf of the given user. A nil source takes stock from that which is not
+// yet assigned to a location; a nil destination leaves it unassigned. The move
+// is recorded and either happens entirely or not at all.
+func MoveStock(db *gorm.DB, item EquipmentItem, from, to *uint, qty, user uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		stock, err := GetItemStock(tx, item.ID)
+		if err != nil {
+			return fmt.Errorf("move stock: %w", err)
+		}
+
+		if from == nil {
+			if item.Quantity < LocatedQuantity(stock)+qty {
+				return fmt.Errorf("move %d %s: %w (unassigned)", qty, item.Name, ErrInsufficientStock)
+			}
+		} else {
+			src := ItemStock{}
+			err := tx.Where(&ItemStock{ItemID: item.ID, LocationID: *from}).First(&src).Error
+			if err != nil || src.Quantity < qty {
+				return fmt.Errorf("move %d %s: %w (location %d)", qty, item.Name, ErrInsufficientStock, *from)
+			}
+
+			if err := tx.Model(&src).Update("quantity", src.Quantity-qty).Error; err != nil {
+				return fmt.Errorf("move stock: sql error: %w", err)
+			}
+		}
+
+		if to != nil {
+			if _, err := GetStorageLocation(tx, *to); err != nil {
+				return fmt.Errorf("move stock: %w", err)
+			}
+
+			dst := ItemStock{ItemID: item.ID, LocationID: *to}
+			if err := tx.Where(&dst).FirstOrCreate(&dst).Error; err != nil {
+				return fmt.Errorf("move stock: sql error: %w", err)
+			}
+
+			if err := tx.Model(&dst).Update("quantity", dst.Quantity+qty).Error; err != nil {
+				return fmt.Errorf("move stock: sql error: %w", err)
+			}
+		}
+
+		mv := StockMove{ItemID: item.ID, FromID: from, ToID: to, Quantity: qty, UserID: user}
+		if err := tx.Create(&mv).Error; err != nil {
+			return fmt.Errorf("move stock: sql error: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// PickStock chooses the locations from which want of an item may be
+// collected, given stock sorted largest first. If any single location holds
+// enough, only the closest fit is returned. Otherwise locations are taken
+// largest first until enough is collected. The returned bool is false if all
+// of the located stock together is not enough. Nothing need be collected if
+// nothing is wanted.
+func PickStock(stock []ItemStock, want uint) ([]ItemStock, bool) {
+	if want == 0 {
+		return nil, true
+	}
+
+	for i := len(stock) - 1; i >= 0; i-- {
+		if stock[i].Quantity >= want {
+			return stock[i : i+1], true
+		}
+	}
+
+	picks := make([]ItemStock, 0, len(stock))
+	got := uint(0)
+	for _, s := range stock {
+		if got >= want {
+			break
+		}
+
+		picks = append(picks, s)
+		got += s.Quantity
+	}
+
+	return picks, got >= want
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPickStock(t *testing.T) {
+	// Stock is sorted largest first.
+	stock := []ItemStock{
+		{LocationID: 1, Quantity: 10},
+		{LocationID: 2, Quantity: 6},
+		{LocationID: 3, Quantity: 2},
+	}
+
+	testdata := []struct {
+		Name   string
+		Stock  []ItemStock
+		Want   uint
+		Expect []uint
+		Enough bool
+	}{
+		{"Nothing wanted", stock, 0, nil, true},
+		{"Closest fit", stock, 5, []uint{2}, true},
+		{"Exact fit", stock, 2, []uint{3}, true},
+		{"Largest only", stock, 8, []uint{1}, true},
+		{"Split largest first", stock, 14, []uint{1, 2}, true},
+		{"Everything", stock, 18, []uint{1, 2, 3}, true},
+		{"Not enough", stock, 20, []uint{1, 2, 3}, false},
+		{"Nowhere", nil, 1, nil, false},
+		{"Nothing wanted from nowhere", nil, 0, nil, true},
+	}
+
+	for _, d := range testdata {
+		picks, ok := PickStock(d.Stock, d.Want)
+
+		var got []uint
+		for _, p := range picks {
+			got = append(got, p.LocationID)
+		}
+		if !reflect.DeepEqual(got, d.Expect) || ok != d.Enough {
+			t.Errorf("%s: expected locations %v (enough %v), got %v (enough %v)", d.Name, d.Expect, d.Enough, got, ok)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Purchase order statuses. Orders move through each status in order.
+const (
+	OrderDraft = iota
+	OrderSent
+	OrderReceived
+)
+
+// Purchase order errors.
+var (
+	ErrInvalidOrderID = errors.New("invalid purchase order ID")
+	ErrNoSuchOrder    = errors.New("purchase order does not exist")
+	ErrOrderStatus    = errors.New("purchase order is not in the right state for this")
+	ErrOrderEmpty     = errors.New("purchase order has no items")
+)
+
+// OrderStatus is the enumerator type for the progress of a purchase order.
+type OrderStatus uint8
+
+func (o OrderStatus) String() string {
+	switch o {
+	case OrderDraft:
+		return "Draft"
+	case OrderSent:
+		return "Sent"
+	case OrderReceived:
+		return "Received"
+	default:
+		return "Unknown"
+	}
+}
+
+// A PurchaseOrder is an order for equipment from a single supplier. Orders
+// may be edited while they are drafts. Stock is added once an order is
+// received.
+type PurchaseOrder struct {
+	*gorm.Model
+	Tenancy
+
+	SupplierID uint
+	Supplier   Supplier
+
+	Status OrderStatus
+	Notes  string
+
+	CreatorID uint
+	Creator   User
+
+	SentAt     *time.Time
+	ReceivedAt *time.Time
+
+	Lines []OrderLine `gorm:"foreignKey:OrderID"`
+}
+
+// An OrderLine is the quantity of a single item on a purchase order, with the
+// supplier's code and price at the time of ordering.
+type OrderLine struct {
+	*gorm.Model
+	Tenancy
+	OrderID uint
+
+	ItemID uint
+	Item   EquipmentItem
+
+	Code      string
+	Quantity  uint
+	UnitPrice Price
+}
+
+// Total returns the cost of this line.
+func (l OrderLine) Total() Price {
+	return l.UnitPrice * Price(l.Quantity)
+}
+
+// Total returns the cost of the whole order.
+func (o PurchaseOrder) Total() Price {
+	t := Price(0)
+	for _, l := range o.Lines {
+		t += l.Total()
+	}
+
+	return t
+}
+
+// Draft returns true if the order may still be edited.
+func (o PurchaseOrder) Draft() bool {
+	return o.Status == OrderDraft
+}
+
+// GetPurchaseOrder returns the purchase order with the given ID, with its
+// supplier, creator and lines joined.
+func GetPurchaseOrder(db *gorm.DB, id uint) (PurchaseOrder, error) {
+	if id == 0 {
+		return PurchaseOrder{}, fmt.Errorf("get purchase order %d: %w", id, ErrInvalidOrderID)
+	}
+
+	o := PurchaseOrder{}
+	res := db.Joins("Supplier").Joins("Creator").
+		Preload("Lines").Preload("Lines.Item").
+		Where("purchase_orders.id = ?", id).
+		First(&o)
+
+	if err := res.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return o, fmt.Errorf("get purchase order %d: %w", id, ErrNoSuchOrder)
+		}
+
+		return o, fmt.Errorf("get purchase order %d: sql error: %w", id, err)
+	}
+
+	return o, nil
+}
+
+// GetPurchaseOrders returns every purchase order, most recent first.
+func GetPurchaseOrders(db *gorm.DB) ([]PurchaseOrder, error) {
+	o := make([]PurchaseOrder, 0, 20)
+	res := db.Joins("Supplier").Joins("Creator").Preload("Lines").
+		Order("purchase_orders.created_at DESC").
+		Find(&o)
+
+	if err := res.Error; err != nil {
+		return o, fmt.Errorf("get purchase orders: sql error: %w", err)
+	}
+
+	return o, nil
+}
+
+// NewPurchaseOrder creates a draft order from a supplier on behalf of the
+// given user. Lines may be empty, to be filled in later.
+func NewPurchaseOrder(db *gorm.DB, supplier, user uint, lines []OrderLine) (PurchaseOrder, error) {
+	o := PurchaseOrder{
+		Model:      &gorm.Model{},
+		SupplierID: supplier,
+		Status:     OrderDraft,
+		CreatorID:  user,
+		Lines:      lines,
+	}
+	for i := range o.Lines {
+		o.Lines[i].Model = &gorm.Model{}
+	}
+
+	if err := db.Omit("Supplier", "Creator", "Lines.Item").Create(&o).Error; err != nil {
+		return o, fmt.Errorf("new purchase order: sql error: %w", err)
+	}
+
+	return o, nil
+}
+
+// SaveOrderLines replaces the lines and notes of a draft order. Lines with a
+// zero quantity are dropped.
+func SaveOrderLines(db *gorm.DB, o PurchaseOrder, notes string, lines []OrderLine) error {
+	if !o.Draft() {
+		return fmt.Errorf("save purchase order %d: %w", o.ID, ErrOrderStatus)
+	}
+
+	keep := make([]OrderLine, 0, len(lines))
+	for _, l := range lines {
+		if l.Quantity == 0 {
+			continue
+		}
+
+		keep = append(keep, OrderLine{Model: &gorm.Model{}, OrderID: o.ID, ItemID: l.ItemID, Code: l.Code, Quantity: l.Quantity, UnitPrice: l.UnitPrice})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&PurchaseOrder{}).Where("id = ?", o.ID).Update("notes", notes).Error; err != nil {
+			return fmt.Errorf("save purchase order %d: sql error: %w", o.ID, err)
+		}
+		if err := tx.Where("order_id = ?", o.ID).Delete(&OrderLine{}).Error; err != nil {
+			return fmt.Errorf("save purchase order %d: sql error: %w", o.ID, err)
+		}
+		if len(keep) > 0 {
+			if err := tx.Omit("Item").Create(&keep).Error; err != nil {
+				return fmt.Errorf("save purchase order %d: sql error: %w", o.ID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// SendOrder marks a draft order as sent to the supplier.
+func SendOrder(db *gorm.DB, o PurchaseOrder) error {
+	if !o.Draft() {
+		return fmt.Errorf("send purchase order %d: %w", o.ID, ErrOrderStatus)
+	}
+	if len(o.Lines) == 0 {
+		return fmt.Errorf("send purchase order %d: %w", o.ID, ErrOrderEmpty)
+	}
+
+	now := time.Now()
+	res := db.Model(&PurchaseOrder{}).Where("id = ?", o.ID).
+		Updates(map[string]any{"status": OrderSent, "sent_at": now})
+	if err := res.Error; err != nil {
+		return fmt.Errorf("send purchase order %d: sql error: %w", o.ID, err)
+	}
+
+	return nil
+}
+
+// ReceiveOrder marks a sent order as received on behalf of the given user,
+// adding everything ordered to stock. Either the whole order is received or
+// nothing is.
+func ReceiveOrder(db *gorm.DB, o PurchaseOrder, user uint) error {
+	if o.Status != OrderSent {
+		return fmt.Errorf("receive purchase order %d: %w", o.ID, ErrOrderStatus)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, l := range o.Lines {
+			if err := adjustStock(tx, l.ItemID, int(l.Quantity), user, fmt.Sprint("Received on purchase order #", o.ID)); err != nil {
+				return fmt.Errorf("receive purchase order %d: adjust stock: sql error: %w", o.ID, err)
+			}
+		}
+
+		now := time.Now()
+		res := tx.Model(&PurchaseOrder{}).Where("id = ? AND status = ?", o.ID, OrderSent).
+			Updates(map[string]any{"status": OrderReceived, "received_at": now})
+		if err := res.Error; err != nil {
+			return fmt.Errorf("receive purchase order %d: sql error: %w", o.ID, err)
+		}
+		// Guards against the same order being received twice at once.
+		if res.RowsAffected != 1 {
+			return fmt.Errorf("receive purchase order %d: %w", o.ID, ErrOrderStatus)
+		}
+
+		return nil
+	})
+}
+
+// DeleteOrder deletes a draft order and its lines.
+func DeleteOrder(db *gorm.DB, o PurchaseOrder) error {
+	if !o.Draft() {
+		return fmt.Errorf("delete purchase order %d: %w", o.ID, ErrOrderStatus)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", o.ID).Delete(&OrderLine{}).Error; err != nil {
+			return fmt.Errorf("delete purchase order %d: sql error: %w", o.ID, err)
+		}
+		if err := tx.Where("id = ?", o.ID).Delete(&PurchaseOrder{}).Error; err != nil {
+			return fmt.Errorf("delete purchase order %d: sql error: %w", o.ID, err)
+		}
+
+		return nil
+	})
+}
+
+// LowStockItems returns the available items with at most LowStockLevel in
+// stock, sorted by quantity and then name.
+func LowStockItems(db *gorm.DB) ([]EquipmentItem, error) {
+	e := make([]EquipmentItem, 0, 20)
+	res := db.Where("available = ? AND quantity <= ?", true, LowStockLevel).
+		Order("quantity ASC, name ASC").
+		Find(&e)
+
+	if err := res.Error; err != nil {
+		return e, fmt.Errorf("get low stock items: sql error: %w", err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,241 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Prep room errors.
+var (
+	ErrInvalidPrepRoomID = errors.New("invalid prep room ID")
+	ErrNoSuchPrepRoom    = errors.New("prep room does not exist")
+	ErrPrepRoomName      = errors.New("prep room must have a name")
+	ErrPrepRoomExists    = errors.New("a prep room with this name already exists")
+	ErrSamePrepRoom      = errors.New("stock is already in this prep room")
+)
+
+// A PrepRoom is a site from which technicians prepare bookings, with its own
+// stock of equipment. Items, activities, bookings and users may each belong
+// to a prep room. Those which belong to none are shared between all of them,
+// so that a school with a single prep room need not set any up.
+type PrepRoom struct {
+	*gorm.Model
+	Tenancy
+
+	Name        string
+	Description string
+}
+
+// PrepRoomName returns the name of the given prep room, or a placeholder if
+// it is not set.
+func PrepRoomName(p *PrepRoom) string {
+	if p == nil {
+		return "All Prep Rooms"
+	}
+
+	return p.Name
+}
+
+// ParsePrepRoom parses a prep room ID given in a form or query, which may be
+// blank for none.
+func ParsePrepRoom(s string) (*uint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("parse prep room %q: %w", s, ErrInvalidPrepRoomID)
+	}
+
+	v := uint(id)
+	return &v, nil
+}
+
+// GetPrepRoom returns the prep room with the given ID.
+func GetPrepRoom(db *gorm.DB, id uint) (PrepRoom, error) {
+	if id == 0 {
+		return PrepRoom{}, fmt.Errorf("get prep room %d: %w", id, ErrInvalidPrepRoomID)
+	}
+
+	p := PrepRoom{}
+	if err := db.Where("id = ?", id).First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return p, fmt.Errorf("get prep room %d: %w", id, ErrNoSuchPrepRoom)
+		}
+
+		return p, fmt.Errorf("get prep room %d: sql error: %w", id, err)
+	}
+
+	return p, nil
+}
+
+// GetPrepRooms returns every prep room, sorted by name.
+func GetPrepRooms(db *gorm.DB) ([]PrepRoom, error) {
+	p := make([]PrepRoom, 0, 5)
+	if err := db.Order("name ASC").Find(&p).Error; err != nil {
+		return p, fmt.Errorf("get prep rooms: sql error: %w", err)
+	}
+
+	return p, nil
+}
+
+// CheckPrepRoom returns an error if the given prep room is set but does not
+// exist.
+func CheckPrepRoom(db *gorm.DB, id *uint) error {
+	if id == nil {
+		return nil
+	}
+
+	_, err := GetPrepRoom(db, *id)
+	return err
+}
+
+// SavePrepRoom creates or updates a prep room. Prep room names must be
+// unique, ignoring case.
+func SavePrepRoom(db *gorm.DB, p *PrepRoom) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("save prep room: %w", ErrPrepRoomName)
+	}
+
+	ex := PrepRoom{}
+	err := db.Where("LOWER(name) = ?", strings.ToLower(p.Name)).First(&ex).Error
+	if err == nil && (p.Model == nil || ex.ID != p.ID) {
+		return fmt.Errorf("save prep room %s: %w", p.Name, ErrPrepRoomExists)
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("save prep room: sql error: %w", err)
+	}
+
+	if p.Model == nil {
+		p.Model = &gorm.Model{}
+	}
+	if err := db.Save(p).Error; err != nil {
+		return fmt.Errorf("save prep room %d: sql error: %w", p.ID, err)
+	}
+
+	return nil
+}
+
+// DeletePrepRoom deletes a prep room. Anything which belonged to it is then
+// shared between all prep rooms.
+func DeletePrepRoom(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range []any{&EquipmentItem{}, &Activity{}, &Booking{}, &User{}} {
+			if err := tx.Model(m).Where("prep_room_id = ?", id).Update("prep_room_id", nil).Error; err != nil {
+				return fmt.Errorf("delete prep room %d: sql error: %w", id, err)
+			}
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&PrepRoom{}).Error; err != nil {
+			return fmt.Errorf("delete prep room %d: sql error: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// PrepRoomTechnicians returns the technicians who should hear about work for
+// the given prep room. These are the technicians of that prep room and those
+// who belong to none. If prep is nil, every technician is returned.
+func PrepRoomTechnicians(db *gorm.DB, prep *uint) ([]User, error) {
+	if prep == nil {
+		return GetRoleUsers(db, UserTechnician)
+	}
+
+	var us []User
+	res := db.Where("role = ?", UserTechnician).
+		Where("prep_room_id = ? OR prep_room_id IS NULL", *prep).
+		Find(&us)
+	if err := res.Error; err != nil {
+		return us, fmt.Errorf("find technicians for prep room %d: sql error: %w", *prep, err)
+	}
+
+	return us, nil
+}
+
+// GetPrepRoomEquipment returns the items which may be booked from the given
+// prep room: its own stock and that which is shared. If prep is nil, every
+// item is returned.
+func GetPrepRoomEquipment(db *gorm.DB, prep *uint) ([]EquipmentItem, error) {
+	if prep == nil {
+		return GetEquipment(db)
+	}
+
+	var eq []EquipmentItem
+	res := db.Where("prep_room_id = ? OR prep_room_id IS NULL", *prep).Find(&eq)
+	if res.Error != nil {
+		return nil, fmt.Errorf("get equipment for prep room %d: %w", *prep, res.Error)
+	}
+
+	for i := range eq {
+		eq[i].db = db
+	}
+
+	return eq, nil
+}
+
+// TransferStock moves qty of an item into another prep room on behalf of the
+// given user. Stock is added to the item of the same name in the destination,
+// which is created with the same details if there is none. Both changes are
+// audited, and either both happen or neither does. The item receiving the
+// stock is returned.
+func TransferStock(db *gorm.DB, item EquipmentItem, to uint, qty, user uint) (EquipmentItem, error) {
+	if item.PrepRoomID != nil && *item.PrepRoomID == to {
+		return item, fmt.Errorf("transfer %s: %w", item.Name, ErrSamePrepRoom)
+	}
+	if qty > item.Quantity {
+		return item, fmt.Errorf("transfer %d %s: %w", qty, item.Name, ErrInsufficientStock)
+	}
+
+	dst := EquipmentItem{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		dprep, err := GetPrepRoom(tx, to)
+		if err != nil {
+			return fmt.Errorf("transfer %s: %w", item.Name, err)
+		}
+
+		from := PrepRoomName(nil)
+		if item.PrepRoomID != nil {
+			sprep, err := GetPrepRoom(tx, *item.PrepRoomID)
+			if err != nil {
+				return fmt.Errorf("transfer %s: %w", item.Name, err)
+			}
+			from = sprep.Name
+		}
+
+		err = tx.Where("name = ? AND prep_room_id = ?", item.Name, to).First(&dst).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			dst = item
+			dst.Model = &gorm.Model{}
+			dst.PrepRoomID, dst.PrepRoom = &to, nil
+			dst.Quantity = 0
+			dst.SafetyDataSheet = ""
+
+			if err := tx.Create(&dst).Error; err != nil {
+				return fmt.Errorf("transfer %s: sql error: %w", item.Name, err)
+			}
+			if err := AuditItem(tx, user, AuditCreate, "Transferred from "+from, EquipmentItem{}, dst); err != nil {
+				return fmt.Errorf("transfer %s: %w", item.Name, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("transfer %s: sql error: %w", item.Name, err)
+		}
+
+		if err := adjustStock(tx, item.ID, -int(qty), user, "Transferred to "+dprep.Name); err != nil {
+			return fmt.Errorf("transfer %s: %w", item.Name, err)
+		}
+		if err := adjustStock(tx, dst.ID, int(qty), user, "Transferred from "+from); err != nil {
+			return fmt.Errorf("transfer %s: %w", item.Name, err)
+		}
+
+		return nil
+	})
+
+	return dst, err
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadPrice is returned when a price cannot be parsed.
+var ErrBadPrice = errors.New("invalid price")
+
+// A Price is an amount of money in pence.
+type Price uint
+
+func (p Price) String() string {
+	return fmt.Sprintf("£%d.%02d", p/100, p%100)
+}
+
+// Decimal returns the price in pounds without a currency symbol, as used in
+// forms.
+func (p Price) Decimal() string {
+	return fmt.Sprintf("%d.%02d", p/100, p%100)
+}
+
+// ParsePrice parses a price in pounds, such as "12.50" or "£3". A blank price
+// is zero.
+func ParsePrice(s string) (Price, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "£")
+	if s == "" {
+		return 0, nil
+	}
+
+	pounds, pence, frac := strings.Cut(s, ".")
+	if frac && (len(pence) == 0 || len(pence) > 2) {
+		return 0, fmt.Errorf("parse price %q: %w", s, ErrBadPrice)
+	}
+	if len(pence) == 1 {
+		pence += "0"
+	}
+	if pounds == "" {
+		pounds = "0"
+	}
+
+	lpounds, err := strconv.ParseUint(pounds, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse price %q: %w", s, ErrBadPrice)
+	}
+	lpence := uint64(0)
+	if pence != "" {
+		lpence, err = strconv.ParseUint(pence, 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("parse price %q: %w", s, ErrBadPrice)
+		}
+	}
+
+	return Price(lpounds*100 + lpence), nil
+}
+
+// MarshalJSON encodes the price as a number of pounds.
+func (p Price) MarshalJSON() ([]byte, error) {
+	return []byte(p.Decimal()), nil
+}
+
+// UnmarshalJSON decodes a price given as a number of pounds, either as a
+// number or a string.
+func (p *Price) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		return nil
+	}
+
+	v, err := ParsePrice(s)
+	if err != nil {
+		return err
+	}
+
+	*p = v
+	return nil
+}
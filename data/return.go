@@ -0,0 +1,286 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Equipment return errors.
+var (
+	ErrReturnEarly    = errors.New("booking has not yet started")
+	ErrReturnMismatch = errors.New("returned, damaged and lost quantities do not add up to the quantity booked")
+	ErrReturned       = errors.New("equipment for this booking has been returned")
+	ErrReturnSet      = errors.New("equipment set is not part of this booking")
+)
+
+// A ReturnRecord records what came back from a booking for a single
+// equipment set once the practical is over. Damaged and lost equipment is
+// removed from stock when the return is recorded, so these records are also
+// the audit trail for those stock adjustments.
+//
+// Bookings, along with their activities and equipment sets, are cleaned up
+// once they are over, so the details of the booking needed for the breakage
+// report are copied onto the record.
+type ReturnRecord struct {
+	*gorm.Model
+	Tenancy
+
+	BookingID uint
+	Booking   Booking `json:"-"`
+
+	BookingStart time.Time
+	Title        string
+	Department   string
+	OwnerID      uint
+	Owner        User
+
+	SetID  uint
+	ItemID uint
+	Item   EquipmentItem
+
+	Booked   uint
+	Returned uint
+	Damaged  uint
+	Lost     uint
+	Notes    string
+
+	RecorderID uint
+	Recorder   User
+}
+
+// Missing returns the quantity which was not returned in a usable state.
+func (r ReturnRecord) Missing() uint {
+	return r.Damaged + r.Lost
+}
+
+// GetBookingReturns returns the return records for the given booking. If the
+// return has not been recorded, the slice is empty.
+func GetBookingReturns(db *gorm.DB, bk uint) ([]ReturnRecord, error) {
+	r := make([]ReturnRecord, 0, 5)
+	res := db.Model(&ReturnRecord{}).Joins("Item").Joins("Recorder").
+		Where(&ReturnRecord{BookingID: bk}).
+		Find(&r)
+
+	if err := res.Error; err != nil {
+		return r, fmt.Errorf("get returns for booking %d: sql error: %w", bk, err)
+	}
+
+	return r, nil
+}
+
+// HasReturn returns true if a return has been recorded for the given booking.
+func HasReturn(db *gorm.DB, bk uint) (bool, error) {
+	var n int64
+	if err := db.Model(&ReturnRecord{}).Where(&ReturnRecord{BookingID: bk}).Count(&n).Error; err != nil {
+		return false, fmt.Errorf("check returns for booking %d: sql error: %w", bk, err)
+	}
+
+	return n > 0, nil
+}
+
+// adjustStock changes the stock of an item by by on behalf of the given user,
+// without going below zero. The change is audited with the given reason.
+func adjustStock(db *gorm.DB, item uint, by int, user uint, reason string) error {
+	it := EquipmentItem{}
+	if err := db.Where("id = ?", item).First(&it).Error; err != nil {
+		return err
+	}
+
+	qty := int(it.Quantity) + by
+	if qty < 0 {
+		qty = 0
+	}
+
+	after := it
+	after.Quantity = uint(qty)
+	if err := db.Model(&it).Update("quantity", qty).Error; err != nil {
+		return err
+	}
+
+	return AuditItem(db, user, AuditUpdate, reason, it, after)
+}
+
+// fillReturns checks that the records account for the equipment of each set
+// they return from bk, and copies onto them the details of the booking and
+// the recording user.
+func fillReturns(bk Booking, recs []ReturnRecord, user uint) error {
+	sets := make(map[uint]EquipmentSet, len(bk.Activity.Equipment))
+	for _, s := range bk.Activity.Equipment {
+		sets[s.ID] = s
+	}
+
+	for i, r := range recs {
+		s, ok := sets[r.SetID]
+		if !ok {
+			return fmt.Errorf("return booking %d: set %d: %w", bk.ID, r.SetID, ErrReturnSet)
+		}
+		if r.Returned+r.Damaged+r.Lost != s.Quantity {
+			return fmt.Errorf("return %s: %w", s.Item.Name, ErrReturnMismatch)
+		}
+
+		recs[i].Model = &gorm.Model{}
+		recs[i].BookingID = bk.ID
+		recs[i].BookingStart = bk.StartTime
+		recs[i].Title = bk.Activity.Title
+		recs[i].Department = bk.Department()
+		recs[i].OwnerID = bk.OwnerID
+		recs[i].ItemID = s.ItemID
+		recs[i].Booked = s.Quantity
+		recs[i].RecorderID = user
+	}
+
+	return nil
+}
+
+// RecordReturn records the equipment returned from a booking on behalf of the
+// given user, with one record per equipment set. Damaged and lost equipment
+// is taken out of stock. Any return already recorded for the booking is
+// reversed and replaced, so that mistakes may be corrected. Either all of the
+// records are saved or none are.
+func RecordReturn(db *gorm.DB, bk Booking, recs []ReturnRecord, user uint) error {
+	if bk.StartTime.After(time.Now()) {
+		return fmt.Errorf("return booking %d: %w", bk.ID, ErrReturnEarly)
+	}
+	if err := fillReturns(bk, recs, user); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		old, err := GetBookingReturns(tx, bk.ID)
+		if err != nil {
+			return fmt.Errorf("return booking %d: %w", bk.ID, err)
+		}
+
+		for _, r := range old {
+			if err := adjustStock(tx, r.ItemID, int(r.Missing()), user, fmt.Sprint("Return for booking #", bk.ID, " corrected")); err != nil {
+				return fmt.Errorf("return booking %d: reverse return: sql error: %w", bk.ID, err)
+			}
+			if err := tx.Delete(&r).Error; err != nil {
+				return fmt.Errorf("return booking %d: reverse return: sql error: %w", bk.ID, err)
+			}
+		}
+
+		for _, r := range recs {
+			if err := adjustStock(tx, r.ItemID, -int(r.Missing()), user, fmt.Sprint("Damaged or lost in booking #", bk.ID)); err != nil {
+				return fmt.Errorf("return booking %d: adjust stock: sql error: %w", bk.ID, err)
+			}
+		}
+
+		if len(recs) > 0 {
+			if err := tx.Omit("Item", "Recorder", "Booking", "Owner").Create(&recs).Error; err != nil {
+				return fmt.Errorf("return booking %d: sql error: %w", bk.ID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// A BreakageTotal is the total equipment damaged and lost for one group in a
+// breakage report.
+type BreakageTotal struct {
+	Name    string
+	Damaged uint
+	Lost    uint
+}
+
+// Missing returns the total quantity damaged or lost.
+func (b BreakageTotal) Missing() uint {
+	return b.Damaged + b.Lost
+}
+
+// A BreakageReport summarises the equipment damaged and lost over a period.
+// Departments are those charged for each booking, as in the cost report.
+type BreakageReport struct {
+	Records     []ReturnRecord
+	Departments []BreakageTotal
+	Teachers    []BreakageTotal
+	Items       []BreakageTotal
+}
+
+// totals sums records into groups by the key returned from by, largest first.
+func totals(recs []ReturnRecord, by func(ReturnRecord) string) []BreakageTotal {
+	idx := make(map[string]int)
+	t := make([]BreakageTotal, 0)
+	for _, r := range recs {
+		k := by(r)
+		i, ok := idx[k]
+		if !ok {
+			i = len(t)
+			idx[k] = i
+			t = append(t, BreakageTotal{Name: k})
+		}
+
+		t[i].Damaged += r.Damaged
+		t[i].Lost += r.Lost
+	}
+
+	sort.SliceStable(t, func(i, j int) bool {
+		return t[i].Missing() > t[j].Missing()
+	})
+
+	return t
+}
+
+// newBreakageReport sums the given records of damaged and lost equipment
+// into a breakage report.
+func newBreakageReport(recs []ReturnRecord) BreakageReport {
+	rep := BreakageReport{Records: recs}
+	rep.Departments = totals(rep.Records, func(r ReturnRecord) string {
+		if r.Department == "" {
+			return NoDepartment
+		}
+		return r.Department
+	})
+	rep.Teachers = totals(rep.Records, func(r ReturnRecord) string {
+		return r.Owner.DisplayName()
+	})
+	rep.Items = totals(rep.Records, func(r ReturnRecord) string {
+		return r.Item.Name
+	})
+
+	return rep
+}
+
+// GetBreakageReport returns a report of all equipment damaged or lost from
+// bookings starting within the given term. The report is built from the
+// return records alone, so still covers bookings which have been cleaned up.
+func GetBreakageReport(db *gorm.DB, term Term) (BreakageReport, error) {
+	recs := make([]ReturnRecord, 0, 10)
+	res := db.Model(&ReturnRecord{}).Joins("Item").Joins("Owner").
+		Where("return_records.booking_start >= ? AND return_records.booking_start < ?", term.Start.UTC(), term.End.UTC()).
+		Where("damaged > 0 OR lost > 0").
+		Order("return_records.created_at DESC").
+		Find(&recs)
+
+	if err := res.Error; err != nil {
+		return BreakageReport{}, fmt.Errorf("get breakage report for %s: sql error: %w", term, err)
+	}
+
+	return newBreakageReport(recs), nil
+}
+
+// MigrateReturns copies the details of their bookings onto return records
+// made before they were kept on the record itself. Bookings must already have
+// been charged, so that the department charged is known. Records whose booking
+// has already been removed are left as they are. This is a no-op once every
+// record has been migrated.
+func MigrateReturns(db *gorm.DB) error {
+	res := db.Exec(`UPDATE return_records
+		JOIN bookings ON bookings.id = return_records.booking_id
+		JOIN activities ON activities.id = bookings.activity_id
+		SET return_records.booking_start = bookings.start_time,
+			return_records.title = activities.title,
+			return_records.department = bookings.charged_to,
+			return_records.owner_id = bookings.owner_id
+		WHERE return_records.owner_id IS NULL OR return_records.owner_id = 0`)
+	if err := res.Error; err != nil {
+		return fmt.Errorf("migrate returns: sql error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestFillReturns(t *testing.T) {
+	start := day(2026, 10, 19).Add(9 * time.Hour)
+	bk := Booking{
+		Model:     &gorm.Model{ID: 7},
+		StartTime: start,
+		OwnerID:   3,
+		Owner:     User{Department: "Science"},
+		Activity: Activity{Title: "Titration", Category: "misc", Equipment: []EquipmentSet{
+			{Model: &gorm.Model{ID: 1}, ItemID: 10, Quantity: 5},
+			{Model: &gorm.Model{ID: 2}, ItemID: 11, Quantity: 2},
+		}},
+	}
+
+	testdata := []struct {
+		Name    string
+		Booking func(bk *Booking)
+		Records []ReturnRecord
+		Err     error
+		Expect  string
+	}{
+		{"Owner's department", nil, []ReturnRecord{{SetID: 1, Returned: 3, Damaged: 1, Lost: 1}}, nil, "Science"},
+		{"Activity's department", func(bk *Booking) {
+			bk.Activity.Department = "Chemistry"
+		}, []ReturnRecord{{SetID: 2, Returned: 2}}, nil, "Chemistry"},
+		{"Department charged", func(bk *Booking) {
+			bk.Activity.Department = "Chemistry"
+			bk.ChargedTo = "Physics"
+		}, []ReturnRecord{{SetID: 1, Returned: 5}}, nil, "Physics"},
+		{"No department", func(bk *Booking) {
+			bk.Owner.Department = ""
+		}, []ReturnRecord{{SetID: 1, Returned: 5}}, nil, NoDepartment},
+		{"Too few", nil, []ReturnRecord{{SetID: 1, Returned: 3, Damaged: 1}}, ErrReturnMismatch, ""},
+		{"Too many", nil, []ReturnRecord{{SetID: 2, Returned: 2, Lost: 1}}, ErrReturnMismatch, ""},
+		{"Set not booked", nil, []ReturnRecord{{SetID: 3, Returned: 1}}, ErrReturnSet, ""},
+	}
+
+	for _, d := range testdata {
+		b := bk
+		if d.Booking != nil {
+			d.Booking(&b)
+		}
+
+		err := fillReturns(b, d.Records, 9)
+		if !errors.Is(err, d.Err) {
+			t.Errorf("%s: expected error %v, got %v", d.Name, d.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		for _, r := range d.Records {
+			set := b.Activity.Equipment[r.SetID-1]
+			if r.BookingID != 7 || !r.BookingStart.Equal(start) || r.Title != "Titration" || r.OwnerID != 3 || r.RecorderID != 9 {
+				t.Errorf("%s: booking details not copied: %+v", d.Name, r)
+			}
+			if r.ItemID != set.ItemID || r.Booked != set.Quantity {
+				t.Errorf("%s: expected %d of item %d booked, got %d of item %d", d.Name, set.Quantity, set.ItemID, r.Booked, r.ItemID)
+			}
+			if r.Department != d.Expect {
+				t.Errorf("%s: expected department %q, got %q", d.Name, d.Expect, r.Department)
+			}
+		}
+	}
+}
+
+func TestNewBreakageReport(t *testing.T) {
+	jane := User{FirstName: "Jane"}
+	john := User{FirstName: "John"}
+	beaker := EquipmentItem{Name: "Beaker"}
+	flask := EquipmentItem{Name: "Flask"}
+
+	rep := newBreakageReport([]ReturnRecord{
+		{Department: "Chemistry", Owner: jane, Item: beaker, Damaged: 1},
+		{Department: "Physics", Owner: john, Item: flask, Damaged: 2, Lost: 2},
+		{Department: "Chemistry", Owner: john, Item: beaker, Lost: 2},
+		{Owner: jane, Item: flask, Damaged: 1},
+	})
+
+	testdata := []struct {
+		Name   string
+		Got    []BreakageTotal
+		Expect []BreakageTotal
+	}{
+		{"Departments", rep.Departments, []BreakageTotal{
+			{Name: "Physics", Damaged: 2, Lost: 2},
+			{Name: "Chemistry", Damaged: 1, Lost: 2},
+			{Name: NoDepartment, Damaged: 1},
+		}},
+		{"Teachers", rep.Teachers, []BreakageTotal{
+			{Name: "John", Damaged: 2, Lost: 4},
+			{Name: "Jane", Damaged: 2},
+		}},
+		{"Items", rep.Items, []BreakageTotal{
+			{Name: "Flask", Damaged: 3, Lost: 2},
+			{Name: "Beaker", Damaged: 1, Lost: 2},
+		}},
+	}
+
+	for _, d := range testdata {
+		if !reflect.DeepEqual(d.Got, d.Expect) {
+			t.Errorf("%s: expected %v, got %v", d.Name, d.Expect, d.Got)
+		}
+	}
+	if len(rep.Records) != 4 {
+		t.Errorf("expected 4 records, got %d", len(rep.Records))
+	}
+}
+
+func TestBreakageReportAfterClean(t *testing.T) {
+	db := testDB(t)
+
+	owner := testOwner(t, db, "Science")
+	item := testItem(t, db, "Beaker", 20, 0)
+
+	start := time.Now().Add(-2 * time.Hour)
+	bk := testBooking(t, db, owner, item, 5, "Titration", "Chemistry", start, start.Add(time.Hour))
+	rec := []ReturnRecord{{SetID: bk.Activity.Equipment[0].ID, Returned: 3, Damaged: 1, Lost: 1}}
+	if err := RecordReturn(db, bk, rec, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := CleanBookings(db); err != nil || n != 1 {
+		t.Fatalf("clean bookings: cleaned %d, error %v", n, err)
+	}
+	if _, err := GetBooking(db, bk.ID); !errors.Is(err, ErrNoSuchBooking) {
+		t.Fatalf("booking not cleaned: got error %v", err)
+	}
+
+	term := Term{Name: "Test", Start: start.Add(-24 * time.Hour), End: start.Add(24 * time.Hour)}
+	rep, err := GetBreakageReport(db, term)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(rep.Records))
+	}
+	if r := rep.Records[0]; r.Title != "Titration" || r.Item.Name != "Beaker" || r.Booked != 5 {
+		t.Errorf("bad record: %q of %q, %d booked", r.Title, r.Item.Name, r.Booked)
+	}
+
+	want := BreakageTotal{Name: "Science", Damaged: 1, Lost: 1}
+	if len(rep.Departments) != 1 || rep.Departments[0] != want {
+		t.Errorf("departments: expected [%v], got %v", want, rep.Departments)
+	}
+	want.Name = "Jane"
+	if len(rep.Teachers) != 1 || rep.Teachers[0] != want {
+		t.Errorf("teachers: expected [%v], got %v", want, rep.Teachers)
+	}
+
+	// Terms not containing the booking report nothing.
+	before := Term{Name: "Before", Start: term.Start.Add(-24 * time.Hour), End: term.Start}
+	rep, err = GetBreakageReport(db, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Records) != 0 {
+		t.Errorf("expected no records in previous term, got %d", len(rep.Records))
+	}
+}
@@ -0,0 +1,243 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Room errors.
+var (
+	ErrInvalidRoomID = errors.New("invalid room ID")
+	ErrNoSuchRoom    = errors.New("room does not exist")
+	ErrRoomName      = errors.New("room must have a name")
+	ErrRoomExists    = errors.New("a room with this name already exists")
+	ErrUnknownRoom   = errors.New("location is not a known room")
+)
+
+// A Room is a teaching room or lab in which bookings take place. Bookings
+// refer to rooms by name, so that bookings made before a room was added or
+// after it was removed remain readable.
+type Room struct {
+	*gorm.Model
+	Tenancy
+
+	Name        string
+	Description string
+	Capacity    uint
+
+	// Facilities available in the room.
+	FumeCupboard bool
+	Gas          bool
+	Sinks        bool
+
+	// IsamsID is the iSAMS classroom ID for rooms seeded from iSAMS.
+	IsamsID *uint64
+}
+
+// Facilities returns the names of the facilities available in the room.
+func (r Room) Facilities() []string {
+	f := make([]string, 0, 3)
+	if r.FumeCupboard {
+		f = append(f, "Fume Cupboard")
+	}
+	if r.Gas {
+		f = append(f, "Gas")
+	}
+	if r.Sinks {
+		f = append(f, "Sinks")
+	}
+
+	return f
+}
+
+// GetRoom returns the room with the given ID.
+func GetRoom(db *gorm.DB, id uint) (Room, error) {
+	if id == 0 {
+		return Room{}, fmt.Errorf("get room %d: %w", id, ErrInvalidRoomID)
+	}
+
+	r := Room{}
+	if err := db.Where("id = ?", id).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return r, fmt.Errorf("get room %d: %w", id, ErrNoSuchRoom)
+		}
+
+		return r, fmt.Errorf("get room %d: sql error: %w", id, err)
+	}
+
+	return r, nil
+}
+
+// GetRooms returns every room, sorted by name.
+func GetRooms(db *gorm.DB) ([]Room, error) {
+	r := make([]Room, 0, 20)
+	if err := db.Order("name ASC").Find(&r).Error; err != nil {
+		return r, fmt.Errorf("get rooms: sql error: %w", err)
+	}
+
+	return r, nil
+}
+
+// FindRoom returns the room with the given name, ignoring case.
+func FindRoom(db *gorm.DB, name string) (Room, error) {
+	r := Room{}
+	res := db.Where("LOWER(name) = ?", strings.ToLower(strings.TrimSpace(name))).First(&r)
+	if err := res.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return r, fmt.Errorf("find room %q: %w", name, ErrNoSuchRoom)
+		}
+
+		return r, fmt.Errorf("find room %q: sql error: %w", name, err)
+	}
+
+	return r, nil
+}
+
+// ResolveLocation validates a booking location against the known rooms,
+// returning the name of the room exactly as recorded. Blank locations, and
+// any location while no rooms have been set up, are allowed as given.
+func ResolveLocation(db *gorm.DB, location string) (string, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return location, nil
+	}
+
+	var n int64
+	if err := db.Model(&Room{}).Count(&n).Error; err != nil {
+		return location, fmt.Errorf("resolve location %q: sql error: %w", location, err)
+	}
+	if n == 0 {
+		return location, nil
+	}
+
+	r, err := FindRoom(db, location)
+	if err != nil {
+		if errors.Is(err, ErrNoSuchRoom) {
+			return location, fmt.Errorf("resolve location %q: %w", location, ErrUnknownRoom)
+		}
+
+		return location, fmt.Errorf("resolve location: %w", err)
+	}
+
+	return r.Name, nil
+}
+
+// SaveRoom creates or updates a room. Room names must be unique, ignoring
+// case.
+func SaveRoom(db *gorm.DB, r *Room) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("save room: %w", ErrRoomName)
+	}
+
+	ex, err := FindRoom(db, r.Name)
+	if err == nil && (r.Model == nil || ex.ID != r.ID) {
+		return fmt.Errorf("save room %s: %w", r.Name, ErrRoomExists)
+	} else if err != nil && !errors.Is(err, ErrNoSuchRoom) {
+		return fmt.Errorf("save room: %w", err)
+	}
+
+	if r.Model == nil {
+		r.Model = &gorm.Model{}
+	}
+	if err := db.Save(r).Error; err != nil {
+		return fmt.Errorf("save room %d: sql error: %w", r.ID, err)
+	}
+
+	return nil
+}
+
+// DeleteRoom deletes a room. Bookings in the room are unaffected.
+func DeleteRoom(db *gorm.DB, id uint) error {
+	if err := db.Where("id = ?", id).Delete(&Room{}).Error; err != nil {
+		return fmt.Errorf("delete room %d: sql error: %w", id, err)
+	}
+
+	return nil
+}
+
+// SeedRooms adds each of the given rooms which does not already exist, as
+// identified by iSAMS ID or name. Existing rooms are left alone, so that
+// local changes to capacities and facilities are kept. The number of rooms
+// added is returned.
+func SeedRooms(db *gorm.DB, rooms []Room) (int, error) {
+	existing, err := GetRooms(db)
+	if err != nil {
+		return 0, fmt.Errorf("seed rooms: %w", err)
+	}
+
+	names := make(map[string]bool, len(existing))
+	ids := make(map[uint64]bool, len(existing))
+	for _, r := range existing {
+		names[strings.ToLower(r.Name)] = true
+		if r.IsamsID != nil {
+			ids[*r.IsamsID] = true
+		}
+	}
+
+	n := 0
+	for _, r := range rooms {
+		r.Name = strings.TrimSpace(r.Name)
+		if r.Name == "" || names[strings.ToLower(r.Name)] || (r.IsamsID != nil && ids[*r.IsamsID]) {
+			continue
+		}
+
+		r.Model = &gorm.Model{}
+		if err := db.Create(&r).Error; err != nil {
+			return n, fmt.Errorf("seed rooms: sql error: %w", err)
+		}
+
+		names[strings.ToLower(r.Name)] = true
+		if r.IsamsID != nil {
+			ids[*r.IsamsID] = true
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// RoomBookings returns the bookings which were not rejected in the named room
+// overlapping the given period, in order of start time, with activities and
+// owners joined.
+func RoomBookings(db *gorm.DB, room string, start, end time.Time) ([]Booking, error) {
+	b := make([]Booking, 0, 10)
+	res := db.Model(&Booking{}).Joins("Activity").Joins("Owner").
+		Where("LOWER(bookings.location) = ?", strings.ToLower(strings.TrimSpace(room))).
+		Where("bookings.start_time < ? AND bookings.end_time > ?", end.UTC(), start.UTC()).
+		Where("bookings.status NOT IN ?", uncommittedStatuses).
+		Order("bookings.start_time ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return b, fmt.Errorf("get bookings for room %q: sql error: %w", room, err)
+	}
+
+	return b, nil
+}
+
+// RoomClashes returns the other bookings in the same room as bk which overlap
+// it. Bookings with no location never clash.
+func RoomClashes(db *gorm.DB, bk Booking) ([]Booking, error) {
+	if strings.TrimSpace(bk.Location) == "" {
+		return nil, nil
+	}
+
+	bks, err := RoomBookings(db, bk.Location, bk.StartTime, bk.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	clashes := make([]Booking, 0, len(bks))
+	for _, b := range bks {
+		if bk.Model == nil || b.ID != bk.ID {
+			clashes = append(clashes, b)
+		}
+	}
+
+	return clashes, nil
+}
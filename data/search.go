@@ -0,0 +1,241 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// LowStockLevel is the quantity at or below which an item is counted as low
+// on stock by inventory searches.
+const LowStockLevel = 5
+
+// DefaultPageSize is the number of items returned per page of search results
+// if no page size is given.
+const DefaultPageSize = 50
+
+// maxTagFacets is the number of the most common tags returned as facets.
+const maxTagFacets = 20
+
+// Stock level filters.
+const (
+	StockAny = ""
+	StockOut = "out"
+	StockLow = "low"
+	StockIn  = "in"
+)
+
+// Hazard filters, along with any pictogram code.
+const (
+	HazardAny  = ""
+	HazardSome = "any"
+	HazardNone = "none"
+)
+
+// Availability filters.
+const (
+	AvailableAny = ""
+	AvailableYes = "yes"
+	AvailableNo  = "no"
+)
+
+// pictogramColumns maps each GHS pictogram code to its item column.
+var pictogramColumns = map[string]string{
+	PictogramExplosive.Code:   "ghs_explosive",
+	PictogramFlammable.Code:   "ghs_flammable",
+	PictogramOxidising.Code:   "ghs_oxidising",
+	PictogramGas.Code:         "ghs_gas",
+	PictogramCorrosive.Code:   "ghs_corrosive",
+	PictogramToxic.Code:       "ghs_toxic",
+	PictogramHarmful.Code:     "ghs_harmful",
+	PictogramHealth.Code:      "ghs_health",
+	PictogramEnvironment.Code: "ghs_environment",
+}
+
+// Pictograms are the nine GHS pictograms, in order of their codes.
+var Pictograms = []Pictogram{
+	PictogramExplosive, PictogramFlammable, PictogramOxidising,
+	PictogramGas, PictogramCorrosive, PictogramToxic,
+	PictogramHarmful, PictogramHealth, PictogramEnvironment,
+}
+
+// An ItemQuery is a search of the inventory. Blank fields are not filtered
+// on. Pages are numbered from one.
+type ItemQuery struct {
+	Text      string       `form:"q"`
+	Category  ItemCategory `form:"category"`
+	Tag       string       `form:"tag"`
+	Hazard    string       `form:"hazard"`
+	Available string       `form:"available"`
+	Stock     string       `form:"stock"`
+	// If set, only items held by this prep room or shared are matched.
+	PrepRoom *uint `form:"-"`
+
+	Page     int `form:"page"`
+	PageSize int `form:"-"`
+}
+
+// A Facet is the number of matching items with a given value of a field.
+type Facet struct {
+	Value string
+	Count int64
+}
+
+// Category returns the value of a category facet as a category.
+func (f Facet) Category() ItemCategory {
+	return ItemCategory(f.Value)
+}
+
+// ItemResults is a single page of inventory search results, along with the
+// facets of all matching items.
+type ItemResults struct {
+	Items []EquipmentItem
+	Total int64
+	Page  int
+	Pages int
+
+	// Categories counts the matches in each category, ignoring any category
+	// filter. Tags similarly counts the most common tags.
+	Categories []Facet
+	Tags       []Facet
+}
+
+// scope returns db filtered by all of the query except the named facet, which
+// may be blank.
+func (q ItemQuery) scope(db *gorm.DB, except string) *gorm.DB {
+	db = db.Model(&EquipmentItem{})
+
+	if t := strings.TrimSpace(q.Text); t != "" {
+		like := "%" + t + "%"
+		if id, err := ParseLabelCode(t); err == nil {
+			db = db.Where("id = ? OR name LIKE ? OR description LIKE ? OR tags LIKE ?", id, like, like, like)
+		} else {
+			db = db.Where("name LIKE ? OR description LIKE ? OR tags LIKE ?", like, like, like)
+		}
+	}
+
+	if except != "category" && q.Category != "" {
+		db = db.Where("category = ?", string(q.Category))
+	}
+
+	if t := SplitTags(q.Tag); except != "tag" && len(t) > 0 {
+		db = db.Where("CONCAT(', ', tags, ', ') LIKE ?", "%, "+t[0]+", %")
+	}
+
+	flags := make([]string, 0, len(pictogramColumns))
+	for _, col := range pictogramColumns {
+		flags = append(flags, col)
+	}
+	sort.Strings(flags)
+
+	switch q.Hazard {
+	case HazardAny:
+	case HazardSome:
+		db = db.Where(strings.Join(flags, " OR ") + " OR signal_word <> ''")
+	case HazardNone:
+		db = db.Where("NOT ("+strings.Join(flags, " OR ")+") AND signal_word = ?", SignalNone)
+	default:
+		if col, ok := pictogramColumns[q.Hazard]; ok {
+			db = db.Where(col+" = ?", true)
+		}
+	}
+
+	switch q.Available {
+	case AvailableYes:
+		db = db.Where("available = ?", true)
+	case AvailableNo:
+		db = db.Where("available = ?", false)
+	}
+
+	if q.PrepRoom != nil {
+		db = db.Where("prep_room_id = ? OR prep_room_id IS NULL", *q.PrepRoom)
+	}
+
+	switch q.Stock {
+	case StockOut:
+		db = db.Where("quantity = 0 OR available = ?", false)
+	case StockLow:
+		db = db.Where("quantity > 0 AND quantity <= ? AND available = ?", LowStockLevel, true)
+	case StockIn:
+		db = db.Where("quantity > ? AND available = ?", LowStockLevel, true)
+	}
+
+	return db
+}
+
+// SearchEquipment returns the page of items matching the query, sorted by
+// name, along with the facets of every match.
+func SearchEquipment(db *gorm.DB, q ItemQuery) (ItemResults, error) {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+
+	r := ItemResults{}
+	if err := q.scope(db, "").Count(&r.Total).Error; err != nil {
+		return r, fmt.Errorf("search equipment: sql error: %w", err)
+	}
+
+	r.Pages = int((r.Total + int64(q.PageSize) - 1) / int64(q.PageSize))
+	r.Page = max(1, min(q.Page, r.Pages))
+
+	res := q.scope(db, "").
+		Preload("PrepRoom").
+		Order("name ASC").
+		Offset((r.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&r.Items)
+	if err := res.Error; err != nil {
+		return r, fmt.Errorf("search equipment: sql error: %w", err)
+	}
+
+	for i := range r.Items {
+		r.Items[i].db = db
+	}
+
+	res = q.scope(db, "category").
+		Select("category AS value, COUNT(*) AS count").
+		Group("category").
+		Order("category ASC").
+		Scan(&r.Categories)
+	if err := res.Error; err != nil {
+		return r, fmt.Errorf("search equipment: category facets: sql error: %w", err)
+	}
+
+	var tags []string
+	if err := q.scope(db, "tag").Where("tags <> ''").Pluck("tags", &tags).Error; err != nil {
+		return r, fmt.Errorf("search equipment: tag facets: sql error: %w", err)
+	}
+	r.Tags = tagFacets(tags)
+
+	return r, nil
+}
+
+// tagFacets counts the occurrences of each tag in the tag lists given,
+// returning the most common first.
+func tagFacets(lists []string) []Facet {
+	counts := make(map[string]int64)
+	for _, l := range lists {
+		for _, t := range SplitTags(l) {
+			counts[t]++
+		}
+	}
+
+	f := make([]Facet, 0, len(counts))
+	for t, n := range counts {
+		f = append(f, Facet{t, n})
+	}
+	sort.Slice(f, func(i, j int) bool {
+		if f[i].Count != f[j].Count {
+			return f[i].Count > f[j].Count
+		}
+		return f[i].Value < f[j].Value
+	})
+
+	if len(f) > maxTagFacets {
+		f = f[:maxTagFacets]
+	}
+
+	return f
+}
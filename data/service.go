@@ -0,0 +1,269 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Service record kinds.
+const (
+	ServicePAT = iota
+	ServiceCalibration
+	ServiceInspection
+	ServiceRepair
+)
+
+// Service record results.
+const (
+	ServicePass = iota
+	ServiceFail
+)
+
+// Service record errors.
+var (
+	ErrInvalidServiceID = errors.New("invalid service record ID")
+	ErrNoSuchService    = errors.New("service record does not exist")
+)
+
+// ServiceKind is the enumerator type for the kind of test or service carried
+// out on an item.
+type ServiceKind uint8
+
+func (s ServiceKind) String() string {
+	switch s {
+	case ServicePAT:
+		return "PAT Test"
+	case ServiceCalibration:
+		return "Calibration"
+	case ServiceInspection:
+		return "Inspection"
+	case ServiceRepair:
+		return "Repair"
+	default:
+		return "Unknown"
+	}
+}
+
+// Valid returns true if this is one of the defined kinds of service.
+func (s ServiceKind) Valid() bool {
+	switch s {
+	case ServicePAT, ServiceCalibration, ServiceInspection, ServiceRepair:
+		return true
+	default:
+		return false
+	}
+}
+
+// ServiceResult is the enumerator type for the outcome of a service.
+type ServiceResult uint8
+
+func (s ServiceResult) String() string {
+	switch s {
+	case ServicePass:
+		return "Pass"
+	case ServiceFail:
+		return "Fail"
+	default:
+		return "Unknown"
+	}
+}
+
+// Valid returns true if this is one of the defined service results.
+func (s ServiceResult) Valid() bool {
+	switch s {
+	case ServicePass, ServiceFail:
+		return true
+	default:
+		return false
+	}
+}
+
+// A ServiceRecord is a record of a test or service carried out on an item,
+// such as a yearly PAT test of a power pack.
+type ServiceRecord struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint
+	Item   EquipmentItem `json:"-"`
+
+	// Serialised unit which was serviced, if the item is tracked by unit.
+	UnitID *uint
+	Unit   *EquipmentUnit
+
+	Kind   ServiceKind
+	Result ServiceResult
+	Notes  string
+
+	TesterID uint
+	Tester   User
+
+	Tested  time.Time
+	NextDue time.Time
+}
+
+// Passed returns true if the service was passed.
+func (s ServiceRecord) Passed() bool {
+	return s.Result == ServicePass
+}
+
+// ServiceStatus is the test status of a single item which must be serviced,
+// or of one unit of it if the item is tracked by unit. Latest is nil if the
+// item or unit has never been serviced.
+type ServiceStatus struct {
+	Item   EquipmentItem
+	Unit   *EquipmentUnit
+	Latest *ServiceRecord
+}
+
+// Name returns the name of the item, along with the serial number of the unit
+// if this is the status of a single unit.
+func (s ServiceStatus) Name() string {
+	if s.Unit != nil {
+		return fmt.Sprint(s.Item.Name, " (", s.Unit.Serial, ")")
+	}
+
+	return s.Item.Name
+}
+
+// InTest returns true if the item has been serviced, passed its last service
+// and is not yet due another at the given time.
+func (s ServiceStatus) InTest(at time.Time) bool {
+	return s.Latest != nil && s.Latest.Passed() && at.Before(s.Latest.NextDue)
+}
+
+// Reason returns a short, human readable reason for which the item is out of
+// test at the given time, or an empty string if it is not.
+func (s ServiceStatus) Reason(at time.Time) string {
+	switch {
+	case s.Latest == nil:
+		return "never tested"
+	case !s.Latest.Passed():
+		return fmt.Sprint("failed ", s.Latest.Kind, " on ", s.Latest.Tested.Local().Format("02/01/06"))
+	case !at.Before(s.Latest.NextDue):
+		return fmt.Sprint(s.Latest.Kind, " due ", s.Latest.NextDue.Local().Format("02/01/06"))
+	default:
+		return ""
+	}
+}
+
+// GetServiceRecord looks up a single service record by ID.
+func GetServiceRecord(db *gorm.DB, id uint) (ServiceRecord, error) {
+	if id == 0 {
+		return ServiceRecord{}, fmt.Errorf("get service record %d: %w", id, ErrInvalidServiceID)
+	}
+
+	s := ServiceRecord{Model: &gorm.Model{ID: id}}
+	if err := db.Where(&s).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s, fmt.Errorf("get service record %d: %w", id, ErrNoSuchService)
+		}
+
+		return s, fmt.Errorf("get service record %d: sql error: %w", id, err)
+	}
+
+	return s, nil
+}
+
+// GetServiceRecords returns the service history of the given item, most
+// recent first.
+func GetServiceRecords(db *gorm.DB, item uint) ([]ServiceRecord, error) {
+	s := make([]ServiceRecord, 0, 5)
+	res := db.Model(&ServiceRecord{}).Joins("Tester").Preload("Unit").
+		Where(&ServiceRecord{ItemID: item}).
+		Order("tested DESC").
+		Find(&s)
+
+	if err := res.Error; err != nil {
+		return s, fmt.Errorf("get service records for item %d: sql error: %w", item, err)
+	}
+
+	return s, nil
+}
+
+// GetServiceStatus returns the test status of every item which must be
+// serviced. Items tracked by unit have the status of each unit returned
+// separately. If items is not empty, only those items are included.
+func GetServiceStatus(db *gorm.DB, items ...uint) ([]ServiceStatus, error) {
+	var eq []EquipmentItem
+	q := db.Where("service_interval > 0")
+	if len(items) > 0 {
+		q = q.Where("id IN ?", items)
+	}
+	if err := q.Find(&eq).Error; err != nil {
+		return nil, fmt.Errorf("get service status: sql error: %w", err)
+	}
+
+	if len(eq) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(eq))
+	for i, e := range eq {
+		ids[i] = e.ID
+	}
+
+	var recs []ServiceRecord
+	if err := db.Where("item_id IN ?", ids).Order("tested DESC").Find(&recs).Error; err != nil {
+		return nil, fmt.Errorf("get service status: sql error: %w", err)
+	}
+
+	// Retired units need no further servicing.
+	var units []EquipmentUnit
+	if err := db.Where("item_id IN ? AND `condition` <> ?", ids, ConditionRetired).Order("serial ASC").Find(&units).Error; err != nil {
+		return nil, fmt.Errorf("get service status: sql error: %w", err)
+	}
+
+	latest := make(map[uint]*ServiceRecord, len(recs))
+	latestUnit := make(map[uint]*ServiceRecord, len(recs))
+	for i := range recs {
+		if _, ok := latest[recs[i].ItemID]; !ok {
+			latest[recs[i].ItemID] = &recs[i]
+		}
+		if u := recs[i].UnitID; u != nil {
+			if _, ok := latestUnit[*u]; !ok {
+				latestUnit[*u] = &recs[i]
+			}
+		}
+	}
+
+	byItem := make(map[uint][]EquipmentUnit, len(eq))
+	for _, u := range units {
+		byItem[u.ItemID] = append(byItem[u.ItemID], u)
+	}
+
+	st := make([]ServiceStatus, 0, len(eq)+len(units))
+	for _, e := range eq {
+		if len(byItem[e.ID]) == 0 {
+			st = append(st, ServiceStatus{e, nil, latest[e.ID]})
+			continue
+		}
+
+		for _, u := range byItem[e.ID] {
+			st = append(st, ServiceStatus{e, &u, latestUnit[u.ID]})
+		}
+	}
+
+	return st, nil
+}
+
+// GetOutOfTest returns the status of each item which is out of test at the
+// given time. If items is not empty, only those items are checked.
+func GetOutOfTest(db *gorm.DB, at time.Time, items ...uint) ([]ServiceStatus, error) {
+	st, err := GetServiceStatus(db, items...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ServiceStatus, 0, len(st))
+	for _, s := range st {
+		if !s.InTest(at) {
+			out = append(out, s)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Substitute errors.
+var (
+	ErrSelfSubstitute   = errors.New("an item cannot substitute itself")
+	ErrSubstituteExists = errors.New("item is already a substitute")
+)
+
+// An ItemSubstitute records that one item may be used in place of another if
+// it is not available, such as a 250ml beaker in place of a 200ml beaker.
+// Substitution is one way; the reverse must be recorded separately.
+type ItemSubstitute struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint
+	Item   EquipmentItem
+
+	SubstituteID uint
+	Substitute   EquipmentItem
+}
+
+// An AvailableSubstitute is a substitute item along with the quantity of it
+// which is free over some period.
+type AvailableSubstitute struct {
+	EquipmentItem
+	Available int
+}
+
+// GetSubstitutes returns the items which may substitute the given item, with
+// the substitute item joined.
+func GetSubstitutes(db *gorm.DB, item uint) ([]ItemSubstitute, error) {
+	s := make([]ItemSubstitute, 0, 5)
+	res := db.Model(&ItemSubstitute{}).Joins("Substitute").
+		Where("item_substitutes.item_id = ?", item).
+		Order("Substitute.name ASC").
+		Find(&s)
+
+	if err := res.Error; err != nil {
+		return s, fmt.Errorf("get substitutes for item %d: sql error: %w", item, err)
+	}
+
+	for i := range s {
+		s[i].Substitute.db = db
+	}
+
+	return s, nil
+}
+
+// GetSubstitute returns the substitute relation with the given ID.
+func GetSubstitute(db *gorm.DB, id uint) (ItemSubstitute, error) {
+	s := ItemSubstitute{}
+	if err := db.Where("id = ?", id).First(&s).Error; err != nil {
+		return s, fmt.Errorf("get substitute %d: sql error: %w", id, err)
+	}
+
+	return s, nil
+}
+
+// AddSubstitute records that sub may be used in place of item.
+func AddSubstitute(db *gorm.DB, item, sub uint) error {
+	if item == sub {
+		return fmt.Errorf("add substitute %d for %d: %w", sub, item, ErrSelfSubstitute)
+	}
+
+	var n int64
+	if err := db.Model(&ItemSubstitute{}).Where("item_id = ? AND substitute_id = ?", item, sub).Count(&n).Error; err != nil {
+		return fmt.Errorf("add substitute %d for %d: sql error: %w", sub, item, err)
+	}
+	if n > 0 {
+		return fmt.Errorf("add substitute %d for %d: %w", sub, item, ErrSubstituteExists)
+	}
+
+	s := ItemSubstitute{Model: &gorm.Model{}, ItemID: item, SubstituteID: sub}
+	if err := db.Omit("Item", "Substitute").Create(&s).Error; err != nil {
+		return fmt.Errorf("add substitute %d for %d: sql error: %w", sub, item, err)
+	}
+
+	return nil
+}
+
+// AvailableSubstitutes returns the substitutes for an item which have stock
+// free between start and end, most available first.
+func AvailableSubstitutes(db *gorm.DB, item uint, start, end time.Time) ([]AvailableSubstitute, error) {
+	subs, err := GetSubstitutes(db, item)
+	if err != nil {
+		return nil, err
+	}
+
+	avail := make([]AvailableSubstitute, 0, len(subs))
+	for _, s := range subs {
+		if !s.Substitute.Available {
+			continue
+		}
+
+		n, err := s.Substitute.NetQuantity(start, end)
+		if err != nil {
+			return avail, fmt.Errorf("get available substitutes for item %d: %w", item, err)
+		}
+		if n <= 0 {
+			continue
+		}
+
+		avail = append(avail, AvailableSubstitute{s.Substitute, n})
+	}
+
+	sort.SliceStable(avail, func(i, j int) bool {
+		return avail[i].Available > avail[j].Available
+	})
+
+	return avail, nil
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Supplier errors.
+var (
+	ErrInvalidSupplierID = errors.New("invalid supplier ID")
+	ErrNoSuchSupplier    = errors.New("supplier does not exist")
+	ErrSupplierName      = errors.New("supplier must have a name")
+	ErrSupplierInUse     = errors.New("supplier has purchase orders and cannot be deleted")
+)
+
+// A Supplier is a company from which equipment is bought.
+type Supplier struct {
+	*gorm.Model
+	Tenancy
+
+	Name    string
+	Email   string
+	Phone   string
+	Website string
+	Notes   string
+}
+
+// A CatalogueEntry is an item sold by a supplier, with the supplier's own
+// product code and current price. An item may be sold by many suppliers.
+type CatalogueEntry struct {
+	*gorm.Model
+	Tenancy
+
+	SupplierID uint
+	Supplier   Supplier
+
+	ItemID uint
+	Item   EquipmentItem
+
+	Code  string
+	Price Price
+}
+
+// GetSupplier returns the supplier with the given ID.
+func GetSupplier(db *gorm.DB, id uint) (Supplier, error) {
+	if id == 0 {
+		return Supplier{}, fmt.Errorf("get supplier %d: %w", id, ErrInvalidSupplierID)
+	}
+
+	s := Supplier{}
+	if err := db.Where("id = ?", id).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s, fmt.Errorf("get supplier %d: %w", id, ErrNoSuchSupplier)
+		}
+
+		return s, fmt.Errorf("get supplier %d: sql error: %w", id, err)
+	}
+
+	return s, nil
+}
+
+// GetSuppliers returns every supplier, sorted by name.
+func GetSuppliers(db *gorm.DB) ([]Supplier, error) {
+	s := make([]Supplier, 0, 10)
+	if err := db.Order("name ASC").Find(&s).Error; err != nil {
+		return s, fmt.Errorf("get suppliers: sql error: %w", err)
+	}
+
+	return s, nil
+}
+
+// SaveSupplier creates or updates a supplier.
+func SaveSupplier(db *gorm.DB, s *Supplier) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("save supplier: %w", ErrSupplierName)
+	}
+
+	if s.Model == nil {
+		s.Model = &gorm.Model{}
+	}
+	if err := db.Save(s).Error; err != nil {
+		return fmt.Errorf("save supplier %d: sql error: %w", s.ID, err)
+	}
+
+	return nil
+}
+
+// DeleteSupplier deletes a supplier and its catalogue. Suppliers which have
+// been ordered from may not be deleted, as orders refer to them.
+func DeleteSupplier(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&PurchaseOrder{}).Where("supplier_id = ?", id).Count(&n).Error; err != nil {
+			return fmt.Errorf("delete supplier %d: sql error: %w", id, err)
+		}
+		if n > 0 {
+			return fmt.Errorf("delete supplier %d: %w", id, ErrSupplierInUse)
+		}
+
+		if err := tx.Where("supplier_id = ?", id).Delete(&CatalogueEntry{}).Error; err != nil {
+			return fmt.Errorf("delete supplier %d: sql error: %w", id, err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&Supplier{}).Error; err != nil {
+			return fmt.Errorf("delete supplier %d: sql error: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// GetCatalogueEntry returns the catalogue entry with the given ID.
+func GetCatalogueEntry(db *gorm.DB, id uint) (CatalogueEntry, error) {
+	e := CatalogueEntry{}
+	if err := db.Where("id = ?", id).First(&e).Error; err != nil {
+		return e, fmt.Errorf("get catalogue entry %d: sql error: %w", id, err)
+	}
+
+	return e, nil
+}
+
+// GetItemCatalogue returns the catalogue entries for an item, cheapest first,
+// with suppliers joined.
+func GetItemCatalogue(db *gorm.DB, item uint) ([]CatalogueEntry, error) {
+	e := make([]CatalogueEntry, 0, 5)
+	res := db.Model(&CatalogueEntry{}).Joins("Supplier").
+		Where("catalogue_entries.item_id = ?", item).
+		Order("catalogue_entries.price ASC").
+		Find(&e)
+
+	if err := res.Error; err != nil {
+		return e, fmt.Errorf("get catalogue for item %d: sql error: %w", item, err)
+	}
+
+	return e, nil
+}
+
+// GetSupplierCatalogue returns the catalogue entries for a supplier, sorted by
+// item name, with items joined.
+func GetSupplierCatalogue(db *gorm.DB, supplier uint) ([]CatalogueEntry, error) {
+	e := make([]CatalogueEntry, 0, 20)
+	res := db.Model(&CatalogueEntry{}).Joins("Item").
+		Where("catalogue_entries.supplier_id = ?", supplier).
+		Order("Item.name ASC").
+		Find(&e)
+
+	if err := res.Error; err != nil {
+		return e, fmt.Errorf("get catalogue for supplier %d: sql error: %w", supplier, err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tenancy is embedded in every model to record the tenant (school) to which a
+// row belongs. Rows with a blank tenant belong to no school in particular and
+// are only visible from the trust level.
+type Tenancy struct {
+	Tenant string `gorm:"size:64;index" json:"-"`
+}
+
+// tenantKey is the context key under which a tenant ID is stored.
+type tenantKey struct{}
+
+// WithTenant returns a database handle which can only see and modify rows
+// belonging to the given tenant, and which stamps any rows it creates as
+// belonging to that tenant. A blank tenant refers to rows owned by no tenant.
+//
+// Tenant isolation is only applied once RegisterTenancy has been called on
+// the database.
+func WithTenant(db *gorm.DB, tenant string) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, tenantKey{}, tenant))
+}
+
+// TenantOf returns the tenant to which db is restricted, if any.
+func TenantOf(db *gorm.DB) (string, bool) {
+	if db.Statement.Context == nil {
+		return "", false
+	}
+
+	t, ok := db.Statement.Context.Value(tenantKey{}).(string)
+	return t, ok
+}
+
+// RegisterTenancy installs the callbacks which isolate tenants from each other
+// on db. Handles from WithTenant are then restricted to their tenant, while
+// those without a tenant may see everything.
+func RegisterTenancy(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Create().Before("gorm:create").Register("prepper:tenant_create", tenantCreate); err != nil {
+		return fmt.Errorf("register tenancy: %w", err)
+	}
+	if err := cb.Query().Before("gorm:query").Register("prepper:tenant_query", tenantScope); err != nil {
+		return fmt.Errorf("register tenancy: %w", err)
+	}
+	if err := cb.Row().Before("gorm:row").Register("prepper:tenant_row", tenantScope); err != nil {
+		return fmt.Errorf("register tenancy: %w", err)
+	}
+	if err := cb.Update().Before("gorm:update").Register("prepper:tenant_update", tenantUpdate); err != nil {
+		return fmt.Errorf("register tenancy: %w", err)
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("prepper:tenant_delete", tenantScope); err != nil {
+		return fmt.Errorf("register tenancy: %w", err)
+	}
+
+	return nil
+}
+
+// tenantField returns the tenant field of the statement's model, if it has
+// one and the statement is restricted to a tenant.
+func tenantField(db *gorm.DB) (string, bool) {
+	t, ok := TenantOf(db)
+	if !ok || db.Statement.Schema == nil || db.Statement.Schema.LookUpField("Tenant") == nil {
+		return "", false
+	}
+
+	return t, true
+}
+
+// tenantScope restricts a statement to rows of the current tenant.
+func tenantScope(db *gorm.DB) {
+	t, ok := tenantField(db)
+	if !ok {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant"}, Value: t},
+	}})
+}
+
+// tenantUpdate restricts an update to rows of the current tenant, ensuring
+// that saving a whole record can not move it out of the tenant.
+func tenantUpdate(db *gorm.DB) {
+	tenantScope(db)
+	tenantCreate(db)
+}
+
+// tenantCreate stamps newly created rows with the current tenant.
+func tenantCreate(db *gorm.DB) {
+	t, ok := tenantField(db)
+	if !ok {
+		return
+	}
+
+	f := db.Statement.Schema.LookUpField("Tenant")
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := f.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), t); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if !rv.CanAddr() {
+			return
+		}
+		if err := f.Set(db.Statement.Context, rv, t); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// AdoptUntenanted gives every row which belongs to no tenant to the given
+// tenant, except for admin accounts, which remain at the trust level. This is
+// used to move the data of a single school into a newly configured tenant.
+// The number of rows adopted is returned.
+func AdoptUntenanted(db *gorm.DB, tenant string, models ...interface{}) (int64, error) {
+	var n int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range models {
+			q := tx.Unscoped().Model(m).Where("tenant = ?", "")
+			if _, ok := m.(*User); ok {
+				q = q.Where("role < ?", UserAdmin)
+			}
+
+			res := q.Update("tenant", tenant)
+			if res.Error != nil {
+				return res.Error
+			}
+			n += res.RowsAffected
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("adopt untenanted rows: sql error: %w", err)
+	}
+
+	return n, nil
+}
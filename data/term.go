@@ -0,0 +1,63 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Months in which each school term begins. Terms run until the start of the
+// next.
+const (
+	springStart = time.January
+	summerStart = time.April
+	autumnStart = time.September
+)
+
+// A Term is one of the three terms of the school year.
+type Term struct {
+	// ID of the term in the academic calendar, or zero if the term is
+	// assumed rather than configured.
+	ID    uint
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// TermOf returns the term which contains the given time, in the time's
+// location.
+func TermOf(t time.Time) Term {
+	y := t.Year()
+	switch {
+	case t.Month() >= autumnStart:
+		return Term{Name: "Autumn", Start: termDate(y, autumnStart, t), End: termDate(y+1, springStart, t)}
+	case t.Month() >= summerStart:
+		return Term{Name: "Summer", Start: termDate(y, summerStart, t), End: termDate(y, autumnStart, t)}
+	default:
+		return Term{Name: "Spring", Start: termDate(y, springStart, t), End: termDate(y, summerStart, t)}
+	}
+}
+
+func termDate(y int, m time.Month, in time.Time) time.Time {
+	return time.Date(y, m, 1, 0, 0, 0, 0, in.Location())
+}
+
+func (t Term) String() string {
+	// Terms from the academic calendar may already be named by year.
+	if strings.Contains(t.Name, strconv.Itoa(t.Start.Year())) {
+		return t.Name
+	}
+
+	return fmt.Sprint(t.Name, " ", t.Start.Year())
+}
+
+// Previous returns the term before this one.
+func (t Term) Previous() Term {
+	return TermOf(t.Start.Add(-time.Hour))
+}
+
+// Contains returns true if the given time is within this term.
+func (t Term) Contains(at time.Time) bool {
+	return !at.Before(t.Start) && at.Before(t.End)
+}
@@ -0,0 +1,214 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Unit conditions. Damaged and retired units may not be issued for bookings.
+const (
+	ConditionGood = iota
+	ConditionFair
+	ConditionDamaged
+	ConditionRetired
+)
+
+// Serialised unit errors.
+var (
+	ErrInvalidUnitID = errors.New("invalid unit ID")
+	ErrNoSuchUnit    = errors.New("unit does not exist")
+	ErrUnitUnusable  = errors.New("unit is damaged or retired")
+	ErrUnitBooked    = errors.New("unit is issued to another booking at this time")
+	ErrTooManyUnits  = errors.New("more units issued than were booked")
+	ErrUnitHistory   = errors.New("unit has been issued or serviced; retire it instead")
+)
+
+// UnitCondition is the enumerator type for the condition of a unit.
+type UnitCondition uint8
+
+func (u UnitCondition) String() string {
+	switch u {
+	case ConditionGood:
+		return "Good"
+	case ConditionFair:
+		return "Fair"
+	case ConditionDamaged:
+		return "Damaged"
+	case ConditionRetired:
+		return "Retired"
+	default:
+		return "Unknown"
+	}
+}
+
+// Usable returns true if units in this condition may be issued.
+func (u UnitCondition) Usable() bool {
+	return u == ConditionGood || u == ConditionFair
+}
+
+// An EquipmentUnit is a single, serial numbered unit of an item, for
+// expensive equipment which must be tracked individually.
+type EquipmentUnit struct {
+	*gorm.Model
+	Tenancy
+
+	ItemID uint
+	Item   EquipmentItem `json:"-"`
+
+	Serial    string
+	Condition UnitCondition
+	Notes     string
+
+	LocationID *uint
+	Location   *StorageLocation
+}
+
+// StoredAt returns the ID of the location where the unit is stored, or zero
+// if this is not known.
+func (u EquipmentUnit) StoredAt() uint {
+	if u.LocationID == nil {
+		return 0
+	}
+
+	return *u.LocationID
+}
+
+// GetUnit looks up a single unit by ID.
+func GetUnit(db *gorm.DB, id uint) (EquipmentUnit, error) {
+	if id == 0 {
+		return EquipmentUnit{}, fmt.Errorf("get unit %d: %w", id, ErrInvalidUnitID)
+	}
+
+	u := EquipmentUnit{Model: &gorm.Model{ID: id}}
+	if err := db.Where(&u).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return u, fmt.Errorf("get unit %d: %w", id, ErrNoSuchUnit)
+		}
+
+		return u, fmt.Errorf("get unit %d: sql error: %w", id, err)
+	}
+
+	return u, nil
+}
+
+// GetItemUnits returns every unit of the given item, ordered by serial.
+func GetItemUnits(db *gorm.DB, item uint) ([]EquipmentUnit, error) {
+	u := make([]EquipmentUnit, 0, 5)
+	res := db.Model(&EquipmentUnit{}).Preload("Location").
+		Where(&EquipmentUnit{ItemID: item}).
+		Order("serial ASC").
+		Find(&u)
+
+	if err := res.Error; err != nil {
+		return u, fmt.Errorf("get units for item %d: sql error: %w", item, err)
+	}
+
+	return u, nil
+}
+
+// unitBookedElsewhere returns true if the unit is issued to any booking other
+// than bk which overlaps with it.
+func unitBookedElsewhere(db *gorm.DB, unit uint, bk Booking) (bool, error) {
+	var n int64
+	res := db.Table("set_units").
+		Joins("JOIN equipment_sets ON equipment_sets.id = set_units.equipment_set_id AND equipment_sets.deleted_at IS NULL").
+		Joins("JOIN bookings ON bookings.activity_id = equipment_sets.activity_id AND bookings.deleted_at IS NULL").
+		Where("set_units.equipment_unit_id = ? AND bookings.id <> ?", unit, bk.ID).
+		Where("bookings.start_time < ? AND bookings.end_time > ?", bk.EndTime, bk.StartTime).
+		Count(&n)
+
+	if err := res.Error; err != nil {
+		return false, fmt.Errorf("check unit %d: sql error: %w", unit, err)
+	}
+
+	return n > 0, nil
+}
+
+// IssueUnits records the given units as issued for an equipment set of the
+// given booking, replacing any previously issued. Units must be of the right
+// item, usable and not issued to any other booking at the same time.
+func IssueUnits(db *gorm.DB, bk Booking, set EquipmentSet, units []uint) error {
+	if uint(len(units)) > set.Quantity {
+		return fmt.Errorf("issue units for %s: %w", set.Item.Name, ErrTooManyUnits)
+	}
+
+	us := make([]EquipmentUnit, 0, len(units))
+	for _, id := range units {
+		u, err := GetUnit(db, id)
+		if err != nil {
+			return fmt.Errorf("issue units for %s: %w", set.Item.Name, err)
+		}
+		if u.ItemID != set.ItemID {
+			return fmt.Errorf("issue units for %s: unit %s: %w", set.Item.Name, u.Serial, ErrNoSuchUnit)
+		}
+		if !u.Condition.Usable() {
+			return fmt.Errorf("issue units for %s: unit %s: %w", set.Item.Name, u.Serial, ErrUnitUnusable)
+		}
+
+		booked, err := unitBookedElsewhere(db, u.ID, bk)
+		if err != nil {
+			return fmt.Errorf("issue units for %s: %w", set.Item.Name, err)
+		}
+		if booked {
+			return fmt.Errorf("issue units for %s: unit %s: %w", set.Item.Name, u.Serial, ErrUnitBooked)
+		}
+
+		us = append(us, u)
+	}
+
+	if err := db.Model(&set).Association("Units").Replace(us); err != nil {
+		return fmt.Errorf("issue units for %s: sql error: %w", set.Item.Name, err)
+	}
+
+	return nil
+}
+
+// GetUnitBookings returns the bookings for which the given unit has been
+// issued, most recent first.
+func GetUnitBookings(db *gorm.DB, unit uint, limit int) ([]Booking, error) {
+	bk := make([]Booking, 0, limit)
+	res := db.Model(&Booking{}).
+		Joins("Owner").Joins("Activity").
+		Joins("JOIN equipment_sets ON equipment_sets.activity_id = bookings.activity_id AND equipment_sets.deleted_at IS NULL").
+		Joins("JOIN set_units ON set_units.equipment_set_id = equipment_sets.id").
+		Where("set_units.equipment_unit_id = ?", unit).
+		Order("start_time DESC").
+		Limit(limit).
+		Find(&bk)
+
+	if err := res.Error; err != nil {
+		return bk, fmt.Errorf("get bookings for unit %d: sql error: %w", unit, err)
+	}
+
+	return bk, nil
+}
+
+// UnitIssuedAt returns true if the unit is issued to any booking which is
+// ongoing at the given time.
+func UnitIssuedAt(db *gorm.DB, unit uint, at time.Time) (bool, error) {
+	return unitBookedElsewhere(db, unit, Booking{Model: &gorm.Model{}, StartTime: at, EndTime: at.Add(time.Second)})
+}
+
+// DeleteUnit deletes the given unit. Units which have been issued for a
+// booking or serviced may not be deleted, so that their history is kept.
+func DeleteUnit(db *gorm.DB, u EquipmentUnit) error {
+	var issued, serviced int64
+	if err := db.Table("set_units").Where("equipment_unit_id = ?", u.ID).Count(&issued).Error; err != nil {
+		return fmt.Errorf("delete unit %d: sql error: %w", u.ID, err)
+	}
+	if err := db.Model(&ServiceRecord{}).Where("unit_id = ?", u.ID).Count(&serviced).Error; err != nil {
+		return fmt.Errorf("delete unit %d: sql error: %w", u.ID, err)
+	}
+	if issued > 0 || serviced > 0 {
+		return fmt.Errorf("delete unit %d: %w", u.ID, ErrUnitHistory)
+	}
+
+	if err := db.Delete(&u).Error; err != nil {
+		return fmt.Errorf("delete unit %d: sql error: %w", u.ID, err)
+	}
+
+	return nil
+}
@@ -50,6 +50,7 @@ func (u UserRole) String() string {
 // hint just in case). All other fields are either cosmetic or for convenience.
 type User struct {
 	*gorm.Model
+	Tenancy
 
 	Username     string    `json:"username"`
 	Password     *Password `json:"-"`
@@ -60,6 +61,12 @@ type User struct {
 	Role         UserRole  `json:"role"`
 	Email        string    `json:"email"`
 	Telephone    string    `json:"telephone"`
+	Department   string    `json:"department"`
+
+	// PrepRoomID is the prep room in which the user works. Technicians
+	// with no prep room hear about bookings for all of them.
+	PrepRoomID *uint     `json:"prep_room_id"`
+	PrepRoom   *PrepRoom `json:"-"`
 
 	// IsamsID is the isams UserCode for this user.
 	IsamsID *string `json:"isams_id"`
@@ -184,6 +191,12 @@ func (u User) IsAdmin() bool {
 	return u.Role >= UserAdmin
 }
 
+// IsTrustAdmin returns true if the user is an admin who belongs to no tenant.
+// When tenants are configured, trust admins may see the data of every tenant.
+func (u User) IsTrustAdmin() bool {
+	return u.IsAdmin() && u.Tenant == ""
+}
+
 // DisplayName returns the name which we should prefer to display on the user's
 // end. This is not machine-friendly.
 func (u User) DisplayName() string {
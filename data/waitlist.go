@@ -0,0 +1,201 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// need returns the quantity of each item booked, keyed by item ID.
+func (b Booking) need() map[uint]int {
+	need := make(map[uint]int, len(b.Activity.Equipment))
+	for _, eq := range b.Activity.Equipment {
+		need[eq.ItemID] += int(eq.Quantity)
+	}
+
+	return need
+}
+
+// overlaps returns true if o counts against the stock free over the period of
+// b. This matches the bookings found by EquipmentItem.Bookings.
+func (b Booking) overlaps(o Booking) bool {
+	return (!o.StartTime.After(b.StartTime) && !o.EndTime.Before(b.StartTime)) ||
+		(!o.StartTime.Before(b.StartTime) && !o.StartTime.After(b.EndTime))
+}
+
+// fits returns true if free holds at least the quantity of each item in need.
+func fits(need, free map[uint]int) bool {
+	for id, n := range need {
+		if free[id] < n {
+			return false
+		}
+	}
+
+	return true
+}
+
+// free returns the quantity of each item booked which is free over the period
+// of the booking, keyed by item ID. Equipment already held by the booking is
+// counted as free.
+func (b Booking) free(db *gorm.DB) (map[uint]int, error) {
+	need := b.need()
+	free := make(map[uint]int, len(need))
+	for _, eq := range b.Activity.Equipment {
+		if _, ok := free[eq.ItemID]; ok {
+			continue
+		}
+
+		it := eq.Item
+		it.UseDB(db)
+		net, err := it.NetQuantity(b.StartTime, b.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if b.Status.Committed() {
+			net += need[eq.ItemID]
+		}
+		free[eq.ItemID] = net
+	}
+
+	return free, nil
+}
+
+// Fits returns true if enough of each item booked is free over the period of
+// the booking for it to go ahead. Equipment already held by the booking is
+// counted as free.
+func (b Booking) Fits(db *gorm.DB) (bool, error) {
+	free, err := b.free(db)
+	if err != nil {
+		return false, fmt.Errorf("check booking #%d fits: %w", b.ID, err)
+	}
+
+	return fits(b.need(), free), nil
+}
+
+// WaitlistBooking places the booking with the given ID on the waitlist if
+// there is not enough equipment free for it to go ahead. Returns true if the
+// booking was waitlisted.
+func WaitlistBooking(db *gorm.DB, id uint) (bool, error) {
+	bk, err := GetBooking(db, id)
+	if err != nil {
+		return false, fmt.Errorf("waitlist booking: %w", err)
+	}
+
+	fits, err := bk.Fits(db)
+	if err != nil || fits {
+		return false, err
+	}
+
+	if err := db.Model(&bk).Update("status", BookingStatusWaitlisted).Error; err != nil {
+		return false, fmt.Errorf("waitlist booking #%d: sql error: %w", id, err)
+	}
+
+	return true, nil
+}
+
+// GetWaitlist returns the waitlisted bookings which overlap the given period
+// and have yet to start, in the order in which they were made.
+func GetWaitlist(db *gorm.DB, start, end time.Time) ([]Booking, error) {
+	b := make([]Booking, 0, 5)
+	res := db.Model(&Booking{}).Joins("Activity").Joins("Owner").
+		Preload("Activity.Equipment").
+		Preload("Activity.Equipment.Item").
+		Where("bookings.status = ?", BookingStatusWaitlisted).
+		Where("bookings.start_time > ?", time.Now()).
+		Where("bookings.start_time < ? AND bookings.end_time > ?", end.UTC(), start.UTC()).
+		Order("bookings.created_at ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return b, fmt.Errorf("get waitlist: sql error: %w", err)
+	}
+
+	return b, nil
+}
+
+// promotable returns the bookings of the waitlist wl which may be promoted,
+// given the stock free for each before any are. Bookings are considered in
+// order, with each promoted taking the equipment it needs from those after
+// it which it overlaps.
+func promotable(wl []Booking, free []map[uint]int) []Booking {
+	var promoted []Booking
+	for i, bk := range wl {
+		left := make(map[uint]int, len(free[i]))
+		for id, n := range free[i] {
+			left[id] = n
+		}
+		for _, p := range promoted {
+			if !bk.overlaps(p) {
+				continue
+			}
+			for id, n := range p.need() {
+				left[id] -= n
+			}
+		}
+
+		if fits(bk.need(), left) {
+			promoted = append(promoted, bk)
+		}
+	}
+
+	return promoted
+}
+
+// PromoteWaitlist re-evaluates the waitlisted bookings overlapping the given
+// period in the order in which they were made, promoting to pending each for
+// which enough equipment is now free. Earlier bookings take the equipment
+// they need before later ones are considered. The promoted bookings are
+// returned.
+func PromoteWaitlist(db *gorm.DB, start, end time.Time) ([]Booking, error) {
+	wl, err := GetWaitlist(db, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("promote waitlist: %w", err)
+	}
+
+	free := make([]map[uint]int, len(wl))
+	for i, bk := range wl {
+		free[i], err = bk.free(db)
+		if err != nil {
+			return nil, fmt.Errorf("promote waitlist: booking #%d: %w", bk.ID, err)
+		}
+	}
+
+	var promoted []Booking
+	for _, bk := range promotable(wl, free) {
+		if err := db.Model(&bk).Update("status", BookingStatusPending).Error; err != nil {
+			return promoted, fmt.Errorf("promote waitlist: booking #%d: sql error: %w", bk.ID, err)
+		}
+		bk, err = ChargeBooking(db, bk.ID)
+		if err != nil {
+			return promoted, fmt.Errorf("promote waitlist: %w", err)
+		}
+		promoted = append(promoted, bk)
+	}
+
+	return promoted, nil
+}
+
+// ExpireWaitlist rejects the bookings still waitlisted which start before the
+// given time, as equipment can no longer be freed for them in time. The
+// rejected bookings are returned.
+func ExpireWaitlist(db *gorm.DB, t time.Time) ([]Booking, error) {
+	b := make([]Booking, 0, 5)
+	res := db.Model(&Booking{}).Joins("Activity").Joins("Owner").
+		Where("bookings.status = ?", BookingStatusWaitlisted).
+		Where("bookings.start_time < ?", t.UTC()).
+		Order("bookings.start_time ASC").
+		Find(&b)
+
+	if err := res.Error; err != nil {
+		return nil, fmt.Errorf("expire waitlist: sql error: %w", err)
+	}
+
+	for i := range b {
+		if err := db.Model(&b[i]).Update("status", BookingStatusRejected).Error; err != nil {
+			return b[:i], fmt.Errorf("expire waitlist: booking #%d: sql error: %w", b[i].ID, err)
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Items booked in the waitlist tests.
+const (
+	testBeakers = iota + 1
+	testBurners
+)
+
+// waitlisted returns a waitlisted booking for the lesson starting at the
+// given hour on a fixed day, needing the given quantity of each item.
+func waitlisted(id uint, hour int, need map[uint]uint) Booking {
+	start := day(2026, 10, 19).Add(time.Duration(hour) * time.Hour)
+	bk := Booking{
+		Model:     &gorm.Model{ID: id},
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Status:    BookingStatusWaitlisted,
+	}
+	for item, n := range need {
+		bk.Activity.Equipment = append(bk.Activity.Equipment, EquipmentSet{ItemID: item, Quantity: n})
+	}
+
+	return bk
+}
+
+func TestBookingOverlaps(t *testing.T) {
+	bk := waitlisted(1, 10, nil)
+
+	testdata := []struct {
+		Name   string
+		Hour   int
+		Expect bool
+	}{
+		{"Same lesson", 10, true},
+		{"Lesson before", 9, true},
+		{"Lesson after", 11, true},
+		{"Earlier", 8, false},
+		{"Later", 12, false},
+	}
+
+	for _, d := range testdata {
+		if got := bk.overlaps(waitlisted(2, d.Hour, nil)); got != d.Expect {
+			t.Errorf("%s: expected overlap %v, got %v", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	testdata := []struct {
+		Name   string
+		Need   map[uint]int
+		Free   map[uint]int
+		Expect bool
+	}{
+		{"Nothing needed", nil, nil, true},
+		{"Exactly enough", map[uint]int{testBeakers: 5}, map[uint]int{testBeakers: 5}, true},
+		{"Too few", map[uint]int{testBeakers: 5}, map[uint]int{testBeakers: 4}, false},
+		{"Overbooked", map[uint]int{testBeakers: 1}, map[uint]int{testBeakers: -2}, false},
+		{"One item short", map[uint]int{testBeakers: 5, testBurners: 2}, map[uint]int{testBeakers: 5, testBurners: 1}, false},
+		{"Item not free", map[uint]int{testBurners: 1}, map[uint]int{testBeakers: 5}, false},
+	}
+
+	for _, d := range testdata {
+		if got := fits(d.Need, d.Free); got != d.Expect {
+			t.Errorf("%s: expected %v, got %v", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestPromotable(t *testing.T) {
+	testdata := []struct {
+		Name     string
+		Waitlist []Booking
+		Free     []map[uint]int
+		Expect   []uint
+	}{
+		{
+			"Nothing free",
+			[]Booking{waitlisted(1, 10, map[uint]uint{testBeakers: 5})},
+			[]map[uint]int{{testBeakers: 4}},
+			nil,
+		},
+		{
+			"Promoted in order",
+			[]Booking{
+				waitlisted(2, 10, map[uint]uint{testBeakers: 5}),
+				waitlisted(1, 10, map[uint]uint{testBeakers: 5}),
+			},
+			[]map[uint]int{{testBeakers: 5}, {testBeakers: 5}},
+			[]uint{2},
+		},
+		{
+			"Earlier bookings take the stock first",
+			[]Booking{
+				waitlisted(1, 10, map[uint]uint{testBeakers: 3}),
+				waitlisted(2, 10, map[uint]uint{testBeakers: 3}),
+				waitlisted(3, 10, map[uint]uint{testBeakers: 2}),
+			},
+			[]map[uint]int{{testBeakers: 5}, {testBeakers: 5}, {testBeakers: 5}},
+			[]uint{1, 3},
+		},
+		{
+			"Earlier bookings which do not fit take nothing",
+			[]Booking{
+				waitlisted(1, 10, map[uint]uint{testBeakers: 6}),
+				waitlisted(2, 10, map[uint]uint{testBeakers: 5}),
+			},
+			[]map[uint]int{{testBeakers: 5}, {testBeakers: 5}},
+			[]uint{2},
+		},
+		{
+			"Different lessons",
+			[]Booking{
+				waitlisted(1, 9, map[uint]uint{testBeakers: 5}),
+				waitlisted(2, 14, map[uint]uint{testBeakers: 5}),
+			},
+			[]map[uint]int{{testBeakers: 5}, {testBeakers: 5}},
+			[]uint{1, 2},
+		},
+		{
+			"Different items",
+			[]Booking{
+				waitlisted(1, 10, map[uint]uint{testBeakers: 5}),
+				waitlisted(2, 10, map[uint]uint{testBurners: 2}),
+			},
+			[]map[uint]int{{testBeakers: 5}, {testBurners: 2}},
+			[]uint{1, 2},
+		},
+		{
+			"Every item needed",
+			[]Booking{
+				waitlisted(1, 10, map[uint]uint{testBeakers: 2}),
+				waitlisted(2, 10, map[uint]uint{testBeakers: 2, testBurners: 1}),
+			},
+			[]map[uint]int{{testBeakers: 3}, {testBeakers: 3, testBurners: 1}},
+			[]uint{1},
+		},
+	}
+
+	for _, d := range testdata {
+		var got []uint
+		for _, bk := range promotable(d.Waitlist, d.Free) {
+			got = append(got, bk.ID)
+		}
+
+		if !reflect.DeepEqual(got, d.Expect) {
+			t.Errorf("%s: expected %v promoted, got %v", d.Name, d.Expect, got)
+		}
+	}
+}
+
+func TestExpireWaitlist(t *testing.T) {
+	db := testDB(t)
+
+	owner := testOwner(t, db, "")
+	item := testItem(t, db, "Beaker", 5, 0)
+
+	// Each booking takes every beaker, so the later bookings are waitlisted.
+	now := time.Now()
+	started := now.Add(-30 * time.Minute)
+	held := testBooking(t, db, owner, item, 5, "Titration", "Chemistry", started, now.Add(time.Hour))
+	late := testBooking(t, db, owner, item, 5, "Rates", "Chemistry", started, now.Add(time.Hour))
+	soon := testBooking(t, db, owner, item, 5, "Crystals", "Chemistry", now.Add(10*time.Minute), now.Add(time.Hour))
+	for _, bk := range []Booking{late, soon} {
+		if ok, err := WaitlistBooking(db, bk.ID); err != nil || !ok {
+			t.Fatalf("waitlist booking #%d: waitlisted %v, error %v", bk.ID, ok, err)
+		}
+	}
+
+	exp, err := ExpireWaitlist(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp) != 1 || exp[0].ID != late.ID {
+		t.Fatalf("expected booking #%d to expire, got %v", late.ID, exp)
+	}
+	if exp[0].Owner.ID != owner.ID || exp[0].Activity.Title != "Rates" {
+		t.Errorf("expired booking missing owner or activity: %q for %q", exp[0].Activity.Title, exp[0].Owner.DisplayName())
+	}
+
+	want := map[uint]BookingStatus{
+		held.ID: BookingStatusPending,
+		late.ID: BookingStatusRejected,
+		soon.ID: BookingStatusWaitlisted,
+	}
+	for id, st := range want {
+		bk, err := GetBooking(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bk.Status != st {
+			t.Errorf("booking #%d: expected status %v, got %v", id, st, bk.Status)
+		}
+	}
+
+	// Expired bookings are not expired again.
+	if exp, err := ExpireWaitlist(db, now); err != nil || len(exp) != 0 {
+		t.Errorf("second expiry: expired %d, error %v", len(exp), err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/isams"
+)
+
+// closureWeeks is the number of weeks ahead for which closures are warned
+// about when booking.
+const closureWeeks = 12
+
+// schoolDateForm is the form used to add dates to the academic calendar.
+type schoolDateForm struct {
+	Name  string `form:"name"`
+	Kind  uint8  `form:"kind"`
+	Start string `form:"start"`
+	End   string `form:"end"`
+}
+
+// seedSchoolDates adds or updates the terms from is in the academic calendar
+// in db.
+func seedSchoolDates(db *gorm.DB, is *isams.ISAMS) (int, error) {
+	ds := make([]data.SchoolDate, len(is.Terms))
+	for i, t := range is.Terms {
+		ds[i] = t.DataDate()
+	}
+
+	return data.ImportSchoolDates(db, ds)
+}
+
+// checkOpen refuses bookings starting at start on days when the school is
+// closed, unless the user may override the booking policy. If the booking is
+// refused, a response is written and false is returned.
+func checkOpen(c *gin.Context, u data.User, start time.Time) bool {
+	if u.Can(data.CapOverridePolicy) {
+		return true
+	}
+
+	cal, err := data.GetAcademicCalendar(tenantDB(c))
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+
+	if d, closed := cal.Closure(start); closed {
+		c.String(http.StatusForbidden, "Booking Refused: the school is closed on %s (%s)", start.In(time.Local).Format("Monday 02/01/06"), d.Name)
+		return false
+	}
+
+	return true
+}
+
+// handleSchoolDates is the handler for "/dates/".
+//
+// Shows the academic calendar of terms, holidays and INSET days, along with
+// forms for editing it for technicians.
+func handleSchoolDates(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	ds, err := data.GetSchoolDates(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Dates    []data.SchoolDate
+		Kinds    []data.DateKind
+		Term     data.Term
+		ISAMS    bool
+		Imported string
+		Error    string
+	}{ddat, ds, data.DateKinds, data.NewAcademicCalendar(ds).TermOf(time.Now()), tenantISAMS(c) != nil, c.Query("imported"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "dates.gohtml", dat)
+}
+
+// handleSchoolDateNew is the handler for POST "/inventory/dates".
+func handleSchoolDateNew(c *gin.Context) {
+	db := tenantDB(c)
+	frm := schoolDateForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	start, err := time.ParseInLocation(dateFormat, frm.Start, time.Local)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Start Date: %s", err.Error())
+		return
+	}
+	end := start
+	if frm.End != "" {
+		end, err = time.ParseInLocation(dateFormat, frm.End, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad End Date: %s", err.Error())
+			return
+		}
+	}
+	if int(frm.Kind) >= len(data.DateKinds) {
+		c.String(http.StatusBadRequest, "Bad Date Kind")
+		return
+	}
+
+	d := data.SchoolDate{Name: frm.Name, Kind: data.DateKind(frm.Kind), Start: start, End: end}
+	if err := data.SaveSchoolDate(db, &d); err != nil {
+		if errors.Is(err, data.ErrSchoolDateName) || errors.Is(err, data.ErrSchoolDateRange) {
+			c.Redirect(http.StatusFound, "/dates/?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/dates/")
+}
+
+// handleSchoolDateDelete is the handler for "/inventory/date/[ID]/delete".
+func handleSchoolDateDelete(c *gin.Context) {
+	lid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Date ID")
+		return
+	}
+
+	if err := data.DeleteSchoolDate(tenantDB(c), uint(lid)); err != nil {
+		if errors.Is(err, data.ErrNoSuchSchoolDate) {
+			c.String(http.StatusNotFound, "Date Not Found")
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/dates/")
+}
+
+// handleSchoolDateImport is the handler for "/inventory/dates/import".
+//
+// Adds or updates the terms set up in iSAMS.
+func handleSchoolDateImport(c *gin.Context) {
+	is := tenantISAMS(c)
+	if is == nil {
+		c.String(http.StatusNotFound, "iSAMS Not Enabled")
+		return
+	}
+
+	n, err := seedSchoolDates(tenantDB(c), is)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	log.Println("imported", n, "terms from iSAMS")
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/dates/?imported=", n))
+}
@@ -65,7 +65,7 @@ func handleHelp(c *gin.Context) {
 		return
 	}
 
-	ht := Config.HelpText
+	ht := tenantConfig(c).HelpText
 	if ht == "" {
 		ht = conf.DefaultHelpText
 	}
@@ -92,9 +92,15 @@ func handleLogin(c *gin.Context) {
 	_, fail := c.GetQuery("error")
 	_, out := c.GetQuery("out")
 
+	var school string
+	if t := tenantOf(c); !t.IsTrust() {
+		school = t.DisplayName()
+	}
+
 	c.HTML(http.StatusOK, "login.gohtml", gin.H{
 		"LoginFailed": fail,
 		"LoggedOut":   out,
+		"School":      school,
 	})
 }
 
@@ -118,7 +124,7 @@ func handleLoginAttempt(c *gin.Context) {
 		return
 	}
 
-	us, err := data.GetUserByName(Database, frm.Username)
+	us, err := findLoginUser(c, frm.Username)
 	if err != nil {
 		// SQL error
 		if !errors.Is(err, data.ErrUserNotFound) {
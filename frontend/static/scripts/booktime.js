@@ -5,6 +5,14 @@
  */
 
 const clashes_endpoint = "/api/clashes";
+const room_clashes_endpoint = "/api/rooms/clashes";
+const week_endpoint = "/api/week";
+const periods_endpoint = "/api/periods";
+
+/*
+ * periods are the periods of the day chosen on the manual form.
+ */
+let periods = [];
 
 /*
  * submitbtn is used to finally submit the form at the end of the popover
@@ -23,16 +31,92 @@ function update_commencing()
 	$("form").each(function() {
 		$(this).children(".week_commencing_input").val(v);
 	});
+
+	update_week(v);
+}
+
+/*
+ * update_week shows the timetable of the week of the rotation which contains
+ * date, if a rotation is configured.
+ */
+function update_week(date)
+{
+	if ($("#week-name").length == 0 || !date) {
+		return;
+	}
+
+	var req = new XMLHttpRequest();
+	req.open("GET", week_endpoint + "?date=" + encodeURIComponent(date), true);
+	req.onreadystatechange = function() {
+		if (this.readyState == 4 && this.status == 200) {
+			let dat = JSON.parse(this.responseText);
+
+			$("#week-name").text(dat.name);
+			let tab = $('button[data-week="' + dat.index + '"]');
+			if (tab.length > 0) {
+				bootstrap.Tab.getOrCreateInstance(tab[0]).show();
+			}
+		}
+	}
+	req.send();
+}
+
+/*
+ * update_periods fills the period picker with the periods of the date chosen
+ * on the manual form, as the timetable layout may differ between days.
+ */
+function update_periods()
+{
+	let date = $("#date-input").val();
+	let sel = $("#period-input");
+
+	periods = [];
+	sel.empty().prop("disabled", true);
+	if (!date) {
+		sel.append($("<option></option>").val("").text("Choose a date first"));
+		return;
+	}
+
+	var req = new XMLHttpRequest();
+	req.open("GET", periods_endpoint + "?date=" + encodeURIComponent(date), true);
+	req.onreadystatechange = function() {
+		if (this.readyState == 4 && this.status == 200) {
+			periods = JSON.parse(this.responseText);
+
+			sel.append($("<option></option>").val("").text(periods.length == 0 ? "No periods" : "Custom time"));
+			periods.forEach((p, i) => {
+				let label = p.name + " (" + p.start.substring(0, 5) + " - " + p.end.substring(0, 5) + ")";
+				sel.append($("<option></option>").val(i).text(label));
+			});
+			sel.prop("disabled", periods.length == 0);
+		}
+	}
+	req.send();
 }
 
 /*
- * show_clashes shows the clashes modal, using the data returned by the API
- * (expected as parsed JSON). This should be treated as the end of control by
- * your function.
+ * pick_period fills the start and end times of the manual form from the
+ * chosen period.
  */
-function show_clashes(clashes)
+function pick_period()
 {
-	if (clashes.length == 0) {
+	let i = $("#period-input").val();
+	if (i === "" || !periods[i]) {
+		return;
+	}
+
+	$("#stime-input").val(periods[i].start.substring(0, 5));
+	$("#etime-input").val(periods[i].end.substring(0, 5));
+}
+
+/*
+ * show_clashes shows the clashes modal, using the equipment and room clashes
+ * returned by the API (expected as parsed JSON). This should be treated as the
+ * end of control by your function.
+ */
+function show_clashes(clashes, rooms)
+{
+	if (clashes.length == 0 && rooms.length == 0) {
 		end_clashes();
 		return;
 	}
@@ -53,9 +137,121 @@ function show_clashes(clashes)
 		r.append('<td class="text-danger">'+c.net_quantity+'</td>');
 	});
 
+	$("#itemClashes").toggleClass("d-none", clashes.length == 0);
+	let short = clashes.some((c) => c.net_quantity < 0);
+	$("#waitlistButton, #waitlistHint").toggleClass("d-none", !short);
+	show_substitutes(clashes);
+	show_room_clashes(rooms);
+
 	$("#clashesModal").modal("show");
 }
 
+/*
+ * show_room_clashes lists the other bookings in the same room.
+ */
+function show_room_clashes(rooms)
+{
+	$("#roomClashesList").empty();
+	rooms.forEach((r) => {
+		let a = $("<a></a>").attr("href", "/book/booking/" + r.booking_id)
+			.text(r.booking_activity + " (" + r.booking_user + ")");
+		let li = $("<li></li>").text(r.location + ", " + r.booking_starts + " - " + r.booking_ends + ": ");
+		li.append(a);
+		$("#roomClashesList").append(li);
+	});
+
+	$("#roomClashes").toggleClass("d-none", rooms.length == 0);
+}
+
+/*
+ * show_substitutes lists the substitutes offered for each clashing item, with
+ * a button to swap each in.
+ */
+function show_substitutes(clashes)
+{
+	let seen = {};
+
+	$("#substitutesList").empty();
+	clashes.forEach((c) => {
+		if (seen[c.equipment_id] || !c.substitutes || c.substitutes.length == 0) {
+			return;
+		}
+		seen[c.equipment_id] = true;
+
+		let li = $("<li></li>").text("Instead of " + c.equipment_name + ": ");
+		c.substitutes.forEach((s) => {
+			let btn = $('<button type="button" class="btn btn-sm btn-outline-primary ms-1"></button>')
+				.text(s.name + " (" + s.available + " free)")
+				.on("click", () => use_substitute(c.equipment_id, s.id));
+			li.append(btn);
+		});
+		$("#substitutesList").append(li);
+	});
+
+	$("#substitutes").toggleClass("d-none", $.isEmptyObject(seen));
+}
+
+/*
+ * use_substitute replaces the item with ID from with that with ID to, in the
+ * same quantity, both for clash detection and in every booking form. Clashes
+ * are then checked again.
+ */
+function use_substitute(from, to)
+{
+	let added = [];
+	items.forEach((item) => {
+		if (item.ItemID != from || item.Quantity == 0) {
+			return;
+		}
+
+		let dest = items.find((i) => i.ItemID == to && i.Important == item.Important);
+		if (dest) {
+			dest.Quantity += item.Quantity;
+		} else {
+			added.push({ItemID: to, Quantity: item.Quantity, Important: item.Important});
+		}
+		item.Quantity = 0;
+	});
+	items.push(...added);
+
+	$("form").each(function() {
+		let form = $(this);
+		["qty_", "eqty_"].forEach((prefix) => {
+			let src = form.find('input[name="' + prefix + from + '"]');
+			if (src.length == 0) {
+				return;
+			}
+
+			let qty = parseInt(src.val());
+			let dest = form.find('input[name="' + prefix + to + '"]');
+			if (dest.length == 0) {
+				dest = $('<input type="hidden" value="0">').attr("name", prefix + to);
+				form.append(dest);
+			}
+
+			dest.val(parseInt(dest.val()) + qty);
+			src.val(0);
+		});
+	});
+
+	$("#clashesModal").modal("hide");
+	$(submitbtn).trigger("click");
+}
+
+/*
+ * join_waitlist submits the booking, asking for it to be waitlisted until
+ * enough equipment is free.
+ */
+function join_waitlist()
+{
+	let form = $(submitbtn).closest("form");
+	if (form.find('input[name="waitlist"]').length == 0) {
+		form.append('<input type="hidden" name="waitlist" value="yes">');
+	}
+
+	end_clashes();
+}
+
 /*
  * end_clashes ends the clash menu and submits the booking
  */
@@ -67,6 +263,51 @@ function end_clashes()
 	$(submitbtn).closest("form").submit();
 }
 
+/*
+ * check_clashes checks for equipment clashes and then room clashes for the
+ * period given by query, before showing any which are found.
+ */
+function check_clashes(query, location)
+{
+	var req = new XMLHttpRequest();
+	req.open("GET", format_items(clashes_endpoint + query), true);
+	req.onreadystatechange = function() {
+		if (this.readyState == 4) {
+			if (this.status == 200) {
+				let dat = JSON.parse(this.responseText);
+
+				check_room(query, location, dat);
+			}
+		}
+	}
+	req.send();
+}
+
+/*
+ * check_room checks for other bookings in location over the period given by
+ * query, then shows them along with the equipment clashes already found.
+ * Unknown rooms are reported without submitting the booking.
+ */
+function check_room(query, location, clashes)
+{
+	var req = new XMLHttpRequest();
+	req.open("GET", room_clashes_endpoint + query + "&location=" + encodeURIComponent(location), true);
+	req.onreadystatechange = function() {
+		if (this.readyState == 4) {
+			if (this.status == 200) {
+				let dat = JSON.parse(this.responseText);
+
+				show_clashes(clashes, dat);
+			} else if (this.status == 400) {
+				let dat = JSON.parse(this.responseText);
+
+				alert(dat.message + ". Please choose one of the listed rooms.");
+			}
+		}
+	}
+	req.send();
+}
+
 /*
  * format_items returns the URI encoded items data with base as the base URL to append to
  */
@@ -94,20 +335,9 @@ function validate_manual(ev)
 	let stime = $("#stime-input")[0].value;
 	let etime = $("#etime-input")[0].value;
 
-	let uri = format_items(clashes_endpoint + "?date="+encodeURIComponent(date) + "&start_time="+encodeURIComponent(stime) + "&end_time="+encodeURIComponent(etime) + "&manual=true");
+	let location = $("#location-input").val();
 
-	var req = new XMLHttpRequest();
-	req.open("GET", uri, true);
-	req.onreadystatechange = function() {
-		if (this.readyState == 4) {
-			if (this.status == 200) {
-				let dat = JSON.parse(this.responseText);
-
-				show_clashes(dat);
-			}
-		}
-	}
-	req.send();
+	check_clashes("?date="+encodeURIComponent(date) + "&start_time="+encodeURIComponent(stime) + "&end_time="+encodeURIComponent(etime) + "&manual=true", location);
 }
 
 function validate_timetable(ev, day, start, end)
@@ -118,20 +348,10 @@ function validate_timetable(ev, day, start, end)
 	let weekinput = $(ev.target).closest(".week_commencing_input");
 	let week = weekinput[0].value;
 
-	/* note lack of manual query parameter */
-	let uri = format_items(clashes_endpoint + "?week_commencing="+week + "&day="+day + "&start_time="+start + "&end_time="+end);
-	var req = new XMLHttpRequest();
-	req.open("GET", uri, true);
-	req.onreadystatechange = function() {
-		if (this.readyState == 4) {
-			if (this.status == 200) {
-				let dat = JSON.parse(this.responseText);
+	let location = $(ev.target).closest("form").find('input[name="location"]').val();
 
-				show_clashes(dat);
-			}
-		}
-	}
-	req.send();
+	/* note lack of manual query parameter */
+	check_clashes("?week_commencing="+week + "&day="+day + "&start_time="+start + "&end_time="+end, location);
 }
 
 function testmodal()
@@ -148,5 +368,5 @@ function testmodal()
 			"booking_starts": "09:15:00",
 			"booking_ends": "10:00:00",
 		},
-	]);
+	], []);
 }
@@ -102,13 +102,18 @@ function timeHover(ev)
 {
 	var dt = ev.srcElement.innerText;
 	var tm = dt.split(" ")[0];
+	var date = $(ev.srcElement).attr("data-date");
 
 	if ($(ev.srcElement).is('[period-fetched]')) {
 		return;
 	}
 
 	var req = new XMLHttpRequest();
-	req.open("GET", period_endpoint + "?time=" + encodeURI(tm), true);
+	var query = "?time=" + encodeURI(tm);
+	if (date) {
+		query += "&date=" + encodeURIComponent(date);
+	}
+	req.open("GET", period_endpoint + query, true);
 	req.onreadystatechange = function() {
 		if (this.readyState == 4) {
 			if (this.status == 200) {
@@ -16,6 +16,9 @@ function serialize_json(s)
 	var textareas = $(s + " textarea");
 	inputs.push(...textareas);
 
+	var selects = $(s + " select");
+	inputs.push(...selects);
+
 	for (var i = 0; i < inputs.length; i++) {
 		var value;
 		switch (inputs[i].type) {
@@ -0,0 +1,48 @@
+/*
+ * scan.js -- camera barcode scanning for item labels
+ * Copyright (C) Ethan Marshall 2023
+ * Part of A-Level Computing 2024
+ */
+
+var scanning = false;
+
+async function start_scan()
+{
+	if (scanning)
+		return;
+
+	const detector = new BarcodeDetector({ formats: ["code_128"] });
+	const video = document.getElementById("scanVideo");
+
+	try {
+		video.srcObject = await navigator.mediaDevices.getUserMedia({
+			video: { facingMode: "environment" },
+		});
+	} catch (e) {
+		$("#scanFailure").removeClass("d-none");
+		return;
+	}
+
+	scanning = true;
+	$("#scanVideo").removeClass("d-none");
+	await video.play();
+
+	const scan = async function() {
+		const codes = await detector.detect(video);
+		if (codes.length > 0) {
+			video.srcObject.getTracks().forEach((t) => t.stop());
+
+			$("#code").val(codes[0].rawValue);
+			$("#scanForm").submit();
+			return;
+		}
+
+		requestAnimationFrame(scan);
+	};
+	requestAnimationFrame(scan);
+}
+
+$(document).ready(function() {
+	if ("BarcodeDetector" in window && navigator.mediaDevices)
+		$("#cameraBtn").removeClass("d-none");
+});
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSafetyDataSheetSize is the largest safety data sheet which may be
+// uploaded, in bytes.
+const maxSafetyDataSheetSize = 10 << 20
+
+// pdfMagic is the header which every PDF file begins with.
+var pdfMagic = []byte("%PDF-")
+
+// sdsPath returns the path on disk to the safety data sheet with the given
+// file name.
+func sdsPath(name string) string {
+	return filepath.Join(PathUploads, "sds", name)
+}
+
+// handleItemUploadSDS is the handler for POST "/inventory/item/[ID]/sds".
+//
+// Accepts a multipart upload of a PDF safety data sheet for the given item,
+// replacing any previously uploaded sheet.
+func handleItemUploadSDS(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	fh, err := c.FormFile("sds")
+	if err != nil {
+		c.String(http.StatusBadRequest, "Missing Safety Data Sheet")
+		return
+	}
+
+	if fh.Size > maxSafetyDataSheetSize {
+		c.String(http.StatusRequestEntityTooLarge, "Safety data sheets may be no larger than %d MiB", maxSafetyDataSheetSize>>20)
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	defer f.Close()
+
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	if !bytes.HasPrefix(buf, pdfMagic) {
+		c.String(http.StatusBadRequest, "Safety data sheets must be PDF documents")
+		return
+	}
+
+	name := fmt.Sprint(item.ID, ".pdf")
+	if err := os.MkdirAll(filepath.Dir(sdsPath(name)), 0o755); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	if err := os.WriteFile(sdsPath(name), buf, 0o644); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	after := item
+	after.SafetyDataSheet = name
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Update("safety_data_sheet", name).Error; err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, s.UserID, data.AuditUpdate, "Safety data sheet uploaded", item, after)
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID))
+}
+
+// handleItemSDS is the handler for "/sds/[ID]".
+//
+// Serves the safety data sheet of the given item. Available to any signed in
+// user, as teachers must be able to read the sheets for their bookings.
+func handleItemSDS(c *gin.Context) {
+	db := tenantDB(c)
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	if !item.HasSafetyDataSheet() {
+		c.String(http.StatusNotFound, "No Safety Data Sheet")
+		return
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.File(sdsPath(filepath.Base(item.SafetyDataSheet)))
+}
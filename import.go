@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize is the largest inventory CSV file which may be imported, in
+// bytes.
+const maxImportSize = 1 << 20
+
+// importData is the template data for the import page, which is shared
+// between the upload form and the preview.
+type importData struct {
+	DashboardData
+	Columns []string
+
+	Rows     []data.ImportRow
+	Created  int
+	Updated  int
+	Invalid  int
+	CSV      string
+	Imported bool
+	Error    string
+}
+
+// handleInventoryExport is the handler for "/inventory/export".
+//
+// Returns the whole inventory as a CSV file, in the same format as is
+// accepted for import.
+func handleInventoryExport(c *gin.Context) {
+	db := tenantDB(c)
+	eq, err := data.GetEquipment(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := data.WriteItemCSV(&buf, eq); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	name := fmt.Sprint("inventory-", time.Now().Format(dateFormat), ".csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// handleInventoryImport is the handler for "/inventory/import".
+//
+// Shows a form for uploading an inventory CSV file.
+func handleInventoryImport(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := importData{DashboardData: ddat, Columns: data.ItemCSVColumns}
+
+	c.HTML(http.StatusOK, "import.gohtml", dat)
+}
+
+// handleInventoryDoImport is the handler for POST "/inventory/import".
+//
+// Parses an uploaded inventory CSV file and shows a preview of the changes
+// which would be made, along with any errors in each row. The preview is a
+// dry run; nothing is saved unless the "commit" field is set, in which case
+// the CSV from the preview is imported if it has no errors.
+func handleInventoryDoImport(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := importData{DashboardData: ddat, Columns: data.ItemCSVColumns}
+
+	// A fresh upload, or the CSV carried over from a previous preview.
+	if fh, err := c.FormFile("csv"); err == nil {
+		if fh.Size > maxImportSize {
+			c.String(http.StatusRequestEntityTooLarge, "Import files may be no larger than %d MiB", maxImportSize>>20)
+			return
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		defer f.Close()
+
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		dat.CSV = string(buf)
+	} else {
+		dat.CSV = c.PostForm("csv_data")
+	}
+
+	if len(dat.CSV) > maxImportSize {
+		c.String(http.StatusRequestEntityTooLarge, "Import files may be no larger than %d MiB", maxImportSize>>20)
+		return
+	}
+
+	dat.Rows, err = data.ParseItemCSV(db, strings.NewReader(dat.CSV))
+	if err != nil {
+		if errors.Is(err, data.ErrCSVHeader) {
+			dat.Error = err.Error()
+			c.HTML(http.StatusOK, "import.gohtml", dat)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	for _, r := range dat.Rows {
+		switch {
+		case !r.Valid():
+			dat.Invalid++
+		case r.Update:
+			dat.Updated++
+		default:
+			dat.Created++
+		}
+	}
+
+	if c.PostForm("commit") != "" && dat.Invalid == 0 && len(dat.Rows) > 0 {
+		if err := data.ImportItems(db, dat.Rows, s.UserID); err != nil {
+			internalError(c, err)
+			return
+		}
+		dat.Imported = true
+	}
+
+	c.HTML(http.StatusOK, "import.gohtml", dat)
+}
@@ -9,8 +9,12 @@ import (
 
 	"github.com/ejv2/prepper/data"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// itemAuditLimit is the number of recent changes shown on an item's page.
+const itemAuditLimit = 20
+
 // AnnotatedInventory contains an item from the database, as well as various
 // pieces of information about it which may be useful for client pages.
 type AnnotatedItem struct {
@@ -86,10 +90,64 @@ func NewAnnotatedItem(i data.EquipmentItem) (AnnotatedItem, error) {
 	return NewAnnotatedItemTime(i, nil, nil)
 }
 
+// pageWindow is the number of pages either side of the current page which are
+// linked to directly.
+const pageWindow = 3
+
+// inventoryQuery is a search of the inventory, with helpers for building links
+// which change a single filter.
+type inventoryQuery struct {
+	data.ItemQuery
+
+	// Prep is the prep room filter as given, so that it is kept by links.
+	Prep string
+}
+
+// With returns the query string for this search with the given parameter set
+// to value, or removed if value is blank. The page is not kept, so changing a
+// filter returns to the first page.
+func (q inventoryQuery) With(key, value string) string {
+	v := url.Values{}
+	set := func(k, val string) {
+		if val != "" {
+			v.Set(k, val)
+		}
+	}
+
+	set("q", q.Text)
+	set("category", string(q.Category))
+	set("tag", q.Tag)
+	set("hazard", q.Hazard)
+	set("available", q.Available)
+	set("stock", q.Stock)
+	set("prep", q.Prep)
+
+	v.Del(key)
+	set(key, value)
+
+	return "?" + v.Encode()
+}
+
+// Page returns the query string for the given page of this search.
+func (q inventoryQuery) Page(n int) string {
+	return q.With("page", strconv.Itoa(n))
+}
+
+// pageNumbers returns the page numbers linked to from the given page.
+func pageNumbers(page, pages int) []int {
+	n := make([]int, 0, 2*pageWindow+1)
+	for i := max(1, page-pageWindow); i <= min(pages, page+pageWindow); i++ {
+		n = append(n, i)
+	}
+
+	return n
+}
+
 // handleInventory is the handler for "/inventory/".
 func handleInventory(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -97,7 +155,25 @@ func handleInventory(c *gin.Context) {
 		return
 	}
 
-	e, err := data.GetEquipment(Database)
+	q := inventoryQuery{Prep: c.Query("prep")}
+	if err := c.BindQuery(&q.ItemQuery); err != nil {
+		c.String(http.StatusBadRequest, "Bad Search")
+		return
+	}
+
+	prep, ok := prepRoomFilter(c, ddat.User)
+	if !ok {
+		return
+	}
+	q.PrepRoom = prep.ID
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	res, err := data.SearchEquipment(db, q.ItemQuery)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -110,9 +186,19 @@ func handleInventory(c *gin.Context) {
 		Inventory   []AnnotatedItem
 		Deleted     bool
 		DeletedName string
-	}{ddat, make([]AnnotatedItem, 0, len(e)), del, dname}
-
-	for _, eq := range e {
+		Query       inventoryQuery
+		Results     data.ItemResults
+		PageNumbers []int
+		Categories  []data.ItemCategory
+		Pictograms  []data.Pictogram
+		LowStock    int
+		Prep        prepFilter
+		Preps       []data.PrepRoom
+	}{ddat, make([]AnnotatedItem, 0, len(res.Items)), del, dname,
+		q, res, pageNumbers(res.Page, res.Pages), data.ItemCategories, data.Pictograms, data.LowStockLevel,
+		prep, preps}
+
+	for _, eq := range res.Items {
 		i, err := NewAnnotatedItem(eq)
 		if err != nil {
 			internalError(c, err)
@@ -131,6 +217,7 @@ func handleInventory(c *gin.Context) {
 func handleItem(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -146,7 +233,7 @@ func handleItem(c *gin.Context) {
 		return
 	}
 
-	item, err := data.GetEquipmentItem(Database, id)
+	item, err := data.GetEquipmentItem(db, id)
 	if err != nil {
 		c.String(http.StatusNotFound, "Item Not Found")
 		return
@@ -158,17 +245,113 @@ func handleItem(c *gin.Context) {
 		return
 	}
 
+	bt, err := data.GetItemBatches(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	svc, err := data.GetServiceRecords(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Service records are sorted with the most recent first.
+	status := data.ServiceStatus{Item: item}
+	if len(svc) > 0 {
+		status.Latest = &svc[0]
+	}
+
+	us, err := data.GetItemUnits(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	locs, err := data.GetStorageLocations(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	audit, err := data.GetItemAudits(db, data.AuditFilter{ItemID: item.ID, Limit: itemAuditLimit})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	subs, err := data.GetSubstitutes(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	eq, err := data.GetEquipment(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	cat, err := data.GetItemCatalogue(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	sups, err := data.GetSuppliers(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
 	dat := struct {
 		DashboardData
-		Item AnnotatedItem
-	}{ddat, aitem}
+		Item          AnnotatedItem
+		Batches       []data.ChemicalBatch
+		ExpiryWarning time.Duration
+		Scanned       bool
+		Adjusted      string
+		Services      []data.ServiceRecord
+		ServiceStatus data.ServiceStatus
+		ServiceKinds  []data.ServiceKind
+		Units         []data.ServiceStatus
+		Locations     data.Locations
+		Conditions    []data.UnitCondition
+		Audit         []data.ItemAudit
+		Categories    []data.ItemCategory
+		Substitutes   []data.ItemSubstitute
+		Equipment     []data.EquipmentItem
+		SubstituteErr string
+		Catalogue     []data.CatalogueEntry
+		Suppliers     []data.Supplier
+		Preps         []data.PrepRoom
+		Prep          prepFilter
+		Transferred   string
+		PrepError     string
+	}{ddat, aitem, bt, batchExpiryWarning, c.Request.URL.Query().Has("scanned"), c.Query("adjusted"),
+		svc, status, serviceKinds, unitStatuses(item, us, svc), locs.Sorted(), unitConditions, audit, data.ItemCategories,
+		subs, eq, c.Query("substitute_error"), cat, sups,
+		preps, prepFilter{item.PrepRoomID}, c.Query("transferred"), c.Query("prep_error")}
 
 	c.HTML(http.StatusOK, "item.gohtml", dat)
 }
 
+// handleItemLocate is the handler for "/inventory/item/[ID]/locate".
+//
+// Shows where stock of the item is stored and which bookings are currently
+// using it. Given a "want" query parameter, the locations from which that
+// quantity may be collected are highlighted.
 func handleItemLocate(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -184,7 +367,7 @@ func handleItemLocate(c *gin.Context) {
 		return
 	}
 
-	item, err := data.GetEquipmentItem(Database, id)
+	item, err := data.GetEquipmentItem(db, id)
 	if err != nil {
 		c.String(http.StatusNotFound, "Item Not Found")
 		return
@@ -196,12 +379,59 @@ func handleItemLocate(c *gin.Context) {
 		return
 	}
 
+	stock, err := data.GetItemStock(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	locs, err := data.GetStorageLocations(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	moves, err := data.GetStockMoves(db, item.ID, 10)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Unassigned stock is that which is not recorded at any location.
+	unassigned := uint(0)
+	if located := data.LocatedQuantity(stock); located < item.Quantity {
+		unassigned = item.Quantity - located
+	}
+
+	// If asked for a quantity, work out where to find it.
+	want := uint(0)
+	if swant := c.Query("want"); swant != "" {
+		lwant, err := strconv.ParseUint(swant, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Invalid Quantity")
+			return
+		}
+		want = uint(lwant)
+	}
+	picks, enough := data.PickStock(stock, want)
+
 	dat := struct {
 		DashboardData
 		Item         AnnotatedItem
 		Bookings     []data.Booking
 		PastBookings []data.Booking
-	}{ddat, aitem, []data.Booking{}, []data.Booking{}}
+		Stock        []data.ItemStock
+		Unassigned   uint
+		Locations    data.Locations
+		Moves        []data.StockMove
+		Want         uint
+		Picks        []data.ItemStock
+		Enough       bool
+		InUse        bool
+		Error        string
+	}{ddat, aitem, []data.Booking{}, []data.Booking{},
+		stock, unassigned, locs.Sorted(), moves, want, picks, enough,
+		aitem.Balance < int(want), c.Query("error")}
 
 	dayStart := time.Now().Local().Truncate(24 * time.Hour)
 	minuteStart := time.Now().Local().Truncate(time.Minute)
@@ -247,6 +477,7 @@ func handleNewItem(c *gin.Context) {
 func handleInventoryReport(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -254,7 +485,7 @@ func handleInventoryReport(c *gin.Context) {
 		return
 	}
 
-	e, err := data.GetEquipment(Database)
+	e, err := data.GetEquipment(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -293,6 +524,7 @@ func handleInventoryReport(c *gin.Context) {
 func handleInventoryLocate(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	siid, ok := c.GetQuery("item")
 	if ok {
@@ -302,13 +534,18 @@ func handleInventoryLocate(c *gin.Context) {
 			return
 		}
 
-		_, err = data.GetEquipmentItem(Database, uint(iid))
+		_, err = data.GetEquipmentItem(db, uint(iid))
 		if err != nil {
 			c.Redirect(http.StatusFound, "/inventory/locate?error")
 			return
 		}
 
-		c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", iid, "/locate"))
+		dest := fmt.Sprint("/inventory/item/", iid, "/locate")
+		if want := c.Query("want"); want != "" {
+			dest += "?want=" + url.QueryEscape(want)
+		}
+
+		c.Redirect(http.StatusFound, dest)
 		return
 	}
 
@@ -320,7 +557,7 @@ func handleInventoryLocate(c *gin.Context) {
 		return
 	}
 
-	e, err := data.GetEquipment(Database)
+	e, err := data.GetEquipment(db)
 	if err != nil {
 		internalError(c, err)
 		return
@@ -352,6 +589,7 @@ func handleInventoryLocate(c *gin.Context) {
 func handleItemDelete(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -372,14 +610,30 @@ func handleItemDelete(c *gin.Context) {
 		return
 	}
 
-	it, err := data.GetEquipmentItem(Database, id)
+	it, err := data.GetEquipmentItem(db, id)
 	if err != nil {
 		c.String(http.StatusNotFound, "Item Not Found")
 		return
 	}
 
-	res := Database.Delete(&it)
-	if res.Error != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&it).Error; err != nil {
+			return err
+		}
+		// Kits should not go on offering an item which no longer exists.
+		if err := tx.Where("item_id = ?", it.ID).Delete(&data.KitItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("item_id = ? OR substitute_id = ?", it.ID, it.ID).Delete(&data.ItemSubstitute{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("item_id = ?", it.ID).Delete(&data.CatalogueEntry{}).Error; err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, s.UserID, data.AuditDelete, "", it, data.EquipmentItem{})
+	})
+	if err != nil {
 		internalError(c, err)
 		return
 	}
@@ -27,6 +27,12 @@ type isamsResponse struct {
 		}
 	}
 
+	SchoolManager struct {
+		Terms struct {
+			Term TermCollection
+		}
+	}
+
 	TimetableManager struct {
 		PublishedTimetables struct {
 			Timetable Timetable
@@ -157,6 +163,54 @@ func (u *User) Timetable(i *ISAMS) *UserTimetable {
 	return u.timetable
 }
 
+// A Term is a term of the school year, as set up in iSAMS.
+type Term struct {
+	ID         ID `json:"@Id"`
+	Name       string
+	StartDate  Date
+	FinishDate Date
+}
+
+// A TermCollection is the list of terms set up in iSAMS.
+type TermCollection []Term
+
+// UnmarshalJSON overrides the unmarshaling routine for this struct, as iSAMS
+// likes to pick and choose whether this is a struct or an array.
+func (c *TermCollection) UnmarshalJSON(data []byte) error {
+	arr := make([]Term, 1)
+
+	err := json.Unmarshal(data, &arr)
+	if err != nil {
+		// Assume an object from now on
+		err = json.Unmarshal(data, &arr[0])
+		if err != nil {
+			return fmt.Errorf("unmarshal isams term collection: %w", err)
+		}
+	}
+
+	*c = TermCollection(arr)
+	return nil
+}
+
+// DataDate returns an equivalent data.SchoolDate for this term. As iSAMS
+// dates often lack a time zone, the calendar days are kept in local time.
+func (t Term) DataDate() data.SchoolDate {
+	local := func(d Date) time.Time {
+		y, m, dd := d.Time().Date()
+		return time.Date(y, m, dd, 0, 0, 0, 0, time.Local)
+	}
+
+	id := uint64(t.ID)
+	return data.SchoolDate{
+		Name:  t.Name,
+		Kind:  data.DateTerm,
+		Start: local(t.StartDate),
+		End:   local(t.FinishDate),
+
+		IsamsID: &id,
+	}
+}
+
 // A Classroom is a possible location for a lesson. Every building on iSAMS has
 // zero or more classrooms.
 type Classroom struct {
@@ -0,0 +1,56 @@
+package isams
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTermCollection(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    []string
+		wantErr bool
+	}{
+		{"object", `{"@Id": "1", "Name": "Autumn", "StartDate": "2026-09-03T00:00:00", "FinishDate": "2026-12-16T00:00:00"}`, []string{"Autumn"}, false},
+		{"array", `[{"@Id": "1", "Name": "Autumn"}, {"@Id": "2", "Name": "Spring"}]`, []string{"Autumn", "Spring"}, false},
+		{"invalid", `"Autumn"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TermCollection
+			err := json.Unmarshal([]byte(tt.arg), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TermCollection.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("TermCollection.UnmarshalJSON() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i] {
+					t.Errorf("TermCollection.UnmarshalJSON()[%d] = %v, want %v", i, got[i].Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTermDataDate(t *testing.T) {
+	var tm Term
+	if err := json.Unmarshal([]byte(`{"@Id": "7", "Name": "Autumn", "StartDate": "2026-09-03T00:00:00", "FinishDate": "2026-12-16T00:00:00"}`), &tm); err != nil {
+		t.Fatal(err)
+	}
+
+	d := tm.DataDate()
+	if d.IsamsID == nil || *d.IsamsID != 7 {
+		t.Errorf("DataDate() IsamsID = %v, want 7", d.IsamsID)
+	}
+	if want := time.Date(2026, 9, 3, 0, 0, 0, 0, time.Local); !d.Start.Equal(want) {
+		t.Errorf("DataDate() Start = %v, want %v", d.Start, want)
+	}
+	if want := time.Date(2026, 12, 16, 0, 0, 0, 0, time.Local); !d.End.Equal(want) {
+		t.Errorf("DataDate() End = %v, want %v", d.End, want)
+	}
+}
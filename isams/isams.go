@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -46,6 +47,7 @@ type ISAMS struct {
 
 	Users []User
 	Rooms []Classroom
+	Terms []Term
 
 	CurrentTimetable Timetable
 	weeks            []TimetableWeek
@@ -95,6 +97,10 @@ func (i *ISAMS) prepopulate() error {
 	i.Users = resp.HRManager.CurrentStaff.StaffMember
 	i.CurrentTimetable = resp.TimetableManager.PublishedTimetables.Timetable
 	i.weeks = resp.TimetableManager.Structure.Week
+	sort.SliceStable(i.weeks, func(a, b int) bool {
+		return i.weeks[a].Ordinal < i.weeks[b].Ordinal
+	})
+	i.Terms = resp.SchoolManager.Terms.Term
 
 	// Wild guess at how many rooms we might have
 	// Maybe about 5 classrooms per building?
@@ -190,6 +196,17 @@ func (i *ISAMS) Period(id uint64) (Period, error) {
 	return Period{}, ErrNotFound
 }
 
+// WeekNames returns the names of each week of the timetable, in order of
+// rotation.
+func (i *ISAMS) WeekNames() []string {
+	names := make([]string, len(i.weeks))
+	for j, w := range i.weeks {
+		names[j] = w.Name
+	}
+
+	return names
+}
+
 // SchedulePeriod looks up a period from the associated period of a Schedule
 // entry.
 func (i *ISAMS) SchedulePeriod(s Schedule) (Period, error) {
@@ -207,6 +224,7 @@ func New(domain, key string) (*ISAMS, error) {
 
 		[]User{},
 		[]Classroom{},
+		[]Term{},
 		Timetable{},
 		[]TimetableWeek{},
 	}
@@ -10,7 +10,7 @@ import "time"
 //
 // The structure of UserTimetable is as follows:
 //
-//	Timetable Week (no particular order)
+//	Timetable Week (ordered by rotation)
 //	  |--> Timetable Day (ordered by day of the week)
 //	        |--> Period (ordered by time of day)
 //
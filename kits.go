@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+)
+
+// kitFromParam looks up the kit given as the "id" URI parameter. If it cannot
+// be found, a response is written and false is returned.
+func kitFromParam(c *gin.Context) (data.Kit, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Kit ID")
+		return data.Kit{}, false
+	}
+
+	k, err := data.GetKit(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchKit) {
+			c.String(http.StatusNotFound, "Kit Not Found")
+			return k, false
+		}
+
+		internalError(c, err)
+		return k, false
+	}
+
+	return k, true
+}
+
+// handleKits is the handler for "/inventory/kits".
+//
+// Shows every kit and a form for creating new kits.
+func handleKits(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	kits, err := data.GetKits(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Kits        []data.Kit
+		Deleted     bool
+		DeletedName string
+		Error       string
+	}{ddat, kits, c.Request.URL.Query().Has("deleted"), c.Query("deleted"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "kits.gohtml", dat)
+}
+
+// handleKitNew is the handler for POST "/inventory/kits".
+func handleKitNew(c *gin.Context) {
+	db := tenantDB(c)
+	frm := struct {
+		Name        string `form:"name"`
+		Description string `form:"description"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	k, err := data.NewKit(db, frm.Name, frm.Description)
+	if err != nil {
+		if errors.Is(err, data.ErrKitName) {
+			c.Redirect(http.StatusFound, "/inventory/kits?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/kit/", k.ID))
+}
+
+// handleKit is the handler for "/inventory/kit/[ID]".
+//
+// Shows the editor for a kit's details and components.
+func handleKit(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	k, ok := kitFromParam(c)
+	if !ok {
+		return
+	}
+
+	eq, err := data.GetEquipment(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Kit       data.Kit
+		Equipment []data.EquipmentItem
+		Saved     bool
+		Error     string
+	}{ddat, k, eq, c.Request.URL.Query().Has("saved"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "kit.gohtml", dat)
+}
+
+// handleKitEdit is the handler for POST "/inventory/kit/[ID]/edit".
+//
+// Component quantities are given as "qty_[ITEM ID]", where a quantity of zero
+// removes the component. A new component may be added using "add_item" and
+// "add_quantity".
+func handleKitEdit(c *gin.Context) {
+	db := tenantDB(c)
+	k, ok := kitFromParam(c)
+	if !ok {
+		return
+	}
+
+	frm := struct {
+		Name        string `form:"name"`
+		Description string `form:"description"`
+		AddItem     string `form:"add_item"`
+		AddQuantity uint   `form:"add_quantity"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	set, err := NewPostItemInformation(db, c.Request)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Component Quantities: %s", err.Error())
+		return
+	}
+
+	add, err := optionalID(frm.AddItem)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Item ID")
+		return
+	}
+	if add != nil && frm.AddQuantity > 0 {
+		if _, err := data.GetEquipmentItem(db, *add); err != nil {
+			c.String(http.StatusNotFound, "Item Not Found")
+			return
+		}
+
+		set.add(data.EquipmentSet{ItemID: *add, Quantity: frm.AddQuantity, Important: true})
+	}
+
+	k.Name, k.Description = frm.Name, frm.Description
+	k.Items = make([]data.KitItem, 0, len(set))
+	for _, s := range set.Totals() {
+		k.Items = append(k.Items, data.KitItem{ItemID: s.ItemID, Quantity: s.Quantity})
+	}
+
+	if err := data.SaveKit(db, k); err != nil {
+		if errors.Is(err, data.ErrKitName) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/inventory/kit/", k.ID, "?error=", url.QueryEscape(err.Error())))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/kit/", k.ID, "?saved"))
+}
+
+// handleKitDelete is the handler for "/inventory/kit/[ID]/delete".
+func handleKitDelete(c *gin.Context) {
+	db := tenantDB(c)
+	k, ok := kitFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteKit(db, k.ID); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/kits?deleted="+url.QueryEscape(k.Name))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/labels"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxLabelCopies is the most copies of each label which may be printed at
+// once, to avoid accidentally generating enormous documents.
+const maxLabelCopies = 100
+
+// labelChoice is an item which may be selected for printing on the label
+// printing page.
+type labelChoice struct {
+	data.EquipmentItem
+	Selected bool
+}
+
+// handleLabels is the handler for "/inventory/labels".
+//
+// Shows a form for choosing items and a label format to print. Items passed in
+// the "item" query parameter are selected beforehand.
+func handleLabels(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	eq, err := data.GetEquipment(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range c.QueryArray("item") {
+		selected[id] = true
+	}
+
+	items := make([]labelChoice, len(eq))
+	for i, e := range eq {
+		items[i] = labelChoice{e, selected[strconv.FormatUint(uint64(e.ID), 10)]}
+	}
+
+	dat := struct {
+		DashboardData
+		Items   []labelChoice
+		Formats []labels.Format
+	}{ddat, items, labels.Formats}
+
+	c.HTML(http.StatusOK, "labels.gohtml", dat)
+}
+
+// handleLabelsPrint is the handler for "/inventory/labels/print".
+//
+// Returns a PDF document of label sheets for the selected items, in the
+// selected format.
+func handleLabelsPrint(c *gin.Context) {
+	db := tenantDB(c)
+	frm := struct {
+		Items  []uint `form:"item" binding:"required"`
+		Format string `form:"format" binding:"required"`
+		Copies int    `form:"copies"`
+		Skip   int    `form:"skip"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	f, ok := labels.FindFormat(frm.Format)
+	if !ok {
+		c.String(http.StatusBadRequest, "Unknown Label Format")
+		return
+	}
+
+	if frm.Copies <= 0 {
+		frm.Copies = 1
+	}
+	if frm.Copies > maxLabelCopies {
+		c.String(http.StatusBadRequest, "No more than %d copies may be printed at once", maxLabelCopies)
+		return
+	}
+
+	ls := make([]labels.Label, 0, len(frm.Items)*frm.Copies)
+	for _, id := range frm.Items {
+		item, err := data.GetEquipmentItem(db, id)
+		if err != nil {
+			c.String(http.StatusNotFound, "Item Not Found")
+			return
+		}
+
+		for i := 0; i < frm.Copies; i++ {
+			ls = append(ls, labels.Label{Title: item.Name, Code: item.LabelCode()})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := labels.WriteSheets(&buf, f, ls, frm.Skip); err != nil {
+		if errors.Is(err, labels.ErrBadSkip) || errors.Is(err, labels.ErrLabelSmall) {
+			c.String(http.StatusBadRequest, "Cannot print labels: %s", err.Error())
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"labels-%s.pdf\"", f.Name))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// handleScan is the handler for "/inventory/scan".
+//
+// If a code is given, it is resolved to an item and the user is sent to the
+// item page, ready to adjust its stock. Otherwise, a page is shown for
+// scanning or typing in a label.
+func handleScan(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	errmsg := ""
+	if code, ok := c.GetQuery("code"); ok {
+		id, err := data.ParseLabelCode(code)
+		if err == nil {
+			_, err = data.GetEquipmentItem(db, id)
+		}
+
+		if err == nil {
+			c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", id, "?scanned#adjust"))
+			return
+		}
+
+		errmsg = fmt.Sprintf("%q is not the label of any item in the inventory", code)
+	}
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Error string
+	}{ddat, errmsg}
+
+	c.HTML(http.StatusOK, "scan.gohtml", dat)
+}
+
+// handleItemAdjust is the handler for POST "/inventory/item/[ID]/adjust".
+//
+// Adds to or removes from the stock level of an item by the given amount,
+// such as when stock is counted after being scanned.
+func handleItemAdjust(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := struct {
+		By int `form:"adjust_by" binding:"required"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	qty := int(item.Quantity) + frm.By
+	if qty < 0 {
+		c.String(http.StatusBadRequest, "Cannot remove %d %s, as only %d are in stock", -frm.By, item.Name, item.Quantity)
+		return
+	}
+
+	after := item
+	after.Quantity = uint(qty)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Update("quantity", qty).Error; err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, s.UserID, data.AuditUpdate, "Stock adjustment", item, after)
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "?adjusted=", frm.By, "#adjust"))
+}
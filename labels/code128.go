@@ -0,0 +1,81 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code128 symbol values used outside of the data itself.
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+// Code128 encoding errors.
+var (
+	ErrEmptyCode     = errors.New("empty barcode")
+	ErrUnencodable   = errors.New("character cannot be encoded")
+	ErrBadCodeLength = errors.New("barcode too long")
+)
+
+// maxCode128Length is the longest string which will be encoded. Anything
+// longer will not scan reliably when printed on a label.
+const maxCode128Length = 48
+
+// code128Patterns holds the widths of the bars and spaces of each Code128
+// symbol, indexed by symbol value. Each pattern begins with a bar. All symbols
+// are eleven modules wide, except for the stop symbol, which is thirteen.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312",
+	"132212", "221213", "221312", "231212", "112232", "122132", "122231", "113222",
+	"123122", "123221", "223211", "221132", "221231", "213212", "223112", "312131",
+	"311222", "321122", "321221", "312212", "322112", "322211", "212123", "212321",
+	"232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121",
+	"313121", "211331", "231131", "213113", "213311", "213131", "311123", "311321",
+	"331121", "312113", "312311", "332111", "314111", "221411", "431111", "111224",
+	"111422", "121124", "121421", "141122", "141221", "112214", "112412", "122114",
+	"122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112",
+	"421211", "212141", "214121", "412121", "111143", "111341", "131141", "114113",
+	"114311", "411113", "411311", "113141", "114131", "311141", "411131", "211412",
+	"211214", "211232", "2331112",
+}
+
+// Code128 encodes s using Code128 code set B, which covers printable ASCII.
+// The result is the width in modules of each alternating bar and space,
+// beginning with a bar, and includes the start symbol, check symbol and stop
+// symbol. Quiet zones are not included.
+func Code128(s string) ([]int, error) {
+	if len(s) == 0 {
+		return nil, ErrEmptyCode
+	}
+	if len(s) > maxCode128Length {
+		return nil, fmt.Errorf("code128 %q: %w", s, ErrBadCodeLength)
+	}
+
+	syms := make([]int, 0, len(s)+3)
+	syms = append(syms, code128StartB)
+
+	check := code128StartB
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return nil, fmt.Errorf("code128 %q: %w: %q", s, ErrUnencodable, s[i])
+		}
+
+		v := int(s[i] - ' ')
+		syms = append(syms, v)
+		check += (i + 1) * v
+	}
+
+	syms = append(syms, check%103, code128Stop)
+
+	widths := make([]int, 0, len(syms)*6+1)
+	for _, sym := range syms {
+		for _, w := range code128Patterns[sym] {
+			widths = append(widths, int(w-'0'))
+		}
+	}
+
+	return widths, nil
+}
@@ -0,0 +1,80 @@
+package labels
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCode128Patterns(t *testing.T) {
+	seen := make(map[string]int, len(code128Patterns))
+	for i, p := range code128Patterns {
+		want := 11
+		if i == code128Stop {
+			want = 13
+		}
+
+		sum, bars := 0, 0
+		for j, w := range p {
+			sum += int(w - '0')
+			if j%2 == 0 {
+				bars += int(w - '0')
+			}
+		}
+
+		if sum != want {
+			t.Errorf("pattern %d (%s) is %d modules, want %d", i, p, sum, want)
+		}
+		if bars%2 != 0 {
+			t.Errorf("pattern %d (%s) has odd bar parity", i, p)
+		}
+		if prev, ok := seen[p]; ok {
+			t.Errorf("pattern %d (%s) duplicates pattern %d", i, p, prev)
+		}
+		seen[p] = i
+	}
+}
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		wantLen int
+		wantErr error
+	}{
+		{"single", "A", 6*3 + 7, nil},
+		{"item", "PREP42", 6*8 + 7, nil},
+		{"empty", "", 0, ErrEmptyCode},
+		{"control", "A\nB", 0, ErrUnencodable},
+		{"unicode", "café", 0, ErrUnencodable},
+		{"long", "0123456789012345678901234567890123456789012345678", 0, ErrBadCodeLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Code128(tt.arg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Code128() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("Code128() = %d elements, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestCode128Check(t *testing.T) {
+	// "PJJ123C" is commonly used as a worked example for code set A, with a
+	// check value of 54. Starting with code set B adds one to this.
+	got, err := Code128("PJJ123C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := got[len(got)-13 : len(got)-7]
+	want := code128Patterns[55]
+	for i := range check {
+		if check[i] != int(want[i]-'0') {
+			t.Fatalf("Code128() check symbol = %v, want %s", check, want)
+		}
+	}
+}
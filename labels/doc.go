@@ -0,0 +1,4 @@
+// Package labels generates printable sheets of barcode labels. Barcodes are
+// encoded using Code128 and laid out onto common adhesive label sheet formats
+// as a PDF document, ready to be printed at actual size.
+package labels
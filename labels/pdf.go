@@ -0,0 +1,126 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ptPerMM is the number of PDF points in a millimetre.
+const ptPerMM = 72 / 25.4
+
+// pdfDocument is a minimal PDF writer, supporting only what is required to
+// draw label sheets: filled rectangles and text in the standard Helvetica
+// font. Object numbers begin at one.
+type pdfDocument struct {
+	width, height float64
+	objs          [][]byte
+	pages         []int
+}
+
+// pdfPage is the content stream of a single page under construction. All
+// coordinates are given in millimetres from the top left of the page.
+type pdfPage struct {
+	height float64
+	buf    bytes.Buffer
+}
+
+const (
+	pdfCatalogObj = 1
+	pdfPagesObj   = 2
+	pdfFontObj    = 3
+)
+
+// newPDFDocument returns a new, empty document with pages of the given size
+// in millimetres.
+func newPDFDocument(width, height float64) *pdfDocument {
+	d := &pdfDocument{width: width, height: height}
+	d.add([]byte("<< /Type /Catalog /Pages 2 0 R >>"))
+	d.add(nil) // Page tree, written once all pages are known.
+	d.add([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"))
+
+	return d
+}
+
+// add appends an object to the document, returning its object number.
+func (d *pdfDocument) add(body []byte) int {
+	d.objs = append(d.objs, body)
+	return len(d.objs)
+}
+
+// newPage returns a new page to be drawn on.
+func (d *pdfDocument) newPage() *pdfPage {
+	return &pdfPage{height: d.height}
+}
+
+// addPage appends a finished page to the document.
+func (d *pdfDocument) addPage(p *pdfPage) {
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.buf.Len(), p.buf.Bytes())
+	content := d.add([]byte(stream))
+
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R /Resources << /Font << /F1 %d 0 R >> >> >>",
+		pdfPagesObj, content, pdfFontObj)
+	d.pages = append(d.pages, d.add([]byte(page)))
+}
+
+// WriteTo writes the complete document to w.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	kids := make([]string, len(d.pages))
+	for i, p := range d.pages {
+		kids[i] = fmt.Sprint(p, " 0 R")
+	}
+	d.objs[pdfPagesObj-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), d.width*ptPerMM, d.height*ptPerMM))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(d.objs))
+	for i, o := range d.objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objs)+1, pdfCatalogObj, xref)
+
+	return buf.WriteTo(w)
+}
+
+// rect draws a filled black rectangle with its top left corner at x, y.
+func (p *pdfPage) rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.buf, "%.3f %.3f %.3f %.3f re f\n",
+		x*ptPerMM, (p.height-y-h)*ptPerMM, w*ptPerMM, h*ptPerMM)
+}
+
+// text draws a single line of text of the given point size with its baseline
+// starting at x, y.
+func (p *pdfPage) text(x, y, size float64, s string) {
+	fmt.Fprintf(&p.buf, "BT /F1 %.1f Tf %.3f %.3f Td (%s) Tj ET\n",
+		size, x*ptPerMM, (p.height-y)*ptPerMM, pdfEscape(s))
+}
+
+// pdfEscape escapes s for use as a PDF literal string. Characters outside of
+// printable ASCII are replaced, as only the standard font encoding is used.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
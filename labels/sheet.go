@@ -0,0 +1,178 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Label sheet errors.
+var (
+	ErrNoLabels   = errors.New("no labels to print")
+	ErrBadSkip    = errors.New("cannot skip a whole sheet or more")
+	ErrLabelSmall = errors.New("label too small for barcode")
+)
+
+// Layout constants, in millimetres unless otherwise stated.
+const (
+	labelPadding   = 2.5
+	labelQuiet     = 10 // Modules of quiet zone either side of the barcode.
+	minModuleWidth = 0.19
+	maxModuleWidth = 0.5
+	maxTitleSize   = 11.0 // Points.
+	codeTextSize   = 7.0  // Points.
+
+	// helveticaAverage is the rough average width of a Helvetica glyph as a
+	// proportion of its point size, used to truncate long titles.
+	helveticaAverage = 0.52
+)
+
+// A Format describes the layout of a sheet of adhesive labels. All sizes are
+// in millimetres.
+type Format struct {
+	Name        string
+	Description string
+
+	PageWidth, PageHeight float64
+	Columns, Rows         int
+
+	Width, Height float64 // Size of each label.
+	Top, Left     float64 // Margin before the first label.
+	PitchX        float64 // Distance between the left of each column.
+	PitchY        float64 // Distance between the top of each row.
+}
+
+// PerSheet returns the number of labels on each sheet.
+func (f Format) PerSheet() int {
+	return f.Columns * f.Rows
+}
+
+// Formats are the supported label sheet formats, named after the commonly
+// available products which use them.
+var Formats = []Format{
+	{"L7160", "A4, 21 per sheet (63.5 x 38.1mm)", 210, 297, 3, 7, 63.5, 38.1, 15.15, 7.25, 66.04, 38.1},
+	{"L7163", "A4, 14 per sheet (99.1 x 38.1mm)", 210, 297, 2, 7, 99.1, 38.1, 15.15, 4.65, 101.6, 38.1},
+	{"L7165", "A4, 8 per sheet (99.1 x 67.7mm)", 210, 297, 2, 4, 99.1, 67.7, 13.1, 4.65, 101.6, 67.7},
+	{"L7651", "A4, 65 per sheet (38.1 x 21.2mm)", 210, 297, 5, 13, 38.1, 21.2, 10.7, 4.75, 40.64, 21.2},
+	{"5160", "US Letter, 30 per sheet (2.625 x 1in)", 215.9, 279.4, 3, 10, 66.675, 25.4, 12.7, 4.7625, 69.85, 25.4},
+}
+
+// FindFormat returns the label format with the given name.
+func FindFormat(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// A Label is a single label to be printed, bearing a title and a barcode.
+type Label struct {
+	Title string
+	Code  string
+}
+
+// WriteSheets lays out the given labels onto as many sheets of the given
+// format as are required and writes the resulting PDF to w. The first skip
+// labels of the first sheet are left blank, so that partly used sheets may be
+// printed on again.
+func WriteSheets(w io.Writer, f Format, labels []Label, skip int) error {
+	if len(labels) == 0 {
+		return ErrNoLabels
+	}
+	if skip < 0 || skip >= f.PerSheet() {
+		return fmt.Errorf("write %s sheets: %w", f.Name, ErrBadSkip)
+	}
+
+	doc := newPDFDocument(f.PageWidth, f.PageHeight)
+
+	var page *pdfPage
+	for i, l := range labels {
+		pos := (i + skip) % f.PerSheet()
+		if page == nil || pos == 0 {
+			if page != nil {
+				doc.addPage(page)
+			}
+			page = doc.newPage()
+		}
+
+		x := f.Left + float64(pos%f.Columns)*f.PitchX
+		y := f.Top + float64(pos/f.Columns)*f.PitchY
+		if err := drawLabel(page, f, x, y, l); err != nil {
+			return fmt.Errorf("write %s sheets: %w", f.Name, err)
+		}
+	}
+	doc.addPage(page)
+
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("write %s sheets: %w", f.Name, err)
+	}
+
+	return nil
+}
+
+// drawLabel draws a single label with its top left corner at x, y. The title
+// is drawn across the top, with the barcode beneath and the human readable
+// code at the bottom.
+func drawLabel(p *pdfPage, f Format, x, y float64, l Label) error {
+	bars, err := Code128(l.Code)
+	if err != nil {
+		return err
+	}
+
+	modules := 2 * labelQuiet
+	for _, b := range bars {
+		modules += b
+	}
+
+	inner := f.Width - 2*labelPadding
+	module := math.Min(inner/float64(modules), maxModuleWidth)
+	if module < minModuleWidth {
+		return fmt.Errorf("%s on %s: %w", l.Code, f.Name, ErrLabelSmall)
+	}
+
+	// Title size scales with the label, so that small labels still have room
+	// for a reasonably tall barcode.
+	titleSize := math.Min(maxTitleSize, f.Height*ptPerMM/6)
+	titleHeight := titleSize / ptPerMM
+	codeHeight := codeTextSize / ptPerMM
+
+	top := y + labelPadding
+	p.text(x+labelPadding, top+titleHeight*0.8, titleSize, truncate(l.Title, inner, titleSize))
+
+	barTop := top + titleHeight + 1
+	barHeight := f.Height - 2*labelPadding - titleHeight - codeHeight - 2
+	barLeft := x + labelPadding + (inner-module*float64(modules))/2 + module*labelQuiet
+
+	cur := barLeft
+	for i, b := range bars {
+		width := module * float64(b)
+		if i%2 == 0 {
+			p.rect(cur, barTop, width, barHeight)
+		}
+		cur += width
+	}
+
+	codeWidth := float64(len(l.Code)) * codeTextSize * helveticaAverage / ptPerMM
+	p.text(x+(f.Width-codeWidth)/2, barTop+barHeight+codeHeight, codeTextSize, l.Code)
+
+	return nil
+}
+
+// truncate shortens s such that it fits, approximately, within width
+// millimetres at the given point size.
+func truncate(s string, width, size float64) string {
+	max := int(width * ptPerMM / (size * helveticaAverage))
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	if max < 3 {
+		return ""
+	}
+
+	return string(r[:max-3]) + "..."
+}
@@ -0,0 +1,71 @@
+package labels
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteSheets(t *testing.T) {
+	f, ok := FindFormat("L7160")
+	if !ok {
+		t.Fatal("FindFormat() could not find L7160")
+	}
+
+	tests := []struct {
+		name      string
+		count     int
+		skip      int
+		wantPages int
+		wantErr   error
+	}{
+		{"single", 1, 0, 1, nil},
+		{"full sheet", 21, 0, 1, nil},
+		{"overflow", 22, 0, 2, nil},
+		{"skipped overflow", 2, 20, 2, nil},
+		{"none", 0, 0, 0, ErrNoLabels},
+		{"skip sheet", 1, 21, 0, ErrBadSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := make([]Label, tt.count)
+			for i := range l {
+				l[i] = Label{Title: "Beaker (250ml)", Code: "PREP42"}
+			}
+
+			var buf bytes.Buffer
+			err := WriteSheets(&buf, f, l, tt.skip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteSheets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			out := buf.String()
+			if !strings.HasPrefix(out, "%PDF-") || !strings.HasSuffix(out, "%%EOF\n") {
+				t.Errorf("WriteSheets() did not produce a PDF document")
+			}
+			if got := strings.Count(out, "/Type /Page "); got != tt.wantPages {
+				t.Errorf("WriteSheets() = %d pages, want %d", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestWriteSheetsSmall(t *testing.T) {
+	f, _ := FindFormat("L7651")
+	l := []Label{{Title: "Long", Code: strings.Repeat("X", 40)}}
+
+	var buf bytes.Buffer
+	if err := WriteSheets(&buf, f, l, 0); !errors.Is(err, ErrLabelSmall) {
+		t.Errorf("WriteSheets() error = %v, want %v", err, ErrLabelSmall)
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	if got, want := pdfEscape(`a(b)\c é`), `a\(b\)\\c ?`; got != want {
+		t.Errorf("pdfEscape() = %s, want %s", got, want)
+	}
+}
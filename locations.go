@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+)
+
+// optionalID parses an optional ID from a form value. An empty value yields a
+// nil ID.
+func optionalID(v string) (*uint, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	lid, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uint(lid)
+	return &id, nil
+}
+
+// handleLocations is the handler for "/inventory/locations".
+//
+// Shows the storage location hierarchy and a form for adding new locations.
+func handleLocations(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	locs, err := data.GetStorageLocations(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	errmsg := c.Query("error")
+
+	dat := struct {
+		DashboardData
+		Locations    data.Locations
+		AllLocations data.Locations
+		Kinds        []data.LocationKind
+		Error        string
+	}{ddat, locs.Sorted(), locs, []data.LocationKind{
+		data.LocationBuilding, data.LocationRoom, data.LocationCupboard, data.LocationShelf,
+	}, errmsg}
+
+	c.HTML(http.StatusOK, "locations.gohtml", dat)
+}
+
+// handleLocationNew is the handler for POST "/inventory/locations".
+func handleLocationNew(c *gin.Context) {
+	db := tenantDB(c)
+	frm := struct {
+		Name   string `form:"name" binding:"required"`
+		Kind   uint8  `form:"kind"`
+		Parent string `form:"parent"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	parent, err := optionalID(frm.Parent)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Parent ID")
+		return
+	}
+
+	_, err = data.NewStorageLocation(db, frm.Name, data.LocationKind(frm.Kind), parent)
+	if err != nil {
+		if errors.Is(err, data.ErrBadNesting) || errors.Is(err, data.ErrNoSuchLocation) {
+			c.Redirect(http.StatusFound, "/inventory/locations?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/locations")
+}
+
+// handleLocationDelete is the handler for "/inventory/locations/[ID]/delete".
+//
+// Locations may only be deleted when they are empty and have no children.
+func handleLocationDelete(c *gin.Context) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Location ID")
+		return
+	}
+
+	loc, err := data.GetStorageLocation(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Location Not Found")
+		return
+	}
+
+	var children, stocked int64
+	if err := db.Model(&data.StorageLocation{}).Where("parent_id = ?", loc.ID).Count(&children).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := db.Model(&data.ItemStock{}).Where("location_id = ? AND quantity > 0", loc.ID).Count(&stocked).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	if children != 0 || stocked != 0 {
+		msg := fmt.Sprint(loc.Name, " still contains other locations or stock and cannot be deleted")
+		c.Redirect(http.StatusFound, "/inventory/locations?error="+url.QueryEscape(msg))
+		return
+	}
+
+	if err := db.Delete(&loc).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/locations")
+}
+
+// handleItemMove is the handler for POST "/inventory/item/[ID]/move".
+//
+// Moves stock of an item between two locations. Either location may be blank
+// to move from or to unassigned stock.
+func handleItemMove(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := struct {
+		From     string `form:"from_location"`
+		To       string `form:"to_location"`
+		Quantity uint   `form:"move_quantity" binding:"required"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	from, err := optionalID(frm.From)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Source Location")
+		return
+	}
+	to, err := optionalID(frm.To)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Destination Location")
+		return
+	}
+
+	dest := fmt.Sprint("/inventory/item/", item.ID, "/locate")
+	if err := data.MoveStock(db, item, from, to, frm.Quantity, s.UserID); err != nil {
+		if errors.Is(err, data.ErrInsufficientStock) || errors.Is(err, data.ErrNoSuchLocation) {
+			c.Redirect(http.StatusFound, dest+"?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, dest)
+}
@@ -39,12 +39,35 @@ const (
 	PathStatic    = PathFrontend + string(os.PathSeparator) + "static"
 	PathSample    = "config.sample.json"
 	PathConfig    = "config.json"
+	PathUploads   = "uploads"
 )
 
+// Models are all database models, in migration order.
+var Models = []interface{}{
+	&data.PrepRoom{},
+	&data.User{},
+	&data.Booking{}, &data.Activity{},
+	&data.EquipmentSet{}, &data.EquipmentItem{},
+	&data.ChemicalBatch{},
+	&data.StorageLocation{}, &data.ItemStock{}, &data.StockMove{},
+	&data.ServiceRecord{}, &data.EquipmentUnit{},
+	&data.ReturnRecord{},
+	&data.ItemAudit{}, &data.ItemChange{},
+	&data.Kit{}, &data.KitItem{},
+	&data.ItemSubstitute{},
+	&data.Supplier{}, &data.CatalogueEntry{},
+	&data.PurchaseOrder{}, &data.OrderLine{},
+	&data.Budget{},
+	&data.Room{},
+	&data.SchoolDate{},
+}
+
 // Lifetime application state.
 var (
 	Config        conf.Config
 	Database      *gorm.DB
+	Trust         *Tenant
+	Tenants       map[string]*Tenant
 	Sessions      session.Store
 	ISAMS         *isams.ISAMS
 	Maintenance   maintenance.Manager
@@ -119,6 +142,159 @@ func cleanDeleted() error {
 	return data.CleanDeleted(Database)
 }
 
+func checkWaitlistExpiry() error {
+	for _, t := range servedTenants() {
+		if err := notifyWaitlistExpiry(t.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyWaitlistExpiry rejects the bookings in db still waitlisted on the day
+// they start, letting their owners know. Maintenance runs early in the
+// morning, so owners hear before the lesson rather than after it.
+func notifyWaitlistExpiry(db *gorm.DB) error {
+	y, m, d := time.Now().Date()
+	exp, err := data.ExpireWaitlist(db, time.Date(y, m, d+1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+
+	for _, bk := range exp {
+		Notifications.PushUser(bk.OwnerID, notifications.Notification{
+			Title:  "Waitlisted Booking Expired",
+			Body:   fmt.Sprint("Equipment has not been freed for your booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), " in time for the lesson today, so it has been rejected."),
+			Action: fmt.Sprint("/book/booking/", bk.ID),
+			Type:   notifications.TypeDanger,
+			Time:   time.Now(),
+		})
+	}
+
+	if len(exp) > 0 {
+		log.Println("Expired", len(exp), "waitlisted bookings")
+	}
+	return nil
+}
+
+func checkBatchExpiry() error {
+	for _, t := range servedTenants() {
+		if err := notifyBatchExpiry(t.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyBatchExpiry warns technicians of the batches in db which have expired
+// or are soon to.
+func notifyBatchExpiry(db *gorm.DB) error {
+	bt, err := data.GetExpiringBatches(db, time.Now().Add(batchExpiryWarning))
+	if err != nil {
+		return err
+	}
+
+	if len(bt) == 0 {
+		return nil
+	}
+
+	for _, b := range bt {
+		n := notifications.Notification{
+			Title:  "Batch Expiring Soon",
+			Body:   fmt.Sprint("Batch ", b.LotNumber, " of ", b.Item.Name, " expires on ", b.Expiry.Local().Format("02/01/06"), "."),
+			Action: fmt.Sprint("/inventory/item/", b.ItemID, "#batches"),
+			Type:   notifications.TypeImportant,
+			Time:   time.Now(),
+		}
+		if b.Expired() {
+			n.Title = "Batch Expired"
+			n.Body = fmt.Sprint("Batch ", b.LotNumber, " of ", b.Item.Name, " expired on ", b.Expiry.Local().Format("02/01/06"), " and should be disposed of.")
+			n.Type = notifications.TypeDanger
+		}
+
+		// Only the prep room holding the batch need dispose of it.
+		urs, err := data.PrepRoomTechnicians(db, b.Item.PrepRoomID)
+		if err != nil {
+			return err
+		}
+		for _, u := range urs {
+			Notifications.PushUser(u.ID, n)
+		}
+	}
+
+	log.Println("Found", len(bt), "expired or expiring batches")
+	return nil
+}
+
+func checkServiceDue() error {
+	for _, t := range servedTenants() {
+		if err := notifyServiceDue(t.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyServiceDue warns technicians of the items in db which are out of test.
+// Each technician hears only of the items held by their prep room, or shared.
+func notifyServiceDue(db *gorm.DB) error {
+	st, err := data.GetOutOfTest(db, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if len(st) == 0 {
+		return nil
+	}
+
+	// Technicians are looked up once per prep room, with zero for shared
+	// items.
+	techs := make(map[uint][]data.User)
+	due := make(map[uint][]data.ServiceStatus)
+	for _, s := range st {
+		room := uint(0)
+		if s.Item.PrepRoomID != nil {
+			room = *s.Item.PrepRoomID
+		}
+
+		urs, ok := techs[room]
+		if !ok {
+			urs, err = data.PrepRoomTechnicians(db, s.Item.PrepRoomID)
+			if err != nil {
+				return err
+			}
+			techs[room] = urs
+		}
+
+		for _, u := range urs {
+			due[u.ID] = append(due[u.ID], s)
+		}
+	}
+
+	for uid, st := range due {
+		// One notification for the lot, as there may be many out of test
+		// at once (such as every power pack when PAT testing is due).
+		n := notifications.Notification{
+			Title:  "Equipment Out of Test",
+			Body:   fmt.Sprint(st[0].Name(), " is out of test (", st[0].Reason(time.Now()), ")."),
+			Action: "/inventory/service",
+			Type:   notifications.TypeDanger,
+			Time:   time.Now(),
+		}
+		if len(st) > 1 {
+			n.Body = fmt.Sprint(len(st), " items are overdue for servicing or have failed a test, including ", st[0].Name(), ".")
+		}
+
+		Notifications.PushUser(uid, n)
+	}
+
+	log.Println("Found", len(st), "items out of test")
+	return nil
+}
+
 func initRoutes(router *gin.Engine) {
 	// Static assets path
 	router.Static("/assets/", "frontend/static")
@@ -132,6 +308,9 @@ func initRoutes(router *gin.Engine) {
 	// Help page
 	router.GET("/help", session.Authenticator(&Sessions, true), handleHelp)
 
+	// Safety data sheets
+	router.GET("/sds/:id", session.Authenticator(&Sessions, true), handleItemSDS)
+
 	// Login page
 	router.GET("/login", handleLogin)
 	router.POST("/login", handleLoginAttempt)
@@ -139,6 +318,9 @@ func initRoutes(router *gin.Engine) {
 	// Logout page
 	router.GET("/logout", handleLogout)
 
+	// Tenant list for trust admins
+	router.GET("/tenants", session.Permissions(&Sessions, Database, data.CapManageUsers, true), handleTenants)
+
 	// Dashboard (requires authentication)
 	router.GET("/dashboard/", session.Authenticator(&Sessions, true), handleDashboard)
 
@@ -161,11 +343,18 @@ func initRoutes(router *gin.Engine) {
 	r = router.Group("/todo/", session.Permissions(&Sessions, Database, data.CapAllBooking, true))
 	{
 		r.GET("/", handleTodo)
+		r.GET("/calendar", handleCalendar)
+		r.GET("/workload", handleWorkload)
+		r.GET("/capacity", handleCapacity)
 
 		r.GET("/unread/:id", handleTodoUnread)
 		r.GET("/progress/:id", handleTodoProgress)
 		r.GET("/done/:id", handleTodoDone)
 		r.GET("/reject/:id", handleTodoReject)
+		r.GET("/claim/:id", handleTodoClaim)
+		r.POST("/assign/:id", handleTodoAssign)
+		r.POST("/prep/:id", handleTodoPrepTime)
+		r.POST("/override/:id", handleBookOverride)
 	}
 
 	r = router.Group("/inventory/", session.Permissions(&Sessions, Database, data.CapManageInventory, true))
@@ -177,6 +366,78 @@ func initRoutes(router *gin.Engine) {
 		r.GET("/new", handleNewItem)
 		r.GET("/report", handleInventoryReport)
 		r.GET("/locate", handleInventoryLocate)
+		r.GET("/labels", handleLabels)
+		r.GET("/labels/print", handleLabelsPrint)
+		r.GET("/scan", handleScan)
+		r.GET("/import", handleInventoryImport)
+		r.GET("/export", handleInventoryExport)
+
+		r.POST("/item/:id/sds", handleItemUploadSDS)
+		r.POST("/item/:id/move", handleItemMove)
+		r.POST("/item/:id/adjust", handleItemAdjust)
+		r.POST("/import", handleInventoryDoImport)
+		r.POST("/item/:id/batch", handleBatchNew)
+		r.POST("/batch/:id/edit", handleBatchEdit)
+		r.GET("/batch/:id/open", handleBatchOpen)
+		r.GET("/batch/:id/delete", handleBatchDelete)
+
+		r.GET("/locations", handleLocations)
+		r.POST("/locations", handleLocationNew)
+		r.GET("/locations/:id/delete", handleLocationDelete)
+
+		r.POST("/rooms", handleRoomNew)
+		r.GET("/rooms/seed", handleRoomSeed)
+		r.POST("/room/:id/edit", handleRoomEdit)
+		r.GET("/room/:id/delete", handleRoomDelete)
+
+		r.POST("/dates", handleSchoolDateNew)
+		r.GET("/dates/import", handleSchoolDateImport)
+		r.GET("/date/:id/delete", handleSchoolDateDelete)
+
+		r.GET("/preprooms", handlePrepRooms)
+		r.POST("/preprooms", handlePrepRoomSave)
+		r.POST("/preproom/:id/edit", handlePrepRoomSave)
+		r.GET("/preproom/:id/delete", handlePrepRoomDelete)
+		r.POST("/item/:id/preproom", handleItemPrepRoom)
+		r.POST("/item/:id/transfer", handleItemTransfer)
+
+		r.GET("/breakages", handleBreakages)
+		r.GET("/costs", handleCosts)
+		r.POST("/costs/budget", handleCostsBudget)
+
+		r.GET("/service", handleService)
+		r.POST("/item/:id/service", handleServiceNew)
+		r.GET("/service/:id/delete", handleServiceDelete)
+		r.POST("/item/:id/unit", handleUnitNew)
+		r.GET("/unit/:id", handleUnit)
+		r.POST("/unit/:id/edit", handleUnitEdit)
+		r.GET("/unit/:id/delete", handleUnitDelete)
+		r.POST("/item/:id/substitute", handleSubstituteNew)
+		r.GET("/substitute/:id/delete", handleSubstituteDelete)
+
+		r.GET("/kits", handleKits)
+		r.POST("/kits", handleKitNew)
+		r.GET("/kit/:id", handleKit)
+		r.POST("/kit/:id/edit", handleKitEdit)
+		r.GET("/kit/:id/delete", handleKitDelete)
+
+		r.GET("/suppliers", handleSuppliers)
+		r.POST("/suppliers", handleSupplierNew)
+		r.GET("/supplier/:id", handleSupplier)
+		r.POST("/supplier/:id/edit", handleSupplierEdit)
+		r.GET("/supplier/:id/delete", handleSupplierDelete)
+		r.POST("/item/:id/supplier", handleCatalogueNew)
+		r.GET("/catalogue/:id/delete", handleCatalogueDelete)
+
+		r.GET("/lowstock", handleLowStock)
+		r.POST("/lowstock", handleLowStockOrder)
+		r.GET("/orders", handleOrders)
+		r.POST("/orders", handleOrderNew)
+		r.GET("/order/:id", handleOrder)
+		r.POST("/order/:id/edit", handleOrderEdit)
+		r.GET("/order/:id/send", handleOrderSend)
+		r.GET("/order/:id/receive", handleOrderReceive)
+		r.GET("/order/:id/delete", handleOrderDelete)
 	}
 
 	r = router.Group("/activity/", session.Permissions(&Sessions, Database, data.CapManageInventory, true))
@@ -203,6 +464,19 @@ func initRoutes(router *gin.Engine) {
 		r.GET("/booking/:id/amend", handleBookAmend)
 		r.POST("/booking/:id/amend", handleBookDoAmend)
 		r.GET("/booking/:id/cancel", handleBookCancel)
+		r.GET("/booking/:id/return", handleBookReturn)
+		r.POST("/booking/:id/return", handleBookDoReturn)
+	}
+
+	r = router.Group("/rooms/", session.Authenticator(&Sessions, true))
+	{
+		r.GET("/", handleRooms)
+		r.GET("/:id", handleRoom)
+	}
+
+	r = router.Group("/dates/", session.Authenticator(&Sessions, true))
+	{
+		r.GET("/", handleSchoolDates)
 	}
 
 	r = router.Group("/api/")
@@ -211,7 +485,11 @@ func initRoutes(router *gin.Engine) {
 		r.POST("/user/edit/:id", handleAPIEditUser)
 		r.GET("/dashboard", handleAPIDashboard)
 		r.GET("/period", handleAPIPeriod)
+		r.GET("/periods", handleAPIPeriods)
+		r.GET("/week", handleAPIWeek)
 		r.GET("/clashes", session.Authenticator(&Sessions, false), handleAPIClashes)
+		r.GET("/rooms/clashes", session.Authenticator(&Sessions, false), handleAPIRoomClashes)
+		r.GET("/calendar", session.Permissions(&Sessions, Database, data.CapAllBooking, false), handleAPICalendar)
 
 		r = r.Group("/item/", session.Permissions(&Sessions, Database, data.CapManageInventory, false))
 		{
@@ -224,10 +502,11 @@ func initRoutes(router *gin.Engine) {
 	r = router.Group("/admin/", session.Permissions(&Sessions, Database, data.CapLogging, false))
 	{
 		r.Any("/", handleAdminRoot)
-		r.GET("/logs", handleAdminLogs)
-		r.GET("/error", handleAdminError)
-		r.GET("/maintenance", handleAdminMaintenance)
-		r.GET("/runnow", handleAdminRunMaint)
+		r.GET("/logs", requireTrust, handleAdminLogs)
+		r.GET("/error", requireTrust, handleAdminError)
+		r.GET("/maintenance", requireTrust, handleAdminMaintenance)
+		r.GET("/runnow", requireTrust, handleAdminRunMaint)
+		r.GET("/audit", handleAdminAudit)
 	}
 }
 
@@ -273,16 +552,45 @@ func main() {
 	if Config.DebugMode {
 		// Migrate schema if needed
 		log.Println("[WARNING]: Auto migrating database schema...")
-		if Database.AutoMigrate(
-			&data.User{},
-			&data.Booking{}, &data.Activity{},
-			&data.EquipmentSet{}, &data.EquipmentItem{},
-		) != nil {
+		if Database.AutoMigrate(Models...) != nil {
 			log.Fatalln("Database migration failed")
 		}
+		if err := data.MigrateHazards(Database); err != nil {
+			log.Fatalln("Hazard migration failed:", err)
+		}
+		// Returns are migrated from the department charged.
+		if err := data.MigrateCharges(Database); err != nil {
+			log.Fatalln("Charge migration failed:", err)
+		}
+		if err := data.MigrateReturns(Database); err != nil {
+			log.Fatalln("Return migration failed:", err)
+		}
+		if err := data.MigrateBudgets(Database); err != nil {
+			log.Fatalln("Budget migration failed:", err)
+		}
 		log.Println("Auto migration complete")
 	}
 	log.Println("Connected to database on", Config.Database.FullAddr())
+	if err := initTenants(); err != nil {
+		log.Fatalln("tenant setup:", err)
+	}
+	for _, t := range servedTenants() {
+		if t.ISAMS == nil {
+			continue
+		}
+
+		// Rooms may still be seeded later, so this is not fatal.
+		if n, err := seedRooms(t.DB, t.ISAMS); err != nil {
+			log.Println("[WARNING]: iSAMS room seeding:", err)
+		} else {
+			log.Println("Seeded", n, "new rooms from iSAMS")
+		}
+		if n, err := seedSchoolDates(t.DB, t.ISAMS); err != nil {
+			log.Println("[WARNING]: iSAMS term import:", err)
+		} else {
+			log.Println("Imported", n, "terms from iSAMS")
+		}
+	}
 
 	// Setup gin debug mode
 	if !Config.DebugMode {
@@ -293,7 +601,7 @@ func main() {
 	router := gin.New()
 	srv := http.Server{
 		Addr:              Config.FullAddr(),
-		Handler:           router,
+		Handler:           tenantRouter(router),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       15 * time.Second,
 		WriteTimeout:      5 * time.Second,
@@ -321,13 +629,18 @@ func main() {
 			"Predicted End Time:", fend.Format(time.RFC1123),
 		)
 	}))
+	router.Use(tenantMiddleware)
 	MSched = maintenance.Scheduler{
 		// TODO: add config variable for this
 		Interval: &maintenance.StandardInterval,
 		Manager:  &Maintenance,
 		Handlers: []func() error{
+			// Waitlisted bookings must expire before they are cleaned up.
+			checkWaitlistExpiry,
 			cleanBookings,
 			cleanDeleted,
+			checkBatchExpiry,
+			checkServiceDue,
 		},
 		Ctx: ctx,
 		Err: mterr,
@@ -345,6 +658,9 @@ func main() {
 
 	router.LoadHTMLGlob(PathTemplates + "/*")
 	initRoutes(router)
+	if err := checkTenantRoutes(router); err != nil {
+		log.Fatalln(err)
+	}
 
 	errchan := make(chan error, 1)
 	sigchan := make(chan os.Signal, 1)
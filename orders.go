@@ -0,0 +1,401 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+)
+
+// reorderLevel is the stock level which items on the low stock report are
+// ordered back up to by default.
+const reorderLevel = 4 * data.LowStockLevel
+
+// lowStockLine is an item on the low stock report, along with who sells it.
+type lowStockLine struct {
+	data.EquipmentItem
+	Catalogue []data.CatalogueEntry
+}
+
+// Reorder returns the suggested quantity to order.
+func (l lowStockLine) Reorder() uint {
+	if l.Quantity >= reorderLevel {
+		return 1
+	}
+
+	return reorderLevel - l.Quantity
+}
+
+// orderFromParam looks up the purchase order given as the "id" URI parameter.
+// If it cannot be found, a response is written and false is returned.
+func orderFromParam(c *gin.Context) (data.PurchaseOrder, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Order ID")
+		return data.PurchaseOrder{}, false
+	}
+
+	o, err := data.GetPurchaseOrder(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchOrder) {
+			c.String(http.StatusNotFound, "Order Not Found")
+			return o, false
+		}
+
+		internalError(c, err)
+		return o, false
+	}
+
+	return o, true
+}
+
+// orderError redirects back to an order's page with the given error.
+func orderError(c *gin.Context, o data.PurchaseOrder, err error) {
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/order/", o.ID, "?error=", url.QueryEscape(err.Error())))
+}
+
+// handleLowStock is the handler for "/inventory/lowstock".
+//
+// Shows every item which is running low, along with the suppliers from which
+// it may be reordered. Selected items are added to new purchase orders.
+func handleLowStock(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	items, err := data.LowStockItems(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	lines := make([]lowStockLine, len(items))
+	for i, it := range items {
+		cat, err := data.GetItemCatalogue(db, it.ID)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+
+		lines[i] = lowStockLine{it, cat}
+	}
+
+	dat := struct {
+		DashboardData
+		Items    []lowStockLine
+		LowStock int
+	}{ddat, lines, data.LowStockLevel}
+
+	c.HTML(http.StatusOK, "lowstock.gohtml", dat)
+}
+
+// handleLowStockOrder is the handler for POST "/inventory/lowstock".
+//
+// Each item to order is given as "order_[ITEM ID]", with the catalogue entry
+// to order from as "entry_[ITEM ID]" and the quantity as "qty_[ITEM ID]". One
+// draft order is created for each supplier.
+func handleLowStockOrder(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	c.MultipartForm()
+	set, err := NewPostItemInformation(db, c.Request)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Quantities: %s", err.Error())
+		return
+	}
+
+	bysup := make(map[uint][]data.OrderLine)
+	sups := make([]uint, 0)
+	for _, e := range set {
+		if c.PostForm(fmt.Sprint("order_", e.ItemID)) == "" || e.Quantity == 0 {
+			continue
+		}
+
+		lid, err := strconv.ParseUint(c.PostForm(fmt.Sprint("entry_", e.ItemID)), 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Supplier For Item %d", e.ItemID)
+			return
+		}
+
+		ent, err := data.GetCatalogueEntry(db, uint(lid))
+		if err != nil || ent.ItemID != e.ItemID {
+			c.String(http.StatusBadRequest, "Bad Supplier For Item %d", e.ItemID)
+			return
+		}
+
+		if _, ok := bysup[ent.SupplierID]; !ok {
+			sups = append(sups, ent.SupplierID)
+		}
+		bysup[ent.SupplierID] = append(bysup[ent.SupplierID], data.OrderLine{
+			ItemID:    ent.ItemID,
+			Code:      ent.Code,
+			Quantity:  e.Quantity,
+			UnitPrice: ent.Price,
+		})
+	}
+
+	for _, sup := range sups {
+		if _, err := data.NewPurchaseOrder(db, sup, s.UserID, bysup[sup]); err != nil {
+			internalError(c, err)
+			return
+		}
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/orders")
+}
+
+// handleOrders is the handler for "/inventory/orders".
+//
+// Shows every purchase order and a form for starting a new, empty order.
+func handleOrders(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	orders, err := data.GetPurchaseOrders(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	sups, err := data.GetSuppliers(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Orders    []data.PurchaseOrder
+		Suppliers []data.Supplier
+		Deleted   bool
+	}{ddat, orders, sups, c.Request.URL.Query().Has("deleted")}
+
+	c.HTML(http.StatusOK, "orders.gohtml", dat)
+}
+
+// handleOrderNew is the handler for POST "/inventory/orders".
+func handleOrderNew(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	lid, err := strconv.ParseUint(c.PostForm("supplier"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Supplier ID")
+		return
+	}
+
+	sup, err := data.GetSupplier(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Supplier Not Found")
+		return
+	}
+
+	o, err := data.NewPurchaseOrder(db, sup.ID, s.UserID, nil)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/order/", o.ID))
+}
+
+// handleOrder is the handler for "/inventory/order/[ID]".
+//
+// Shows a purchase order, which may be edited while it is a draft.
+func handleOrder(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	o, ok := orderFromParam(c)
+	if !ok {
+		return
+	}
+
+	cat, err := data.GetSupplierCatalogue(db, o.SupplierID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Order     data.PurchaseOrder
+		Catalogue []data.CatalogueEntry
+		Error     string
+	}{ddat, o, cat, c.Query("error")}
+
+	c.HTML(http.StatusOK, "order.gohtml", dat)
+}
+
+// handleOrderEdit is the handler for POST "/inventory/order/[ID]/edit".
+//
+// Existing lines are given as the parallel form arrays "line_item",
+// "line_code", "line_quantity" and "line_price". A line may be added from the
+// supplier's catalogue using "add_entry" and "add_quantity".
+func handleOrderEdit(c *gin.Context) {
+	db := tenantDB(c)
+	o, ok := orderFromParam(c)
+	if !ok {
+		return
+	}
+
+	items, codes := c.PostFormArray("line_item"), c.PostFormArray("line_code")
+	qtys, prices := c.PostFormArray("line_quantity"), c.PostFormArray("line_price")
+	if len(codes) != len(items) || len(qtys) != len(items) || len(prices) != len(items) {
+		c.String(http.StatusBadRequest, "Bad Order Lines")
+		return
+	}
+
+	lines := make([]data.OrderLine, 0, len(items)+1)
+	for i := range items {
+		item, err := strconv.ParseUint(items[i], 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Item ID")
+			return
+		}
+		qty, err := strconv.ParseUint(qtys[i], 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Quantity")
+			return
+		}
+		price, err := data.ParsePrice(prices[i])
+		if err != nil {
+			orderError(c, o, err)
+			return
+		}
+
+		lines = append(lines, data.OrderLine{ItemID: uint(item), Code: codes[i], Quantity: uint(qty), UnitPrice: price})
+	}
+
+	if sent := c.PostForm("add_entry"); sent != "" {
+		lid, err := strconv.ParseUint(sent, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Catalogue Entry ID")
+			return
+		}
+		qty, err := strconv.ParseUint(c.DefaultPostForm("add_quantity", "1"), 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Quantity")
+			return
+		}
+
+		ent, err := data.GetCatalogueEntry(db, uint(lid))
+		if err != nil || ent.SupplierID != o.SupplierID {
+			c.String(http.StatusBadRequest, "Bad Catalogue Entry")
+			return
+		}
+
+		lines = append(lines, data.OrderLine{ItemID: ent.ItemID, Code: ent.Code, Quantity: uint(qty), UnitPrice: ent.Price})
+	}
+
+	if err := data.SaveOrderLines(db, o, c.PostForm("notes"), lines); err != nil {
+		if errors.Is(err, data.ErrOrderStatus) {
+			orderError(c, o, err)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/order/", o.ID))
+}
+
+// handleOrderSend is the handler for "/inventory/order/[ID]/send".
+func handleOrderSend(c *gin.Context) {
+	db := tenantDB(c)
+	o, ok := orderFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.SendOrder(db, o); err != nil {
+		if errors.Is(err, data.ErrOrderStatus) || errors.Is(err, data.ErrOrderEmpty) {
+			orderError(c, o, err)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/order/", o.ID))
+}
+
+// handleOrderReceive is the handler for "/inventory/order/[ID]/receive".
+//
+// Everything on the order is added to stock.
+func handleOrderReceive(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	o, ok := orderFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.ReceiveOrder(db, o, s.UserID); err != nil {
+		if errors.Is(err, data.ErrOrderStatus) {
+			orderError(c, o, err)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/order/", o.ID))
+}
+
+// handleOrderDelete is the handler for "/inventory/order/[ID]/delete".
+//
+// Only drafts may be deleted.
+func handleOrderDelete(c *gin.Context) {
+	db := tenantDB(c)
+	o, ok := orderFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteOrder(db, o); err != nil {
+		if errors.Is(err, data.ErrOrderStatus) {
+			orderError(c, o, err)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/orders?deleted")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/notifications"
+)
+
+// checkPolicy enforces the booking policy of the tenant on a booking of an
+// activity in the given category starting at start. Users who may override
+// the policy are exempt. If the booking is refused, a response is written and
+// false is returned.
+func checkPolicy(c *gin.Context, u data.User, category string, start time.Time) bool {
+	if u.Can(data.CapOverridePolicy) {
+		return true
+	}
+
+	if err := tenantConfig(c).BookingPolicy.Check(category, start, time.Now()); err != nil {
+		c.String(http.StatusForbidden, "Booking Refused: %s", err)
+		return false
+	}
+
+	return true
+}
+
+// amendWindow returns how long before a booking starts the user may no longer
+// amend it. Users who may override the booking policy may amend bookings up
+// until they start.
+func amendWindow(c *gin.Context, u data.User) time.Duration {
+	if u.Can(data.CapOverridePolicy) {
+		return 0
+	}
+
+	return tenantConfig(c).BookingPolicy.AmendWindow()
+}
+
+// bookingMoved returns true if the start time given in an amendment form
+// differs from that of bk. Unchanged forms hold the start time as shown.
+func bookingMoved(bk data.Booking, start string) bool {
+	return start != bk.StartTime.Format(datetimeFormat)
+}
+
+// checkMove enforces the booking policy on moving bk to start at start, as
+// for a new booking. Bookings may not be moved to within their amendment
+// window either, as they could then no longer be amended. Bookings exempted
+// by a technician may be moved freely. If the move is refused, a response is
+// written and false is returned.
+func checkMove(c *gin.Context, u data.User, bk data.Booking, start time.Time) bool {
+	if bk.PolicyOverride {
+		return true
+	}
+
+	if time.Until(start) < amendWindow(c, u) {
+		c.String(http.StatusForbidden, "Booking Refused: bookings may not be moved to start within their amendment window")
+		return false
+	}
+
+	return checkPolicy(c, u, bk.Activity.Category, start)
+}
+
+// handleBookOverride is the handler for POST "/todo/override/[ID]".
+//
+// Exempts the booking from the booking policy, allowing its owner to amend it
+// late, or given "revoke", subjects it to the policy again. The owner is
+// notified of the change.
+func handleBookOverride(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	usr, err := data.GetUser(Database, s.UserID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	lid, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Booking ID")
+		return
+	}
+
+	bk, err := data.GetBooking(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchBooking) {
+			c.String(http.StatusNotFound, "Booking Not Found")
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	_, revoke := c.GetPostForm("revoke")
+	if err := db.Model(&bk).Update("policy_override", !revoke).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	body := fmt.Sprint(usr.DisplayName(), " has allowed late amendments to your booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), ".")
+	if revoke {
+		body = fmt.Sprint(usr.DisplayName(), " has withdrawn late amendments to your booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), ".")
+	}
+	Notifications.PushUser(bk.OwnerID, notifications.Notification{
+		Title:  "Booking Policy Override",
+		Body:   body,
+		Action: fmt.Sprint("/book/booking/", bk.ID),
+		Type:   notifications.TypeGeneric,
+		Time:   time.Now(),
+	})
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID))
+}
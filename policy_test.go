@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ejv2/prepper/conf"
+	"github.com/ejv2/prepper/data"
+)
+
+// policyContext returns a request context for a tenant with the given booking
+// policy, along with its response.
+func policyContext(p conf.BookingPolicy) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(tenantContextKey, &Tenant{Config: conf.Config{BookingPolicy: p}})
+
+	return c, w
+}
+
+func TestBookingMoved(t *testing.T) {
+	bk := data.Booking{StartTime: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+
+	if bookingMoved(bk, "2026-10-19T09:00") {
+		t.Error("unchanged start time counted as moved")
+	}
+	if !bookingMoved(bk, "2026-10-19T10:00") {
+		t.Error("later start time not counted as moved")
+	}
+	if !bookingMoved(bk, "2026-10-18T09:00") {
+		t.Error("earlier start time not counted as moved")
+	}
+}
+
+// TestCheckMove covers the checks made when amending or postponing a booking
+// to start at a different time.
+func TestCheckMove(t *testing.T) {
+	window := uint(60)
+	policy := conf.BookingPolicy{
+		LeadTimes:    map[string]uint{conf.AnyCategory: 24 * 60},
+		AmendMinutes: &window,
+	}
+	teacher := data.User{Role: data.UserTeacher}
+	technician := data.User{Role: data.UserTechnician}
+
+	// Amending or postponing a booking a week away.
+	bk := data.Booking{
+		Model:     &gorm.Model{ID: 1},
+		StartTime: time.Now().Add(7 * 24 * time.Hour),
+		Activity:  data.Activity{Category: "Chemistry"},
+	}
+	exempt := bk
+	exempt.PolicyOverride = true
+
+	testdata := []struct {
+		Name    string
+		User    data.User
+		Booking data.Booking
+		Start   time.Time
+		Expect  bool
+	}{
+		{"Later with notice", teacher, bk, bk.StartTime.Add(24 * time.Hour), true},
+		{"Earlier with notice", teacher, bk, time.Now().Add(48 * time.Hour), true},
+		{"Earlier within lead time", teacher, bk, time.Now().Add(2 * time.Hour), false},
+		{"Within amendment window", teacher, bk, time.Now().Add(30 * time.Minute), false},
+		// Postponing a booking due to start soon must still give notice.
+		{"Postponed within lead time", teacher, data.Booking{Model: bk.Model, StartTime: time.Now().Add(10 * time.Minute)}, time.Now().Add(3 * time.Hour), false},
+		{"Postponed with notice", teacher, data.Booking{Model: bk.Model, StartTime: time.Now().Add(10 * time.Minute)}, time.Now().Add(48 * time.Hour), true},
+		{"Exempted booking", teacher, exempt, time.Now().Add(10 * time.Minute), true},
+		{"Technician", technician, bk, time.Now().Add(10 * time.Minute), true},
+	}
+
+	for _, test := range testdata {
+		c, w := policyContext(policy)
+		if got := checkMove(c, test.User, test.Booking, test.Start); got != test.Expect {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Expect, got)
+		}
+		if !test.Expect && w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d", test.Name, http.StatusForbidden, w.Code)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// prepAll is the "prep" query value which shows every prep room.
+const prepAll = "all"
+
+// prepFilter is the prep room to which a page is limited. A nil ID shows
+// every prep room.
+type prepFilter struct {
+	ID *uint
+}
+
+// Is returns true if the filter is limited to the given prep room.
+func (p prepFilter) Is(id uint) bool {
+	return p.ID != nil && *p.ID == id
+}
+
+// All returns true if the filter shows every prep room.
+func (p prepFilter) All() bool {
+	return p.ID == nil
+}
+
+// prepRoomFilter parses the "prep" query parameter, which is a prep room ID
+// or "all". If absent, the user's own prep room is used. If the parameter is
+// invalid, a response is written and false is returned.
+func prepRoomFilter(c *gin.Context, u data.User) (prepFilter, bool) {
+	sp, ok := c.GetQuery("prep")
+	if !ok {
+		return prepFilter{u.PrepRoomID}, true
+	}
+	if sp == prepAll {
+		return prepFilter{}, true
+	}
+
+	id, err := data.ParsePrepRoom(sp)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Prep Room")
+		return prepFilter{}, false
+	}
+
+	return prepFilter{id}, true
+}
+
+// prepRoomFromParam looks up the prep room given as the "id" URI parameter.
+// If it cannot be found, a response is written and false is returned.
+func prepRoomFromParam(c *gin.Context) (data.PrepRoom, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Prep Room ID")
+		return data.PrepRoom{}, false
+	}
+
+	p, err := data.GetPrepRoom(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchPrepRoom) {
+			c.String(http.StatusNotFound, "Prep Room Not Found")
+			return p, false
+		}
+
+		internalError(c, err)
+		return p, false
+	}
+
+	return p, true
+}
+
+// handlePrepRooms is the handler for "/inventory/preprooms".
+//
+// Shows every prep room, along with forms for adding, renaming and removing
+// them.
+func handlePrepRooms(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Preps []data.PrepRoom
+		Error string
+	}{ddat, preps, c.Query("error")}
+
+	c.HTML(http.StatusOK, "preprooms.gohtml", dat)
+}
+
+// handlePrepRoomSave is the handler for POST "/inventory/preprooms" and
+// "/inventory/preproom/[ID]/edit".
+func handlePrepRoomSave(c *gin.Context) {
+	db := tenantDB(c)
+	p := data.PrepRoom{}
+	if c.Param("id") != "" {
+		var ok bool
+		if p, ok = prepRoomFromParam(c); !ok {
+			return
+		}
+	}
+
+	frm := struct {
+		Name        string `form:"name"`
+		Description string `form:"description"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	p.Name, p.Description = frm.Name, frm.Description
+	if err := data.SavePrepRoom(db, &p); err != nil {
+		if errors.Is(err, data.ErrPrepRoomName) || errors.Is(err, data.ErrPrepRoomExists) {
+			c.Redirect(http.StatusFound, "/inventory/preprooms?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/preprooms")
+}
+
+// handlePrepRoomDelete is the handler for "/inventory/preproom/[ID]/delete".
+//
+// Anything belonging to the prep room is shared between the others.
+func handlePrepRoomDelete(c *gin.Context) {
+	db := tenantDB(c)
+	p, ok := prepRoomFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeletePrepRoom(db, p.ID); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/preprooms")
+}
+
+// handleItemPrepRoom is the handler for POST "/inventory/item/[ID]/preproom".
+//
+// Moves an item, along with all of its stock, into the prep room given as
+// "prep_room", or shares it between all prep rooms if blank.
+func handleItemPrepRoom(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	prep, err := data.ParsePrepRoom(c.PostForm("prep_room"))
+	if err == nil {
+		err = data.CheckPrepRoom(db, prep)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Prep Room")
+		return
+	}
+
+	after := item
+	after.PrepRoomID = prep
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Update("prep_room_id", prep).Error; err != nil {
+			return err
+		}
+
+		return data.AuditItem(tx, s.UserID, data.AuditUpdate, "Prep room changed", item, after)
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#preproom"))
+}
+
+// handleItemTransfer is the handler for POST "/inventory/item/[ID]/transfer".
+//
+// Transfers stock of an item into another prep room, creating the item there
+// if needed.
+func handleItemTransfer(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := struct {
+		To       uint `form:"to_prep_room" binding:"required"`
+		Quantity uint `form:"transfer_quantity" binding:"required"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	dest := fmt.Sprint("/inventory/item/", item.ID)
+	dst, err := data.TransferStock(db, item, frm.To, frm.Quantity, s.UserID)
+	if err != nil {
+		if errors.Is(err, data.ErrInsufficientStock) || errors.Is(err, data.ErrSamePrepRoom) ||
+			errors.Is(err, data.ErrNoSuchPrepRoom) {
+			c.Redirect(http.StatusFound, dest+"?prep_error="+url.QueryEscape(err.Error())+"#preproom")
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint(dest, "?transferred=", dst.ID, "#preproom"))
+}
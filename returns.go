@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/notifications"
+	"github.com/gin-gonic/gin"
+)
+
+// breakageTerms is the number of past terms offered on the breakage report.
+const breakageTerms = 6
+
+// returnBooking looks up the booking given as the "id" URI parameter and
+// checks that the current user may record its return. If not, a response is
+// written and false is returned.
+func returnBooking(c *gin.Context, ddat DashboardData) (data.Booking, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Booking ID")
+		return data.Booking{}, false
+	}
+
+	bk, err := data.GetBooking(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchBooking) {
+			c.String(http.StatusNotFound, "Booking Not Found")
+			return bk, false
+		}
+
+		internalError(c, err)
+		return bk, false
+	}
+
+	if bk.OwnerID != ddat.User.ID && !ddat.User.Can(data.CapAllBooking) {
+		c.String(http.StatusForbidden, "Permission Denied")
+		return bk, false
+	}
+
+	return bk, true
+}
+
+// handleBookReturn is the handler for "/book/booking/[ID]/return".
+//
+// Shows the form used after a practical to record the equipment which came
+// back, and what was damaged or lost.
+func handleBookReturn(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	bk, ok := returnBooking(c, ddat)
+	if !ok {
+		return
+	}
+
+	rets, err := data.GetBookingReturns(db, bk.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Default to everything having come back in one piece.
+	prev := make(map[uint]data.ReturnRecord, len(rets))
+	for _, r := range rets {
+		prev[r.SetID] = r
+	}
+	form := make([]data.ReturnRecord, len(bk.Activity.Equipment))
+	for i, e := range bk.Activity.Equipment {
+		r, ok := prev[e.ID]
+		if !ok {
+			r = data.ReturnRecord{SetID: e.ID, Returned: e.Quantity}
+		}
+		r.Item = e.Item
+		r.Booked = e.Quantity
+		form[i] = r
+	}
+
+	dat := struct {
+		DashboardData
+		Booking  data.Booking
+		Returns  []data.ReturnRecord
+		Recorded bool
+		Early    bool
+		Error    string
+	}{ddat, bk, form, len(rets) > 0, bk.StartTime.After(time.Now()), c.Query("error")}
+
+	c.HTML(http.StatusOK, "booking-return.gohtml", dat)
+}
+
+// handleBookDoReturn is the handler for POST "/book/booking/[ID]/return".
+//
+// Quantities for each equipment set are given as the form values
+// "returned_[SET ID]", "damaged_[SET ID]" and "lost_[SET ID]", with notes in
+// "return_notes_[SET ID]". Technicians are notified of any damage or loss.
+func handleBookDoReturn(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	bk, ok := returnBooking(c, ddat)
+	if !ok {
+		return
+	}
+
+	qty := func(name string, set uint) (uint, error) {
+		v := c.PostForm(fmt.Sprint(name, "_", set))
+		if v == "" {
+			return 0, nil
+		}
+
+		n, err := strconv.ParseUint(v, 10, 32)
+		return uint(n), err
+	}
+
+	recs := make([]data.ReturnRecord, 0, len(bk.Activity.Equipment))
+	missing := uint(0)
+	for _, e := range bk.Activity.Equipment {
+		r := data.ReturnRecord{SetID: e.ID, Notes: c.PostForm(fmt.Sprint("return_notes_", e.ID))}
+
+		var errs [3]error
+		r.Returned, errs[0] = qty("returned", e.ID)
+		r.Damaged, errs[1] = qty("damaged", e.ID)
+		r.Lost, errs[2] = qty("lost", e.ID)
+		if err := errors.Join(errs[:]...); err != nil {
+			c.String(http.StatusBadRequest, "Bad Quantity: %s", err.Error())
+			return
+		}
+
+		missing += r.Missing()
+		recs = append(recs, r)
+	}
+
+	if err := data.RecordReturn(db, bk, recs, ddat.User.ID); err != nil {
+		if errors.Is(err, data.ErrReturnMismatch) || errors.Is(err, data.ErrReturnEarly) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID, "/return?error=", url.QueryEscape(err.Error())))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	if missing > 0 {
+		urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		for _, usr := range urs {
+			Notifications.PushUser(usr.ID, notifications.Notification{
+				Title:  "Equipment Damaged or Lost",
+				Body:   fmt.Sprint(ddat.User.DisplayName(), " recorded ", missing, " items damaged or lost from a booking of ", bk.Activity.Title, " on ", bk.StartTime.Format("02/01/06"), ". Stock has been adjusted."),
+				Action: fmt.Sprint("/book/booking/", bk.ID),
+				Type:   notifications.TypeDanger,
+				Time:   time.Now(),
+			})
+		}
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/book/booking/", bk.ID))
+}
+
+// handleBreakages is the handler for "/inventory/breakages".
+//
+// Shows the equipment damaged and lost over a term, by department, teacher and
+// item. The term is chosen by passing any date within it as the "term" query
+// parameter, defaulting to the current term.
+func handleBreakages(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	at := time.Now()
+	if st := c.Query("term"); st != "" {
+		at, err = time.ParseInLocation(dateFormat, st, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+			return
+		}
+	}
+	cal, err := data.GetAcademicCalendar(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	term := cal.TermOf(at)
+
+	rep, err := data.GetBreakageReport(db, term)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	terms := cal.Recent(time.Now(), breakageTerms)
+
+	dat := struct {
+		DashboardData
+		Term   data.Term
+		Terms  []data.Term
+		Report data.BreakageReport
+	}{ddat, term, terms, rep}
+
+	c.HTML(http.StatusOK, "breakages.gohtml", dat)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/isams"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roomForm is the form used to create and edit rooms.
+type roomForm struct {
+	Name         string `form:"name"`
+	Description  string `form:"description"`
+	Capacity     uint   `form:"capacity"`
+	FumeCupboard bool   `form:"fume_cupboard"`
+	Gas          bool   `form:"gas"`
+	Sinks        bool   `form:"sinks"`
+}
+
+// apply copies the form values into a room.
+func (f roomForm) apply(r *data.Room) {
+	r.Name, r.Description, r.Capacity = f.Name, f.Description, f.Capacity
+	r.FumeCupboard, r.Gas, r.Sinks = f.FumeCupboard, f.Gas, f.Sinks
+}
+
+// roomFromParam looks up the room given as the "id" URI parameter. If it
+// cannot be found, a response is written and false is returned.
+func roomFromParam(c *gin.Context) (data.Room, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Room ID")
+		return data.Room{}, false
+	}
+
+	r, err := data.GetRoom(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchRoom) {
+			c.String(http.StatusNotFound, "Room Not Found")
+			return r, false
+		}
+
+		internalError(c, err)
+		return r, false
+	}
+
+	return r, true
+}
+
+// seedRooms adds any classrooms from is which are not yet known as rooms in
+// db.
+func seedRooms(db *gorm.DB, is *isams.ISAMS) (int, error) {
+	rooms := make([]data.Room, len(is.Rooms))
+	for i, r := range is.Rooms {
+		id := uint64(r.ID)
+		rooms[i] = data.Room{Name: r.Name, Description: r.Description, IsamsID: &id}
+	}
+
+	return data.SeedRooms(db, rooms)
+}
+
+// handleRooms is the handler for "/rooms/".
+//
+// Shows every room, along with forms for adding rooms for technicians.
+func handleRooms(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	rooms, err := data.GetRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Rooms  []data.Room
+		New    data.Room
+		ISAMS  bool
+		Seeded string
+		Error  string
+	}{ddat, rooms, data.Room{}, tenantISAMS(c) != nil, c.Query("seeded"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "rooms.gohtml", dat)
+}
+
+// handleRoom is the handler for "/rooms/[ID]".
+//
+// Shows a room and everything booked in it on the day given as "date",
+// defaulting to today.
+func handleRoom(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	r, ok := roomFromParam(c)
+	if !ok {
+		return
+	}
+
+	y, m, d := time.Now().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if sd := c.Query("date"); sd != "" {
+		day, err = time.ParseInLocation(dateFormat, sd, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+			return
+		}
+	}
+
+	bks, err := data.RoomBookings(db, r.Name, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Room     data.Room
+		Date     time.Time
+		Previous time.Time
+		Next     time.Time
+		Bookings []data.Booking
+		Saved    bool
+		Error    string
+	}{ddat, r, day, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1), bks, c.Request.URL.Query().Has("saved"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "room.gohtml", dat)
+}
+
+// handleRoomNew is the handler for POST "/inventory/rooms".
+func handleRoomNew(c *gin.Context) {
+	db := tenantDB(c)
+	frm := roomForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	r := data.Room{}
+	frm.apply(&r)
+	if err := data.SaveRoom(db, &r); err != nil {
+		if errors.Is(err, data.ErrRoomName) || errors.Is(err, data.ErrRoomExists) {
+			c.Redirect(http.StatusFound, "/rooms/?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/rooms/", r.ID))
+}
+
+// handleRoomEdit is the handler for POST "/inventory/room/[ID]/edit".
+func handleRoomEdit(c *gin.Context) {
+	db := tenantDB(c)
+	r, ok := roomFromParam(c)
+	if !ok {
+		return
+	}
+
+	frm := roomForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	frm.apply(&r)
+	if err := data.SaveRoom(db, &r); err != nil {
+		if errors.Is(err, data.ErrRoomName) || errors.Is(err, data.ErrRoomExists) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/rooms/", r.ID, "?error=", url.QueryEscape(err.Error())))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/rooms/", r.ID, "?saved"))
+}
+
+// handleRoomDelete is the handler for "/inventory/room/[ID]/delete".
+func handleRoomDelete(c *gin.Context) {
+	db := tenantDB(c)
+	r, ok := roomFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteRoom(db, r.ID); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/rooms/")
+}
+
+// handleRoomSeed is the handler for "/inventory/rooms/seed".
+//
+// Adds any iSAMS classrooms which are not yet known as rooms.
+func handleRoomSeed(c *gin.Context) {
+	is := tenantISAMS(c)
+	if is == nil {
+		c.String(http.StatusNotFound, "iSAMS Not Enabled")
+		return
+	}
+
+	n, err := seedRooms(tenantDB(c), is)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	log.Println("seeded", n, "rooms from iSAMS")
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/rooms/?seeded=", n))
+}
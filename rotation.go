@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timetableWeek returns the position and name of the week of the timetable
+// rotation containing t. Weeks are named as configured, or else after the
+// timetable weeks in iSAMS. If the tenant has no rotation configured, false is
+// returned.
+func timetableWeek(c *gin.Context, t time.Time) (int, string, bool) {
+	rot := tenantConfig(c).WeekRotation
+	if rot == nil {
+		return 0, "", false
+	}
+
+	names := rot.Weeks
+	if is := tenantISAMS(c); len(names) == 0 && is != nil {
+		names = is.WeekNames()
+	}
+	if len(names) == 0 {
+		return 0, "", false
+	}
+
+	i := rot.Index(t, len(names))
+	return i, names[i], true
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultServiceInterval is used to work out when a service is next due for
+// items which do not have a service interval of their own.
+const defaultServiceInterval = 365 * 24 * time.Hour
+
+// serviceKinds are the kinds of service which may be recorded, in the order in
+// which they are offered.
+var serviceKinds = []data.ServiceKind{
+	data.ServicePAT, data.ServiceCalibration, data.ServiceInspection, data.ServiceRepair,
+}
+
+// handleService is the handler for "/inventory/service".
+//
+// Shows the test status of all equipment which must be serviced, with those
+// out of test listed first.
+func handleService(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	st, err := data.GetServiceStatus(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	now := time.Now()
+	sort.SliceStable(st, func(i, j int) bool {
+		ii, ji := st[i].InTest(now), st[j].InTest(now)
+		if ii != ji {
+			return !ii
+		}
+		if st[i].Latest == nil || st[j].Latest == nil {
+			return st[i].Latest == nil && st[j].Latest != nil
+		}
+
+		return st[i].Latest.NextDue.Before(st[j].Latest.NextDue)
+	})
+
+	out := 0
+	for _, e := range st {
+		if !e.InTest(now) {
+			out++
+		}
+	}
+
+	dat := struct {
+		DashboardData
+		Status    []data.ServiceStatus
+		OutOfTest int
+	}{ddat, st, out}
+
+	c.HTML(http.StatusOK, "service.gohtml", dat)
+}
+
+// handleServiceNew is the handler for POST "/inventory/item/[ID]/service".
+//
+// Records a service of the given item, or one unit of it, carried out by the
+// current user. If no next due date is given, it is worked out from the service
+// interval of the item.
+func handleServiceNew(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := struct {
+		Kind    uint8  `form:"service_kind"`
+		Result  uint8  `form:"service_result"`
+		Tested  string `form:"tested" binding:"required"`
+		NextDue string `form:"next_due"`
+		Notes   string `form:"service_notes"`
+		Unit    string `form:"service_unit"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	kind, result := data.ServiceKind(frm.Kind), data.ServiceResult(frm.Result)
+	if !kind.Valid() {
+		c.String(http.StatusBadRequest, "Bad Service Kind")
+		return
+	}
+	if !result.Valid() {
+		c.String(http.StatusBadRequest, "Bad Service Result")
+		return
+	}
+
+	unit, err := optionalID(frm.Unit)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Unit ID")
+		return
+	}
+	if unit != nil {
+		u, err := data.GetUnit(db, *unit)
+		if err != nil || u.ItemID != item.ID {
+			c.String(http.StatusBadRequest, "Unit is not of this item")
+			return
+		}
+	}
+
+	tested, err := time.ParseInLocation(dateFormat, frm.Tested, time.Local)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+		return
+	}
+
+	next := tested.Add(defaultServiceInterval)
+	if item.NeedsService() {
+		next = tested.AddDate(0, 0, int(item.ServiceInterval))
+	}
+	if frm.NextDue != "" {
+		next, err = time.ParseInLocation(dateFormat, frm.NextDue, time.Local)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Bad Date Format: %s", err.Error())
+			return
+		}
+	}
+
+	if !next.After(tested) {
+		c.String(http.StatusBadRequest, "The next service must be due after this one")
+		return
+	}
+
+	rec := data.ServiceRecord{
+		Model:    &gorm.Model{},
+		ItemID:   item.ID,
+		UnitID:   unit,
+		Kind:     kind,
+		Result:   result,
+		Notes:    frm.Notes,
+		TesterID: s.UserID,
+		Tested:   tested,
+		NextDue:  next,
+	}
+	if err := db.Create(&rec).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#service"))
+}
+
+// handleServiceDelete is the handler for "/inventory/service/[ID]/delete".
+func handleServiceDelete(c *gin.Context) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Service Record ID")
+		return
+	}
+
+	rec, err := data.GetServiceRecord(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Service Record Not Found")
+		return
+	}
+
+	if err := db.Delete(&rec).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", rec.ItemID, "#service"))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+)
+
+// handleSubstituteNew is the handler for POST "/inventory/item/[ID]/substitute".
+//
+// Records that the item given as the form value "substitute" may be used in
+// place of this item.
+func handleSubstituteNew(c *gin.Context) {
+	db := tenantDB(c)
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	lsub, err := strconv.ParseUint(c.PostForm("substitute"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Substitute ID")
+		return
+	}
+
+	sub, err := data.GetEquipmentItem(db, uint(lsub))
+	if err != nil {
+		c.String(http.StatusNotFound, "Substitute Not Found")
+		return
+	}
+
+	if err := data.AddSubstitute(db, item.ID, sub.ID); err != nil {
+		if errors.Is(err, data.ErrSelfSubstitute) || errors.Is(err, data.ErrSubstituteExists) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "?substitute_error=", url.QueryEscape(err.Error()), "#substitutes"))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#substitutes"))
+}
+
+// handleSubstituteDelete is the handler for "/inventory/substitute/[ID]/delete".
+func handleSubstituteDelete(c *gin.Context) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Substitute ID")
+		return
+	}
+
+	sub, err := data.GetSubstitute(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Substitute Not Found")
+		return
+	}
+
+	if err := db.Delete(&sub).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", sub.ItemID, "#substitutes"))
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// supplierForm is the form used to create and edit suppliers.
+type supplierForm struct {
+	Name    string `form:"name"`
+	Email   string `form:"email"`
+	Phone   string `form:"phone"`
+	Website string `form:"website"`
+	Notes   string `form:"notes"`
+}
+
+// apply copies the form values into a supplier.
+func (f supplierForm) apply(s *data.Supplier) {
+	s.Name, s.Email, s.Phone, s.Website, s.Notes = f.Name, f.Email, f.Phone, f.Website, f.Notes
+}
+
+// supplierFromParam looks up the supplier given as the "id" URI parameter. If
+// it cannot be found, a response is written and false is returned.
+func supplierFromParam(c *gin.Context) (data.Supplier, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Supplier ID")
+		return data.Supplier{}, false
+	}
+
+	s, err := data.GetSupplier(db, uint(lid))
+	if err != nil {
+		if errors.Is(err, data.ErrNoSuchSupplier) {
+			c.String(http.StatusNotFound, "Supplier Not Found")
+			return s, false
+		}
+
+		internalError(c, err)
+		return s, false
+	}
+
+	return s, true
+}
+
+// handleSuppliers is the handler for "/inventory/suppliers".
+//
+// Shows every supplier and a form for adding new suppliers.
+func handleSuppliers(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	sups, err := data.GetSuppliers(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Suppliers []data.Supplier
+		New       data.Supplier
+		Error     string
+	}{ddat, sups, data.Supplier{}, c.Query("error")}
+
+	c.HTML(http.StatusOK, "suppliers.gohtml", dat)
+}
+
+// handleSupplierNew is the handler for POST "/inventory/suppliers".
+func handleSupplierNew(c *gin.Context) {
+	db := tenantDB(c)
+	frm := supplierForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	sup := data.Supplier{}
+	frm.apply(&sup)
+	if err := data.SaveSupplier(db, &sup); err != nil {
+		if errors.Is(err, data.ErrSupplierName) {
+			c.Redirect(http.StatusFound, "/inventory/suppliers?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/supplier/", sup.ID))
+}
+
+// handleSupplier is the handler for "/inventory/supplier/[ID]".
+//
+// Shows a supplier's details and the items in their catalogue.
+func handleSupplier(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	sup, ok := supplierFromParam(c)
+	if !ok {
+		return
+	}
+
+	cat, err := data.GetSupplierCatalogue(db, sup.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dat := struct {
+		DashboardData
+		Supplier  data.Supplier
+		Catalogue []data.CatalogueEntry
+		Saved     bool
+		Error     string
+	}{ddat, sup, cat, c.Request.URL.Query().Has("saved"), c.Query("error")}
+
+	c.HTML(http.StatusOK, "supplier.gohtml", dat)
+}
+
+// handleSupplierEdit is the handler for POST "/inventory/supplier/[ID]/edit".
+func handleSupplierEdit(c *gin.Context) {
+	db := tenantDB(c)
+	sup, ok := supplierFromParam(c)
+	if !ok {
+		return
+	}
+
+	frm := supplierForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	frm.apply(&sup)
+	if err := data.SaveSupplier(db, &sup); err != nil {
+		if errors.Is(err, data.ErrSupplierName) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/inventory/supplier/", sup.ID, "?error=", url.QueryEscape(err.Error())))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/supplier/", sup.ID, "?saved"))
+}
+
+// handleSupplierDelete is the handler for "/inventory/supplier/[ID]/delete".
+func handleSupplierDelete(c *gin.Context) {
+	db := tenantDB(c)
+	sup, ok := supplierFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteSupplier(db, sup.ID); err != nil {
+		if errors.Is(err, data.ErrSupplierInUse) {
+			c.Redirect(http.StatusFound, fmt.Sprint("/inventory/supplier/", sup.ID, "?error=", url.QueryEscape(err.Error())))
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/suppliers")
+}
+
+// handleCatalogueNew is the handler for POST "/inventory/item/[ID]/supplier".
+//
+// Adds the item to the catalogue of the supplier given as "supplier", with
+// the product code "supplier_code" and price "supplier_price" in pounds.
+func handleCatalogueNew(c *gin.Context) {
+	db := tenantDB(c)
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := struct {
+		Supplier uint   `form:"supplier" binding:"required"`
+		Code     string `form:"supplier_code"`
+		Price    string `form:"supplier_price"`
+	}{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	sup, err := data.GetSupplier(db, frm.Supplier)
+	if err != nil {
+		c.String(http.StatusNotFound, "Supplier Not Found")
+		return
+	}
+
+	price, err := data.ParsePrice(frm.Price)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Price: %s", err.Error())
+		return
+	}
+
+	e := data.CatalogueEntry{Model: &gorm.Model{}, SupplierID: sup.ID, ItemID: item.ID, Code: frm.Code, Price: price}
+	if err := db.Omit("Supplier", "Item").Create(&e).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#suppliers"))
+}
+
+// handleCatalogueDelete is the handler for "/inventory/catalogue/[ID]/delete".
+func handleCatalogueDelete(c *gin.Context) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Catalogue Entry ID")
+		return
+	}
+
+	e, err := data.GetCatalogueEntry(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Catalogue Entry Not Found")
+		return
+	}
+
+	if err := db.Delete(&e).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", e.ItemID, "#suppliers"))
+}
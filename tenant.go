@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ejv2/prepper/conf"
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/isams"
+	"github.com/ejv2/prepper/session"
+)
+
+// Tenant routing keys.
+const (
+	// The name of the cookie which remembers the tenant chosen by path
+	// prefix, as links within the site do not carry the prefix.
+	TenantCookieName = "TENANT"
+	// The gin context key under which the request's tenant is stored.
+	tenantContextKey = "tenant"
+)
+
+// A Tenant is a school served by this installation, along with its own view of
+// the config, iSAMS and the database.
+type Tenant struct {
+	conf.Tenant
+
+	Config conf.Config
+	ISAMS  *isams.ISAMS
+	DB     *gorm.DB
+}
+
+// IsTrust returns true if this is the trust level, which belongs to no school.
+func (t *Tenant) IsTrust() bool {
+	return t.ID == ""
+}
+
+// requestTenantKey is the request context key under which a tenant resolved
+// before routing is stored.
+type requestTenantKey struct{}
+
+// initTenants sets up tenant isolation on the database and loads the config
+// for each tenant. If no tenants are configured, the whole installation is
+// served as a single school by the trust tenant.
+func initTenants() error {
+	Trust = &Tenant{Config: Config, ISAMS: ISAMS, DB: Database}
+	Tenants = make(map[string]*Tenant, len(Config.Tenants))
+	if !Config.HasTenants() {
+		return nil
+	}
+
+	if err := data.RegisterTenancy(Database); err != nil {
+		return err
+	}
+
+	for _, tc := range Config.Tenants {
+		t := &Tenant{
+			Tenant: tc,
+			Config: Config.ForTenant(tc),
+			ISAMS:  ISAMS,
+			DB:     data.WithTenant(Database, tc.ID),
+		}
+
+		if tc.ISAMS != nil {
+			is, err := isams.New(tc.ISAMS.Domain, tc.ISAMS.APIKey)
+			if err != nil {
+				return fmt.Errorf("tenant %s: iSAMS load: %w", tc.ID, err)
+			}
+			t.ISAMS = is
+
+			log.Print("ISAMS Support Enabled for ", tc.ID, " (connected to ", tc.ISAMS.Domain, ")")
+		}
+
+		Tenants[tc.ID] = t
+	}
+
+	// Data from before tenants were configured belongs to the default.
+	if def, ok := Config.DefaultTenant(); ok {
+		n, err := data.AdoptUntenanted(Database, def.ID, Models...)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Println("Adopted", n, "existing records into tenant", def.ID)
+		}
+	}
+
+	log.Println("Serving", len(Tenants), "tenants")
+	return nil
+}
+
+// sortedTenants returns every tenant, ordered by name.
+func sortedTenants() []*Tenant {
+	ts := make([]*Tenant, 0, len(Tenants))
+	for _, t := range Tenants {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool {
+		return ts[i].DisplayName() < ts[j].DisplayName()
+	})
+
+	return ts
+}
+
+// servedTenants returns every tenant, or just the trust tenant if a single
+// school is served.
+func servedTenants() []*Tenant {
+	if len(Tenants) == 0 {
+		return []*Tenant{Trust}
+	}
+
+	return sortedTenants()
+}
+
+// checkTenantRoutes ensures that no tenant's path prefix hides a route.
+func checkTenantRoutes(router *gin.Engine) error {
+	for _, r := range router.Routes() {
+		seg, _, _ := strings.Cut(strings.TrimPrefix(r.Path, "/"), "/")
+		if _, ok := Tenants[seg]; ok {
+			return fmt.Errorf("tenant %s: ID clashes with route %s", seg, r.Path)
+		}
+	}
+
+	return nil
+}
+
+// tenantRouter resolves the tenant of each request from its hostname or path
+// prefix before it is routed. A path prefix is stripped from the request and
+// remembered in a cookie, so that routes and links need not know of tenants.
+func tenantRouter(h http.Handler) http.Handler {
+	if !Config.HasTenants() {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tc, ok := Config.TenantForHost(r.Host); ok {
+			r = r.WithContext(context.WithValue(r.Context(), requestTenantKey{}, Tenants[tc.ID]))
+		} else {
+			id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if t, ok := Tenants[id]; ok {
+				http.SetCookie(w, &http.Cookie{
+					Name:  TenantCookieName,
+					Value: t.ID,
+					Path:  "/",
+				})
+
+				r.URL.Path = "/" + rest
+				r.URL.RawPath = ""
+				r = r.WithContext(context.WithValue(r.Context(), requestTenantKey{}, t))
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tenantMiddleware settles the tenant of a request. A tenant given by hostname
+// or path prefix always wins; otherwise the tenant of the signed in user is
+// used, then that remembered by cookie. Sessions belonging to another tenant
+// are signed out, although trust admins may visit any tenant.
+func tenantMiddleware(c *gin.Context) {
+	if !Config.HasTenants() {
+		c.Set(tenantContextKey, Trust)
+		c.Next()
+		return
+	}
+
+	t, fixed := c.Request.Context().Value(requestTenantKey{}).(*Tenant)
+
+	var us *data.User
+	sess, signed := currentSession(c)
+	if signed {
+		u, err := data.GetUser(Database, sess.UserID)
+		if err != nil && !errors.Is(err, data.ErrUserNotFound) {
+			internalError(c, err)
+			c.Abort()
+			return
+		}
+		if err == nil {
+			us = &u
+		}
+	}
+
+	if !fixed {
+		if us != nil && us.Tenant != "" {
+			t = Tenants[us.Tenant]
+		} else if id, err := c.Cookie(TenantCookieName); err == nil {
+			t = Tenants[id]
+		}
+	}
+	if t == nil {
+		t = Trust
+	}
+
+	if signed && (us == nil || !canEnterTenant(*us, t)) {
+		log.Println("Session for user", sess.UserID, "signed out of tenant", t.ID)
+		sess.Logout()
+		Sessions.Update(&sess)
+	}
+
+	c.Set(tenantContextKey, t)
+	c.Next()
+}
+
+// canEnterTenant returns true if the user may use the site as the given
+// tenant. Trust admins may enter any tenant, and are the only users permitted
+// at the trust level.
+func canEnterTenant(u data.User, t *Tenant) bool {
+	if u.IsTrustAdmin() {
+		return true
+	}
+
+	return !t.IsTrust() && u.Tenant == t.ID
+}
+
+// currentSession returns the session of the request without starting one.
+func currentSession(c *gin.Context) (session.Session, bool) {
+	rtok, err := c.Cookie(session.TokenCookieName)
+	if err != nil {
+		return session.Session{}, false
+	}
+
+	tok, err := session.ParseToken(rtok)
+	if err != nil {
+		return session.Session{}, false
+	}
+
+	s, ok := Sessions.Lookup(tok)
+	return s, ok && s.SignedIn
+}
+
+// requireTrust restricts functions affecting the whole installation, such as
+// the server logs and maintenance, to trust admins when tenants are
+// configured.
+func requireTrust(c *gin.Context) {
+	if !Config.HasTenants() {
+		return
+	}
+
+	s, _ := currentSession(c)
+	u, err := data.GetUser(Database, s.UserID)
+	if err != nil || !u.IsTrustAdmin() {
+		c.String(http.StatusForbidden, "Access Denied")
+		c.Abort()
+	}
+}
+
+// tenantOf returns the tenant of the request.
+func tenantOf(c *gin.Context) *Tenant {
+	if t, ok := c.Get(tenantContextKey); ok {
+		return t.(*Tenant)
+	}
+
+	return Trust
+}
+
+// tenantDB returns the database as seen by the tenant of the request. At the
+// trust level, this sees every tenant.
+func tenantDB(c *gin.Context) *gorm.DB {
+	return tenantOf(c).DB
+}
+
+// tenantConfig returns the config as seen by the tenant of the request.
+func tenantConfig(c *gin.Context) conf.Config {
+	return tenantOf(c).Config
+}
+
+// tenantISAMS returns the iSAMS connection of the tenant of the request, which
+// is nil if iSAMS is not configured.
+func tenantISAMS(c *gin.Context) *isams.ISAMS {
+	return tenantOf(c).ISAMS
+}
+
+// findLoginUser looks up the account signing in with the given username.
+// Users may only sign in to their own tenant, while trust admins may sign in
+// to any tenant or to the trust level.
+func findLoginUser(c *gin.Context, name string) (data.User, error) {
+	if !Config.HasTenants() {
+		return data.GetUserByName(Database, name)
+	}
+
+	if t := tenantOf(c); !t.IsTrust() {
+		us, err := data.GetUserByName(t.DB, name)
+		if !errors.Is(err, data.ErrUserNotFound) {
+			return us, err
+		}
+	}
+
+	us, err := data.GetUserByName(data.WithTenant(Database, ""), name)
+	if err != nil {
+		return us, err
+	}
+	if !us.IsTrustAdmin() {
+		return data.User{}, fmt.Errorf("login %s: %w", name, data.ErrUserNotFound)
+	}
+
+	return us, nil
+}
+
+// handleTenants is the handler for "/tenants"
+//
+// Lists every tenant for trust admins to move between. Given "leave", the
+// remembered tenant is forgotten to return to the trust level.
+func handleTenants(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	if !ddat.User.IsTrustAdmin() {
+		c.String(http.StatusForbidden, "Access Denied")
+		return
+	}
+
+	if _, leave := c.GetQuery("leave"); leave {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:   TenantCookieName,
+			Path:   "/",
+			MaxAge: -1,
+		})
+		c.Redirect(http.StatusFound, "/tenants")
+		return
+	}
+
+	c.HTML(http.StatusOK, "tenants.gohtml", struct {
+		DashboardData
+		Tenants []*Tenant
+		Current *Tenant
+	}{ddat, sortedTenants(), tenantOf(c)})
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/ejv2/prepper/data"
 	"github.com/ejv2/prepper/notifications"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // handleTodo is the handler for "/todo/".
@@ -19,6 +22,7 @@ import (
 func handleTodo(c *gin.Context) {
 	s := Sessions.Start(c)
 	defer s.Update()
+	db := tenantDB(c)
 
 	ddat, err := NewDashboardData(s)
 	if err != nil {
@@ -26,41 +30,132 @@ func handleTodo(c *gin.Context) {
 		return
 	}
 
-	pnd, err := data.GetBookingsStatus(Database, data.BookingStatusPending)
+	prep, ok := prepRoomFilter(c, ddat.User)
+	if !ok {
+		return
+	}
+
+	preps, err := data.GetPrepRooms(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	pnd, err := data.GetBookingsStatus(db, data.BookingStatusPending, prep.ID)
+	if err != nil {
+		internalError(c, err)
+	}
+
+	prog, err := data.GetBookingsStatus(db, data.BookingStatusProgress, prep.ID)
 	if err != nil {
 		internalError(c, err)
 	}
 
-	prog, err := data.GetBookingsStatus(Database, data.BookingStatusProgress)
+	done, err := data.GetBookingsStatus(db, data.BookingStatusReady, prep.ID)
 	if err != nil {
 		internalError(c, err)
 	}
 
-	done, err := data.GetBookingsStatus(Database, data.BookingStatusReady)
+	rej, err := data.GetBookingsStatus(db, data.BookingStatusRejected, prep.ID)
 	if err != nil {
 		internalError(c, err)
 	}
 
-	rej, err := data.GetBookingsStatus(Database, data.BookingStatusRejected)
+	wait, err := data.GetBookingsStatus(db, data.BookingStatusWaitlisted, prep.ID)
 	if err != nil {
 		internalError(c, err)
 	}
+	wait = slices.DeleteFunc(wait, data.Booking.Past)
+
+	techs, err := data.PrepRoomTechnicians(db, prep.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Suggest an order of preparation across everything outstanding,
+	// starting with that which must be started soonest.
+	out := slices.Concat(pnd, prog)
+	data.SortPrepOrder(out)
+	order := make(map[uint]int, len(out))
+	for i, b := range out {
+		order[b.ID] = i + 1
+	}
+	data.SortPrepOrder(pnd)
+	data.SortPrepOrder(prog)
+
+	// Warn of the coming week's days with more prep due than can be done.
+	y, m, d := time.Now().Date()
+	days := make([]time.Time, 7)
+	for i := range days {
+		days[i] = time.Date(y, m, d+i, 0, 0, 0, 0, time.Local)
+	}
+	var over []data.CapacityDay
+	for _, cd := range data.PlanCapacity(out, days, len(techs), tenantConfig(c).TechnicianDay()) {
+		if cd.Overloaded() {
+			over = append(over, cd)
+		}
+	}
+
+	// Only show what is left for the current user to do.
+	_, mine := c.GetQuery("mine")
+	if mine {
+		notMine := func(b data.Booking) bool {
+			return b.AssigneeID != nil && *b.AssigneeID != s.UserID
+		}
+		pnd = slices.DeleteFunc(pnd, notMine)
+		prog = slices.DeleteFunc(prog, notMine)
+		done = slices.DeleteFunc(done, notMine)
+		rej = slices.DeleteFunc(rej, notMine)
+	}
+
+	bt, err := usableBatches(db, prog)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	us, err := usableUnits(db, prog)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
 
 	dat := struct {
 		DashboardData
-		Config   conf.Config
-		Pending  []data.Booking
-		Progress []data.Booking
-		Done     []data.Booking
-		Rejected []data.Booking
-	}{ddat, Config, pnd, prog, done, rej}
+		Config     conf.Config
+		Pending    []data.Booking
+		Progress   []data.Booking
+		Done       []data.Booking
+		Rejected   []data.Booking
+		Waitlisted []data.Booking
+		Batches    map[uint][]data.ChemicalBatch
+		Units      map[uint][]data.EquipmentUnit
+		Prep       prepFilter
+		Preps      []data.PrepRoom
+		Techs      []data.User
+		Mine       bool
+		Order      map[uint]int
+		Overloaded []data.CapacityDay
+	}{ddat, tenantConfig(c), pnd, prog, done, rej, wait, bt, us, prep, preps, techs, mine, order, over}
 
 	c.HTML(http.StatusOK, "todo.gohtml", dat)
 }
 
+// errTodoAborted aborts the transaction of a to-do action for which an error
+// response has already been written.
+var errTodoAborted = errors.New("to-do action aborted")
+
 // handleSetStatus handles promoting the status of a booking given as a URI
 // parameter. If there was an error, false is returned, else true.
 func handleSetStatus(status data.BookingStatus, c *gin.Context) bool {
+	return setStatus(status, c, tenantDB(c))
+}
+
+// setStatus sets the status of the booking given as a URI parameter through
+// db, letting its owner know. If there was an error, a response is written
+// and false is returned, else true.
+func setStatus(status data.BookingStatus, c *gin.Context, db *gorm.DB) bool {
 	s := Sessions.Start(c)
 	usr, err := data.GetUser(Database, s.UserID)
 	if err != nil {
@@ -76,17 +171,31 @@ func handleSetStatus(status data.BookingStatus, c *gin.Context) bool {
 		return false
 	}
 
-	bk, err := data.GetBooking(Database, id)
+	bk, err := data.GetBooking(db, id)
 	if err != nil {
 		internalError(c, err)
 		return false
 	}
 
-	res := Database.Model(&bk).Where(&bk).Update("Status", status)
+	// The status of bk is updated in place.
+	old := bk.Status
+	res := db.Model(&bk).Where(&bk).Update("Status", status)
 	if err := res.Error; err != nil {
 		internalError(c, err)
 		return false
 	}
+	// Bookings taken back from rejection are charged again, as prices may
+	// have changed since.
+	if !old.Committed() && status.Committed() {
+		if _, err := data.ChargeBooking(db, bk.ID); err != nil {
+			internalError(c, err)
+			return false
+		}
+	}
+	// Rejected bookings free their equipment for the waitlist.
+	if old.Committed() && !status.Committed() {
+		promoteWaitlist(c, bk.StartTime, bk.EndTime)
+	}
 
 	Notifications.PushUser(bk.OwnerID, notifications.Notification{
 		Title:  "Booking Status Updated",
@@ -121,8 +230,24 @@ func handleTodoProgress(c *gin.Context) {
 }
 
 // handleTodoDone is the handler for "/todo/done/[ID]".
+//
+// Any batches or units selected by the technician are recorded against the
+// booking as it is marked as ready. Either everything is recorded or nothing
+// is.
 func handleTodoDone(c *gin.Context) {
-	if handleSetStatus(data.BookingStatusReady, c) {
-		c.Redirect(http.StatusFound, "/todo/")
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if !handleSetBatches(c, tx) || !handleSetUnits(c, tx) || !setStatus(data.BookingStatusReady, c, tx) {
+			return errTodoAborted
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errTodoAborted) {
+			internalError(c, err)
+		}
+		return
 	}
+
+	c.Redirect(http.StatusFound, "/todo/")
 }
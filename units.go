@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// unitConditions are the conditions which a unit may be given, in the order in
+// which they are offered.
+var unitConditions = []data.UnitCondition{
+	data.ConditionGood, data.ConditionFair, data.ConditionDamaged, data.ConditionRetired,
+}
+
+// unitForm is the form used for creating and editing serialised units.
+type unitForm struct {
+	Serial    string `form:"unit_serial" binding:"required"`
+	Condition uint8  `form:"unit_condition"`
+	Location  string `form:"unit_location"`
+	Notes     string `form:"unit_notes"`
+}
+
+// apply copies the form into the given unit.
+func (f unitForm) apply(u *data.EquipmentUnit) error {
+	loc, err := optionalID(f.Location)
+	if err != nil {
+		return err
+	}
+
+	u.Serial = f.Serial
+	u.Condition = data.UnitCondition(f.Condition)
+	u.LocationID = loc
+	u.Notes = f.Notes
+
+	return nil
+}
+
+// unitStatuses returns the service status of each unit of item from the given
+// service history, which must be sorted most recent first.
+func unitStatuses(item data.EquipmentItem, units []data.EquipmentUnit, svc []data.ServiceRecord) []data.ServiceStatus {
+	st := make([]data.ServiceStatus, len(units))
+	for i := range units {
+		u := &units[i]
+		st[i] = data.ServiceStatus{Item: item, Unit: u}
+		for j := range svc {
+			if svc[j].UnitID != nil && *svc[j].UnitID == u.ID {
+				st[i].Latest = &svc[j]
+				break
+			}
+		}
+	}
+
+	return st
+}
+
+// unitFromParam looks up the unit given as the "id" URI parameter. If there
+// was an error, a response is written and false is returned.
+func unitFromParam(c *gin.Context) (data.EquipmentUnit, bool) {
+	db := tenantDB(c)
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad Unit ID")
+		return data.EquipmentUnit{}, false
+	}
+
+	u, err := data.GetUnit(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Unit Not Found")
+		return u, false
+	}
+
+	return u, true
+}
+
+// handleUnit is the handler for "/inventory/unit/[ID]".
+//
+// Shows the details of a single serialised unit, along with its service and
+// booking history.
+func handleUnit(c *gin.Context) {
+	s := Sessions.Start(c)
+	defer s.Update()
+	db := tenantDB(c)
+
+	ddat, err := NewDashboardData(s)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	u, ok := unitFromParam(c)
+	if !ok {
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, u.ItemID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	svc, err := data.GetServiceRecords(db, item.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	// Only this unit's services are relevant.
+	own := make([]data.ServiceRecord, 0, len(svc))
+	for _, r := range svc {
+		if r.UnitID != nil && *r.UnitID == u.ID {
+			own = append(own, r)
+		}
+	}
+
+	bks, err := data.GetUnitBookings(db, u.ID, 20)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	locs, err := data.GetStorageLocations(db)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	status := data.ServiceStatus{Item: item, Unit: &u}
+	if len(own) > 0 {
+		status.Latest = &own[0]
+	}
+
+	dat := struct {
+		DashboardData
+		Item          data.EquipmentItem
+		Unit          data.EquipmentUnit
+		Services      []data.ServiceRecord
+		ServiceStatus data.ServiceStatus
+		Bookings      []data.Booking
+		Locations     data.Locations
+		Conditions    []data.UnitCondition
+	}{ddat, item, u, own, status, bks, locs.Sorted(), unitConditions}
+
+	c.HTML(http.StatusOK, "unit.gohtml", dat)
+}
+
+// handleUnitNew is the handler for POST "/inventory/item/[ID]/unit".
+func handleUnitNew(c *gin.Context) {
+	db := tenantDB(c)
+	siid := c.Param("id")
+	lid, err := strconv.ParseUint(siid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid Item ID")
+		return
+	}
+
+	item, err := data.GetEquipmentItem(db, uint(lid))
+	if err != nil {
+		c.String(http.StatusNotFound, "Item Not Found")
+		return
+	}
+
+	frm := unitForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	u := data.EquipmentUnit{Model: &gorm.Model{}, ItemID: item.ID}
+	if err := frm.apply(&u); err != nil {
+		c.String(http.StatusBadRequest, "Bad Location ID")
+		return
+	}
+
+	if err := db.Create(&u).Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", item.ID, "#units"))
+}
+
+// handleUnitEdit is the handler for POST "/inventory/unit/[ID]/edit".
+func handleUnitEdit(c *gin.Context) {
+	db := tenantDB(c)
+	u, ok := unitFromParam(c)
+	if !ok {
+		return
+	}
+
+	frm := unitForm{}
+	if err := c.Bind(&frm); err != nil {
+		c.String(http.StatusBadRequest, "Bad Inputs")
+		return
+	}
+
+	if err := frm.apply(&u); err != nil {
+		c.String(http.StatusBadRequest, "Bad Location ID")
+		return
+	}
+
+	res := db.Model(&u).
+		Update("serial", u.Serial).
+		Update("condition", u.Condition).
+		Update("location_id", u.LocationID).
+		Update("notes", u.Notes)
+	if err := res.Error; err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/unit/", u.ID))
+}
+
+// handleUnitDelete is the handler for "/inventory/unit/[ID]/delete".
+//
+// Units which have been issued for bookings or serviced must be retired
+// instead.
+func handleUnitDelete(c *gin.Context) {
+	db := tenantDB(c)
+	u, ok := unitFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteUnit(db, u); err != nil {
+		if errors.Is(err, data.ErrUnitHistory) {
+			c.String(http.StatusBadRequest, "Cannot delete unit %s: %s", u.Serial, data.ErrUnitHistory)
+			return
+		}
+
+		internalError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprint("/inventory/item/", u.ItemID, "#units"))
+}
+
+// usableUnits returns the usable units of every item used by the given
+// bookings, keyed by item ID. Items which are not tracked by unit are
+// omitted.
+func usableUnits(db *gorm.DB, bks []data.Booking) (map[uint][]data.EquipmentUnit, error) {
+	m := make(map[uint][]data.EquipmentUnit)
+	seen := make(map[uint]bool)
+
+	for _, bk := range bks {
+		for _, eq := range bk.Activity.Equipment {
+			if seen[eq.ItemID] {
+				continue
+			}
+			seen[eq.ItemID] = true
+
+			us, err := data.GetItemUnits(db, eq.ItemID)
+			if err != nil {
+				return m, err
+			}
+
+			for _, u := range us {
+				if u.Condition.Usable() {
+					m[eq.ItemID] = append(m[eq.ItemID], u)
+				}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// handleSetUnits records the units issued for each equipment set of the
+// booking given as a URI parameter. Units are passed as query parameters of
+// the form "unit_[SET ID]=[UNIT ID]", which may be repeated. Sets of items
+// which are tracked by unit have their issued units replaced, even if none are
+// given. Changes are made through db. If there was an error, a response is
+// written and false is returned, else true.
+func handleSetUnits(c *gin.Context, db *gorm.DB) bool {
+	sid := c.Param("id")
+	lid, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Bad ID Format: %s", err)
+		return false
+	}
+
+	bk, err := data.GetBooking(db, uint(lid))
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+
+	usable, err := usableUnits(db, []data.Booking{bk})
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+
+	for _, eq := range bk.Activity.Equipment {
+		if len(usable[eq.ItemID]) == 0 {
+			continue
+		}
+
+		sus := c.QueryArray(fmt.Sprint("unit_", eq.ID))
+		ids := make([]uint, 0, len(sus))
+		for _, su := range sus {
+			uid, err := strconv.ParseUint(su, 10, 32)
+			if err != nil {
+				c.String(http.StatusBadRequest, "Bad Unit ID Format: %s", err)
+				return false
+			}
+			ids = append(ids, uint(uid))
+		}
+
+		if err := data.IssueUnits(db, bk, eq, ids); err != nil {
+			if errors.Is(err, data.ErrUnitBooked) || errors.Is(err, data.ErrUnitUnusable) ||
+				errors.Is(err, data.ErrTooManyUnits) || errors.Is(err, data.ErrNoSuchUnit) {
+				c.String(http.StatusBadRequest, "Cannot issue units: %s", err.Error())
+				return false
+			}
+
+			internalError(c, err)
+			return false
+		}
+	}
+
+	return true
+}
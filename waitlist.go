@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ejv2/prepper/data"
+	"github.com/ejv2/prepper/notifications"
+)
+
+// promoteWaitlist promotes waitlisted bookings overlapping the given period
+// for which equipment has been freed, letting their owners and technicians
+// know. Failure is not fatal, as the waitlist is checked again whenever
+// equipment is next freed.
+func promoteWaitlist(c *gin.Context, start, end time.Time) {
+	db := tenantDB(c)
+	promoted, err := data.PromoteWaitlist(db, start, end)
+	if err != nil {
+		log.Println("promote waitlist:", err)
+	}
+
+	for _, bk := range promoted {
+		Notifications.PushUser(bk.OwnerID, notifications.Notification{
+			Title:  "Booking Off Waitlist",
+			Body:   fmt.Sprint("Equipment has been freed for your booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), ", which is no longer waitlisted."),
+			Action: fmt.Sprint("/book/booking/", bk.ID),
+			Type:   notifications.TypeImportant,
+			Time:   time.Now(),
+		})
+
+		urs, err := data.PrepRoomTechnicians(db, bk.PrepRoomID)
+		if err != nil {
+			log.Println("promote waitlist:", err)
+			continue
+		}
+		for _, u := range urs {
+			Notifications.PushUser(u.ID, notifications.Notification{
+				Title:  "Booking Off Waitlist",
+				Body:   fmt.Sprint(bk.Owner.DisplayName(), "'s booking of ", bk.Activity.Title, " for ", bk.StartTime.Local().Format("02/01/06 15:04"), " has been promoted from the waitlist."),
+				Action: "/todo/",
+				Type:   notifications.TypeImportant,
+				Time:   time.Now(),
+			})
+		}
+
+		checkBudget(db, bk)
+		autoAssign(c, bk)
+	}
+}
+
+// joinPeriod returns the smallest period covering both given periods.
+func joinPeriod(s1, e1, s2, e2 time.Time) (time.Time, time.Time) {
+	if s2.Before(s1) {
+		s1 = s2
+	}
+	if e2.After(e1) {
+		e1 = e2
+	}
+
+	return s1, e1
+}